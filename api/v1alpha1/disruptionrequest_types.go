@@ -0,0 +1,115 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DisruptionRequestPhase is the state of a DisruptionRequest.
+// +kubebuilder:validation:Enum=Pending;WaitingForBudget;Evicted;Canceled;Expired
+type DisruptionRequestPhase string
+
+const (
+	// DisruptionRequestPhasePending means the pod has pending pre-activities.
+	DisruptionRequestPhasePending DisruptionRequestPhase = "Pending"
+	// DisruptionRequestPhaseWaitingForBudget means the pre-activities completed,
+	// but the eviction was denied, e.g. by the disruption budget.
+	DisruptionRequestPhaseWaitingForBudget DisruptionRequestPhase = "WaitingForBudget"
+	// DisruptionRequestPhaseEvicted means x-pdb evicted the pod.
+	DisruptionRequestPhaseEvicted DisruptionRequestPhase = "Evicted"
+	// DisruptionRequestPhaseCanceled means the pod is gone or was replaced before x-pdb evicted it.
+	DisruptionRequestPhaseCanceled DisruptionRequestPhase = "Canceled"
+	// DisruptionRequestPhaseExpired means the pod could not be evicted before the request expired.
+	DisruptionRequestPhaseExpired DisruptionRequestPhase = "Expired"
+)
+
+// IsFinished returns true if the phase is final.
+func (p DisruptionRequestPhase) IsFinished() bool {
+	switch p {
+	case DisruptionRequestPhaseEvicted, DisruptionRequestPhaseCanceled, DisruptionRequestPhaseExpired:
+		return true
+	}
+	return false
+}
+
+// DisruptionRequestSpec defines the eviction which was blocked by pre-activities.
+type DisruptionRequestSpec struct {
+	// PodName is the name of the pod to be evicted.
+	PodName string `json:"podName"`
+	// PodUID is the UID of the pod to be evicted, replaced pods of the same name are not evicted.
+	PodUID types.UID `json:"podUID"`
+	// XPDBName is the name of the XPodDisruptionBudget protecting the pod, if any.
+	// +optional
+	XPDBName string `json:"xpdbName,omitempty"`
+	// Requester is the user whose eviction was blocked.
+	// +optional
+	Requester string `json:"requester,omitempty"`
+	// ExpiresAt is the time x-pdb gives up evicting the pod.
+	ExpiresAt metav1.Time `json:"expiresAt"`
+}
+
+// DisruptionRequestTransition records a change of the phase of a DisruptionRequest.
+type DisruptionRequestTransition struct {
+	Phase DisruptionRequestPhase `json:"phase"`
+	// +optional
+	Message            string      `json:"message,omitempty"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+}
+
+// DisruptionRequestStatus defines the observed state of DisruptionRequest.
+type DisruptionRequestStatus struct {
+	// +optional
+	Phase DisruptionRequestPhase `json:"phase,omitempty"`
+	// Message explains the current phase, e.g. why the eviction was denied.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Transitions lists the phases of the request, oldest first.
+	// +optional
+	Transitions []DisruptionRequestTransition `json:"transitions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Pod",type=string,JSONPath=`.spec.podName`
+// +kubebuilder:printcolumn:name="XPDB",type=string,JSONPath=`.spec.xpdbName`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// DisruptionRequest records an eviction which was blocked by pending pre-activities.
+// x-pdb evicts the pod on behalf of the requester once the pre-activities completed.
+type DisruptionRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DisruptionRequestSpec   `json:"spec,omitempty"`
+	Status DisruptionRequestStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DisruptionRequestList contains a list of DisruptionRequest.
+type DisruptionRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DisruptionRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DisruptionRequest{}, &DisruptionRequestList{})
+}
@@ -28,4 +28,12 @@ const (
 	XPDBEventReasonBlocked XPDBEventReason = "Blocked"
 	// XPDBEventReasonAccepted represents a accepted disruption.
 	XPDBEventReasonAccepted XPDBEventReason = "Accepted"
+	// XPDBEventReasonPreActivityRequested represents a pre-activity pending on a pod.
+	XPDBEventReasonPreActivityRequested XPDBEventReason = "PreActivityRequested"
+	// XPDBEventReasonPreActivityCompleted represents a completed pre-activity.
+	XPDBEventReasonPreActivityCompleted XPDBEventReason = "PreActivityCompleted"
+	// XPDBEventReasonPreActivityTimedOut represents a pre-activity which didn't complete within the timeout.
+	XPDBEventReasonPreActivityTimedOut XPDBEventReason = "PreActivityTimedOut"
+	// XPDBEventReasonPreActivitiesCompleted represents a pod whose pre-activities all completed.
+	XPDBEventReasonPreActivitiesCompleted XPDBEventReason = "PreActivitiesCompleted"
 )
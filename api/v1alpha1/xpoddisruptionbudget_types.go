@@ -43,9 +43,17 @@ type XPodDisruptionBudgetSpec struct {
 	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
 
 	// Label query over pods whose evictions are managed by the disruption
-	// budget.
+	// budget. It is ignored if "targetRef" is set.
+	// +optional
 	Selector metav1.LabelSelector `json:"selector,omitempty"`
 
+	// TargetRef references the workload whose pods are managed by the disruption
+	// budget. It is resolved in each cluster to the pod selector of the workload
+	// and its number of replicas is used as the expected number of pods.
+	// The workload must implement the scale subresource.
+	// +optional
+	TargetRef *XPodDisruptionBudgetTargetRef `json:"targetRef,omitempty"`
+
 	// An eviction is allowed if at most "maxUnavailable" pods selected by
 	// "selector" are unavailable after the eviction, i.e. even in absence of
 	// the evicted pod. For example, one can prevent all voluntary evictions
@@ -53,18 +61,103 @@ type XPodDisruptionBudgetSpec struct {
 	// +optional
 	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
 
+	// RemoteMappings translate the namespace and selector of the XPDB for remote
+	// clusters whose workloads use a different namespace or labels.
+	// They take precedence over the mapping configured for the remote.
+	// +optional
+	RemoteMappings []XPodDisruptionBudgetRemoteMapping `json:"remoteMappings,omitempty"`
+
 	// XPDB allows workload owners to define a disruption probe endpoint.
 	// It might be helpful to probe internal state of some workloads like databases
 	// to verify wether an eviction can happen or not.
 	// Database Raft groups might become unavailable if a given pod
 	// is disrupted. In these cases workload owners might want to
 	// block disruptions to happen, even if all pods are ready.
+	// It is evaluated as the first probe of "probes".
 	// +optional
 	Probe *XPodDisruptionBudgetProbeSpec `json:"probe,omitempty"`
+
+	// Probes lists the disruption probes of the XPDB.
+	// They are combined according to "probeMode".
+	// +optional
+	Probes []XPodDisruptionBudgetProbeSpec `json:"probes,omitempty"`
+
+	// ProbeMode defines whether All (default) or Any of the probes must allow a disruption.
+	// +kubebuilder:validation:Enum=All;Any
+	// +optional
+	ProbeMode ProbeMode `json:"probeMode,omitempty"`
+
+	// PreActivities configures whether pending pre-activities of the pods block their disruption.
+	// +optional
+	PreActivities *XPodDisruptionBudgetPreActivities `json:"preActivities,omitempty"`
+}
+
+// XPodDisruptionBudgetPreActivities configures the pre-activities of the pods of a XPDB.
+type XPodDisruptionBudgetPreActivities struct {
+	// Enabled defines whether pending pre-activities block disruptions of the pods
+	// and whether disruption probes may request pre-activities.
+	// Defaults to the --pre-activities-enabled flag of x-pdb, which is true unless set.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// ProbeMode defines how the verdicts of multiple disruption probes are combined.
+type ProbeMode string
+
+const (
+	// ProbeModeAll allows a disruption if all probes allow it.
+	ProbeModeAll ProbeMode = "All"
+	// ProbeModeAny allows a disruption if any probe allows it.
+	ProbeModeAny ProbeMode = "Any"
+)
+
+// ProbeFailurePolicy defines how errors calling a disruption probe are handled.
+type ProbeFailurePolicy string
+
+const (
+	// ProbeFailurePolicyFail denies the disruption if the probe can't be called.
+	ProbeFailurePolicyFail ProbeFailurePolicy = "Fail"
+	// ProbeFailurePolicyIgnore skips the probe if it can't be called.
+	ProbeFailurePolicyIgnore ProbeFailurePolicy = "Ignore"
+)
+
+// XPodDisruptionBudgetTargetRef references a workload in the namespace of the XPDB.
+type XPodDisruptionBudgetTargetRef struct {
+	// API version of the workload, e.g. apps/v1.
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the workload, e.g. StatefulSet.
+	Kind string `json:"kind"`
+
+	// Name of the workload.
+	Name string `json:"name"`
+}
+
+// XPodDisruptionBudgetRemoteMapping translates the namespace and selector of a XPDB for a remote cluster.
+type XPodDisruptionBudgetRemoteMapping struct {
+	// Endpoint of the remote x-pdb, as configured with --remote-endpoints or --remotes-config.
+	Endpoint string `json:"endpoint"`
+
+	// Namespace of the pods in the remote cluster.
+	// Defaults to the namespace of the XPDB.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Label query over the pods in the remote cluster.
+	// Defaults to the selector of the XPDB.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
 }
 
 // XPodDisruptionBudgetProbeSpec allows workload owners to define a disruption probe endpoint.
+// +kubebuilder:validation:XValidation:rule="!(has(self.endpoint) && has(self.http))",message="endpoint and http are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="((has(self.timeout) ? duration(self.timeout).getMilliseconds() : 2000) + 50 * (has(self.retries) ? self.retries : 0)) * ((has(self.retries) ? self.retries : 0) + 1) <= 3000",message="timeout * (retries + 1) plus the backoff of 100ms * retries * (retries + 1) / 2 must not exceed 3s, the time the probes of a disruption have to complete"
 type XPodDisruptionBudgetProbeSpec struct {
+	// Name of the probe, used to report the probe which blocked a disruption.
+	// Defaults to the endpoint or the URL of the probe.
+	// +optional
+	Name string `json:"name,omitempty"`
+
 	// Specifies if the x-pdb will perform a call to the disruption probe endpoint.
 	// Its enabled by default. It can be manually disabled in the case the disruption probe
 	// is unavailable and a disruption needs to be performed.
@@ -74,7 +167,133 @@ type XPodDisruptionBudgetProbeSpec struct {
 	// The endpoint that x-pdb will call when evaluating if a given pod can be disrupted.
 	// This is a grpc endpoint of a service that should implement the contract defined in
 	// protos/disruptionprobe/disruptionprobe.proto.
+	// The endpoint can be a template over the pod being disrupted to call a probe of the pod itself,
+	// e.g. {{.PodIP}}:9090, {{.Hostname}}.{{.ServiceName}}:9090 or {{.PodIP}}:{{port "probe"}}.
+	// +optional
 	Endpoint string `json:"endpoint,omitempty"`
+
+	// HTTP configures a HTTP disruption probe instead of a grpc endpoint.
+	// +optional
+	HTTP *XPodDisruptionBudgetHTTPProbe `json:"http,omitempty"`
+
+	// Timeout of a single call of the probe. Defaults to 2s.
+	// All calls of the probe, including retries and the backoff between them, have to complete
+	// within 3s of locking the disruption, before the lock expires.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// FailurePolicy defines how errors calling the probe are handled.
+	// Fail (default) denies the disruption, Ignore skips the probe.
+	// +kubebuilder:validation:Enum=Fail;Ignore
+	// +optional
+	FailurePolicy ProbeFailurePolicy `json:"failurePolicy,omitempty"`
+
+	// Retries is the number of times a failed call of the probe is retried.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=5
+	// +optional
+	Retries int32 `json:"retries,omitempty"`
+
+	// TLS configures the CA used to verify the probe and the client certificate presented to it.
+	// +optional
+	TLS *XPodDisruptionBudgetProbeTLS `json:"tls,omitempty"`
+
+	// Auth configures the bearer token sent to the probe.
+	// +optional
+	Auth *XPodDisruptionBudgetProbeAuth `json:"auth,omitempty"`
+
+	// ProtocolVersion of the probe. If unset, grpc probes are called with v2 and fall back
+	// to v1 if they don't implement it, HTTP probes are called with v1.
+	// +kubebuilder:validation:Enum=v1;v2
+	// +optional
+	ProtocolVersion ProbeProtocolVersion `json:"protocolVersion,omitempty"`
+}
+
+// ProbeProtocolVersion is the version of the protocol spoken by a disruption probe.
+type ProbeProtocolVersion string
+
+const (
+	// ProbeProtocolVersionV1 is the disruptionprobe.v1 protocol.
+	ProbeProtocolVersionV1 ProbeProtocolVersion = "v1"
+	// ProbeProtocolVersionV2 is the disruptionprobe.v2 protocol.
+	ProbeProtocolVersionV2 ProbeProtocolVersion = "v2"
+)
+
+// XPodDisruptionBudgetProbeTLS configures the TLS connection to a disruption probe.
+// The referenced Secrets must be in the namespace of the XPDB and labelled with
+// x-pdb.form3.tech/probe-credentials=true. Rotated Secrets are picked up without restarting x-pdb.
+type XPodDisruptionBudgetProbeTLS struct {
+	// CA references the PEM encoded CA bundle used to verify the certificate of the probe.
+	// Defaults to the CA of x-pdb.
+	// +optional
+	CA *XPodDisruptionBudgetSecretKeyRef `json:"ca,omitempty"`
+
+	// ClientCertificateSecretName is the name of a Secret of type kubernetes.io/tls
+	// holding the client certificate presented to the probe.
+	// +optional
+	ClientCertificateSecretName string `json:"clientCertificateSecretName,omitempty"`
+}
+
+// XPodDisruptionBudgetProbeAuth configures the bearer token sent to a disruption probe.
+// The token is sent in the authorization header of HTTPS probes and in the authorization metadata of grpc probes.
+// +kubebuilder:validation:XValidation:rule="!(has(self.serviceAccountToken) && has(self.bearerToken))",message="serviceAccountToken and bearerToken are mutually exclusive"
+type XPodDisruptionBudgetProbeAuth struct {
+	// ServiceAccountToken sends a token of the x-pdb service account.
+	// +optional
+	ServiceAccountToken *XPodDisruptionBudgetProbeServiceAccountToken `json:"serviceAccountToken,omitempty"`
+
+	// BearerToken references a static token in a Secret.
+	// The Secret must be in the namespace of the XPDB and labelled with x-pdb.form3.tech/probe-credentials=true.
+	// +optional
+	BearerToken *XPodDisruptionBudgetSecretKeyRef `json:"bearerToken,omitempty"`
+}
+
+// XPodDisruptionBudgetProbeServiceAccountToken configures the service account token sent to a disruption probe.
+// The probe can verify the token with a TokenReview.
+type XPodDisruptionBudgetProbeServiceAccountToken struct {
+	// Audience of the token. It must be one of the audiences allowed by the x-pdb configuration.
+	Audience string `json:"audience"`
+
+	// ExpirationSeconds is the requested lifetime of the token. Defaults to 3600.
+	// +kubebuilder:validation:Minimum=600
+	// +optional
+	ExpirationSeconds *int64 `json:"expirationSeconds,omitempty"`
+}
+
+// XPodDisruptionBudgetSecretKeyRef references a key of a Secret in the namespace of the XPDB.
+type XPodDisruptionBudgetSecretKeyRef struct {
+	// Name of the Secret.
+	Name string `json:"name"`
+
+	// Key of the Secret.
+	Key string `json:"key"`
+}
+
+// XPodDisruptionBudgetHTTPProbe defines a HTTP disruption probe.
+// x-pdb sends the IsDisruptionAllowedRequest encoded as JSON and expects
+// the IsDisruptionAllowedResponse encoded as JSON.
+type XPodDisruptionBudgetHTTPProbe struct {
+	// URL of the probe, e.g. https://probe.db.svc.cluster.local:8443/disruption.
+	// Like the endpoint, the URL can be a template over the pod being disrupted.
+	URL string `json:"url"`
+
+	// Method of the request. Defaults to POST.
+	// +kubebuilder:validation:Enum=GET;POST;PUT
+	// +optional
+	Method string `json:"method,omitempty"`
+
+	// Headers added to the request.
+	// +optional
+	Headers []XPodDisruptionBudgetHTTPHeader `json:"headers,omitempty"`
+}
+
+// XPodDisruptionBudgetHTTPHeader is a header of a HTTP disruption probe request.
+type XPodDisruptionBudgetHTTPHeader struct {
+	// Name of the header.
+	Name string `json:"name"`
+
+	// Value of the header.
+	Value string `json:"value"`
 }
 
 // XPodDisruptionBudgetStatus defines the observed state of XPodDisruptionBudget.
@@ -21,10 +21,124 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisruptionRequest) DeepCopyInto(out *DisruptionRequest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisruptionRequest.
+func (in *DisruptionRequest) DeepCopy() *DisruptionRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(DisruptionRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DisruptionRequest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisruptionRequestList) DeepCopyInto(out *DisruptionRequestList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DisruptionRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisruptionRequestList.
+func (in *DisruptionRequestList) DeepCopy() *DisruptionRequestList {
+	if in == nil {
+		return nil
+	}
+	out := new(DisruptionRequestList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DisruptionRequestList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisruptionRequestSpec) DeepCopyInto(out *DisruptionRequestSpec) {
+	*out = *in
+	in.ExpiresAt.DeepCopyInto(&out.ExpiresAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisruptionRequestSpec.
+func (in *DisruptionRequestSpec) DeepCopy() *DisruptionRequestSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DisruptionRequestSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisruptionRequestStatus) DeepCopyInto(out *DisruptionRequestStatus) {
+	*out = *in
+	if in.Transitions != nil {
+		in, out := &in.Transitions, &out.Transitions
+		*out = make([]DisruptionRequestTransition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisruptionRequestStatus.
+func (in *DisruptionRequestStatus) DeepCopy() *DisruptionRequestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DisruptionRequestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisruptionRequestTransition) DeepCopyInto(out *DisruptionRequestTransition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisruptionRequestTransition.
+func (in *DisruptionRequestTransition) DeepCopy() *DisruptionRequestTransition {
+	if in == nil {
+		return nil
+	}
+	out := new(DisruptionRequestTransition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *XPodDisruptionBudget) DeepCopyInto(out *XPodDisruptionBudget) {
 	*out = *in
@@ -52,6 +166,41 @@ func (in *XPodDisruptionBudget) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XPodDisruptionBudgetHTTPHeader) DeepCopyInto(out *XPodDisruptionBudgetHTTPHeader) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XPodDisruptionBudgetHTTPHeader.
+func (in *XPodDisruptionBudgetHTTPHeader) DeepCopy() *XPodDisruptionBudgetHTTPHeader {
+	if in == nil {
+		return nil
+	}
+	out := new(XPodDisruptionBudgetHTTPHeader)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XPodDisruptionBudgetHTTPProbe) DeepCopyInto(out *XPodDisruptionBudgetHTTPProbe) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]XPodDisruptionBudgetHTTPHeader, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XPodDisruptionBudgetHTTPProbe.
+func (in *XPodDisruptionBudgetHTTPProbe) DeepCopy() *XPodDisruptionBudgetHTTPProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(XPodDisruptionBudgetHTTPProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *XPodDisruptionBudgetList) DeepCopyInto(out *XPodDisruptionBudgetList) {
 	*out = *in
@@ -84,6 +233,71 @@ func (in *XPodDisruptionBudgetList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XPodDisruptionBudgetPreActivities) DeepCopyInto(out *XPodDisruptionBudgetPreActivities) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XPodDisruptionBudgetPreActivities.
+func (in *XPodDisruptionBudgetPreActivities) DeepCopy() *XPodDisruptionBudgetPreActivities {
+	if in == nil {
+		return nil
+	}
+	out := new(XPodDisruptionBudgetPreActivities)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XPodDisruptionBudgetProbeAuth) DeepCopyInto(out *XPodDisruptionBudgetProbeAuth) {
+	*out = *in
+	if in.ServiceAccountToken != nil {
+		in, out := &in.ServiceAccountToken, &out.ServiceAccountToken
+		*out = new(XPodDisruptionBudgetProbeServiceAccountToken)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BearerToken != nil {
+		in, out := &in.BearerToken, &out.BearerToken
+		*out = new(XPodDisruptionBudgetSecretKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XPodDisruptionBudgetProbeAuth.
+func (in *XPodDisruptionBudgetProbeAuth) DeepCopy() *XPodDisruptionBudgetProbeAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(XPodDisruptionBudgetProbeAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XPodDisruptionBudgetProbeServiceAccountToken) DeepCopyInto(out *XPodDisruptionBudgetProbeServiceAccountToken) {
+	*out = *in
+	if in.ExpirationSeconds != nil {
+		in, out := &in.ExpirationSeconds, &out.ExpirationSeconds
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XPodDisruptionBudgetProbeServiceAccountToken.
+func (in *XPodDisruptionBudgetProbeServiceAccountToken) DeepCopy() *XPodDisruptionBudgetProbeServiceAccountToken {
+	if in == nil {
+		return nil
+	}
+	out := new(XPodDisruptionBudgetProbeServiceAccountToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *XPodDisruptionBudgetProbeSpec) DeepCopyInto(out *XPodDisruptionBudgetProbeSpec) {
 	*out = *in
@@ -92,6 +306,26 @@ func (in *XPodDisruptionBudgetProbeSpec) DeepCopyInto(out *XPodDisruptionBudgetP
 		*out = new(bool)
 		**out = **in
 	}
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = new(XPodDisruptionBudgetHTTPProbe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(XPodDisruptionBudgetProbeTLS)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(XPodDisruptionBudgetProbeAuth)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XPodDisruptionBudgetProbeSpec.
@@ -104,6 +338,61 @@ func (in *XPodDisruptionBudgetProbeSpec) DeepCopy() *XPodDisruptionBudgetProbeSp
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XPodDisruptionBudgetProbeTLS) DeepCopyInto(out *XPodDisruptionBudgetProbeTLS) {
+	*out = *in
+	if in.CA != nil {
+		in, out := &in.CA, &out.CA
+		*out = new(XPodDisruptionBudgetSecretKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XPodDisruptionBudgetProbeTLS.
+func (in *XPodDisruptionBudgetProbeTLS) DeepCopy() *XPodDisruptionBudgetProbeTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(XPodDisruptionBudgetProbeTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XPodDisruptionBudgetRemoteMapping) DeepCopyInto(out *XPodDisruptionBudgetRemoteMapping) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XPodDisruptionBudgetRemoteMapping.
+func (in *XPodDisruptionBudgetRemoteMapping) DeepCopy() *XPodDisruptionBudgetRemoteMapping {
+	if in == nil {
+		return nil
+	}
+	out := new(XPodDisruptionBudgetRemoteMapping)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XPodDisruptionBudgetSecretKeyRef) DeepCopyInto(out *XPodDisruptionBudgetSecretKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XPodDisruptionBudgetSecretKeyRef.
+func (in *XPodDisruptionBudgetSecretKeyRef) DeepCopy() *XPodDisruptionBudgetSecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(XPodDisruptionBudgetSecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *XPodDisruptionBudgetSpec) DeepCopyInto(out *XPodDisruptionBudgetSpec) {
 	*out = *in
@@ -118,16 +407,40 @@ func (in *XPodDisruptionBudgetSpec) DeepCopyInto(out *XPodDisruptionBudgetSpec)
 		**out = **in
 	}
 	in.Selector.DeepCopyInto(&out.Selector)
+	if in.TargetRef != nil {
+		in, out := &in.TargetRef, &out.TargetRef
+		*out = new(XPodDisruptionBudgetTargetRef)
+		**out = **in
+	}
 	if in.MaxUnavailable != nil {
 		in, out := &in.MaxUnavailable, &out.MaxUnavailable
 		*out = new(intstr.IntOrString)
 		**out = **in
 	}
+	if in.RemoteMappings != nil {
+		in, out := &in.RemoteMappings, &out.RemoteMappings
+		*out = make([]XPodDisruptionBudgetRemoteMapping, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Probe != nil {
 		in, out := &in.Probe, &out.Probe
 		*out = new(XPodDisruptionBudgetProbeSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		*out = make([]XPodDisruptionBudgetProbeSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PreActivities != nil {
+		in, out := &in.PreActivities, &out.PreActivities
+		*out = new(XPodDisruptionBudgetPreActivities)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XPodDisruptionBudgetSpec.
@@ -154,3 +467,18 @@ func (in *XPodDisruptionBudgetStatus) DeepCopy() *XPodDisruptionBudgetStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XPodDisruptionBudgetTargetRef) DeepCopyInto(out *XPodDisruptionBudgetTargetRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XPodDisruptionBudgetTargetRef.
+func (in *XPodDisruptionBudgetTargetRef) DeepCopy() *XPodDisruptionBudgetTargetRef {
+	if in == nil {
+		return nil
+	}
+	out := new(XPodDisruptionBudgetTargetRef)
+	in.DeepCopyInto(out)
+	return out
+}
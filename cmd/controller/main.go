@@ -17,20 +17,36 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/form3tech-oss/x-pdb/internal/audit"
+	"github.com/form3tech-oss/x-pdb/internal/certs"
 	"github.com/form3tech-oss/x-pdb/internal/disruptionprobe"
+	"github.com/form3tech-oss/x-pdb/internal/disruptionrequests"
+	"github.com/form3tech-oss/x-pdb/internal/health"
 	"github.com/form3tech-oss/x-pdb/internal/lock"
+	"github.com/form3tech-oss/x-pdb/internal/mtls"
 	"github.com/form3tech-oss/x-pdb/internal/pdb"
 	"github.com/form3tech-oss/x-pdb/internal/preactivities"
+	"github.com/form3tech-oss/x-pdb/internal/proxy"
+	"github.com/form3tech-oss/x-pdb/internal/remotes"
 	stateclient "github.com/form3tech-oss/x-pdb/internal/state/client"
 	stateserver "github.com/form3tech-oss/x-pdb/internal/state/server"
+	"github.com/form3tech-oss/x-pdb/internal/tracing"
 	"github.com/form3tech-oss/x-pdb/internal/webhooks"
+	statepb "github.com/form3tech-oss/x-pdb/pkg/proto/state/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
@@ -43,7 +59,6 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
@@ -71,11 +86,54 @@ func main() {
 	var controllerCertsDir string
 	var controllerPort int
 	var remoteEndpoints string
+	var remotesConfigPath string
+	var spiffeEndpointSocket string
+	var peersConfig string
 	var leaseNamespace string
 	var podID string
 	var kubeContext string
 	var clusterID string
 	var dryRun bool
+	var selfSignedCerts bool
+	var certsNamespace string
+	var caSecretName string
+	var trustedCAsConfigMap string
+	var webhookConfigurationName string
+	var webhookDNSNames string
+	var controllerDNSNames string
+	var caValidity time.Duration
+	var certValidity time.Duration
+	var certRenewBefore time.Duration
+	var probeProxyURL string
+	var probeProxyPasswordFile string
+	var peerMonitorInterval time.Duration
+	var probeClientIdleTimeout time.Duration
+	var serviceAccountName string
+	var probeTokenAudiences string
+	var probeSecrets bool
+	var probeSecretNamespaces string
+	var probeCacheTTL time.Duration
+	var readinessMinCertValidity time.Duration
+	var readinessRemoteQuorum int
+	var readinessRemoteMaxAge time.Duration
+	var otlpEndpoint string
+	var otlpInsecure bool
+	var traceSampleRatio float64
+	var auditStdout bool
+	var auditFile string
+	var auditFileMaxSize int64
+	var auditFileMaxBackups int
+	var auditWebhookURL string
+	var auditWebhookBatchSize int
+	var auditWebhookFlushInterval time.Duration
+	var auditWebhookMaxRetries int
+	var multipleXPDBPolicy string
+	var disruptionCooldown time.Duration
+	var preActivitiesEnabled bool
+	var preActivityTimeout time.Duration
+	var preActivityTimeoutPolicy string
+	var disruptionRequests bool
+	var disruptionRequestTTL time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.StringVar(&webhookCertsDir, "webhook-certs-dir", "", "The directory that contains webhook certificates")
@@ -83,6 +141,18 @@ func main() {
 	flag.StringVar(&controllerCertsDir, "controller-certs-dir", "", "The directory that contains webhook certificates")
 	flag.IntVar(&controllerPort, "controller-port", 9643, "The state server binding port")
 	flag.StringVar(&remoteEndpoints, "remote-endpoints", "", "The list of endpoints of the remote pdb controllers")
+	flag.StringVar(&remotesConfigPath, "remotes-config", "",
+		"Path to the file that configures the remote x-pdb deployments. Mutually exclusive with --remote-endpoints.",
+	)
+	flag.StringVar(&spiffeEndpointSocket, "spiffe-endpoint-socket", "",
+		"Address of the SPIFFE Workload API (e.g. unix:///run/spire/sockets/agent.sock). "+
+			"If set, the certificates used between x-pdb servers are obtained from the Workload API "+
+			"instead of --controller-certs-dir.",
+	)
+	flag.StringVar(&peersConfig, "peers-config", "",
+		"Path to the file that maps remote client certificates to cluster ids and allowed namespaces. "+
+			"If not set, any client presenting a valid certificate is trusted.",
+	)
 	flag.StringVar(&leaseNamespace, "namespace", "kube-system", "the namespace in which the controller runs in")
 	flag.StringVar(&podID, "pod-id", os.Getenv("HOSTNAME"),
 		"The ID of the pod x-pdb pod. Used as prefix for the lease-holder-identity to obtain locks across clusters.",
@@ -95,6 +165,112 @@ func main() {
 	flag.BoolVar(&dryRun, "dry-run", false,
 		"run the admission controller in dry-run mode, which never rejects a voluntary disruption",
 	)
+	flag.BoolVar(&selfSignedCerts, "self-signed-certs", false,
+		"Bootstrap a CA and issue the webhook and controller certificates without cert-manager. "+
+			"Certificates are written to --webhook-certs-dir and --controller-certs-dir and renewed before they expire.",
+	)
+	flag.StringVar(&certsNamespace, "certs-namespace", "",
+		"The namespace that holds the CA secret, the published CA and certificate requests. Defaults to --namespace.",
+	)
+	flag.StringVar(&caSecretName, "ca-secret-name", "x-pdb-ca",
+		"The name of the secret holding the CA. The CA is published in a ConfigMap of the same name.",
+	)
+	flag.StringVar(&trustedCAsConfigMap, "trusted-cas-configmap", "",
+		"The name of the ConfigMap holding the PEM encoded CAs of the remote clusters.",
+	)
+	flag.StringVar(&webhookConfigurationName, "webhook-configuration-name", "",
+		"The name of the ValidatingWebhookConfiguration the CA bundle is injected into.",
+	)
+	flag.StringVar(&webhookDNSNames, "webhook-dns-names", "", "The list of DNS names of the webhook certificate")
+	flag.StringVar(&controllerDNSNames, "controller-dns-names", "", "The list of DNS names of the controller certificate")
+	flag.DurationVar(&caValidity, "ca-validity", 26280*time.Hour, "The validity of the CA")
+	flag.DurationVar(&certValidity, "cert-validity", 2000*time.Hour, "The validity of the issued certificates")
+	flag.DurationVar(&certRenewBefore, "cert-renew-before", 1000*time.Hour,
+		"How long before expiry the issued certificates are renewed",
+	)
+	flag.StringVar(&probeProxyURL, "disruption-probe-proxy-url", "",
+		"The egress proxy used to reach disruption probes, e.g. http://user@proxy:3128 or socks5://proxy:1080",
+	)
+	flag.StringVar(&probeProxyPasswordFile, "disruption-probe-proxy-password-file", "",
+		"The file holding the password of the user set in --disruption-probe-proxy-url",
+	)
+	flag.DurationVar(&probeClientIdleTimeout, "disruption-probe-client-idle-timeout", 5*time.Minute,
+		"Connections to disruption probes which haven't been used for this duration are closed. Disabled if 0.",
+	)
+	flag.StringVar(&serviceAccountName, "service-account-name", "",
+		"The service account x-pdb runs as. Disruption probes can authenticate x-pdb with tokens of this service account.",
+	)
+	flag.StringVar(&probeTokenAudiences, "disruption-probe-token-audiences", "",
+		"Comma separated list of the audiences of service account tokens disruption probes may request. "+
+			"Service account tokens are disabled if empty.",
+	)
+	flag.BoolVar(&probeSecrets, "disruption-probe-secrets", false,
+		"Allows disruption probes to reference Secrets. "+
+			"Requires x-pdb to be granted access to all Secrets of their namespaces.",
+	)
+	flag.StringVar(&probeSecretNamespaces, "disruption-probe-secret-namespaces", "",
+		"Comma separated list of the namespaces disruption probes may reference Secrets in. All namespaces if empty.",
+	)
+	flag.DurationVar(&probeCacheTTL, "disruption-probe-cache-ttl", 0,
+		"The time verdicts of disruption probes are cached per pod unless the probe sets a max-age, 0 disables caching")
+	flag.DurationVar(&peerMonitorInterval, "peer-monitor-interval", 10*time.Second,
+		"The interval in which the health of the remote x-pdb servers is checked",
+	)
+	flag.DurationVar(&readinessMinCertValidity, "readiness-min-cert-validity", time.Hour,
+		"The pod is not ready if the webhook or controller certificate expires within this duration",
+	)
+	flag.IntVar(&readinessRemoteQuorum, "readiness-remote-quorum", 0,
+		"The number of remotes which must have answered a health check within --readiness-remote-max-age "+
+			"for the pod to be ready. Disabled if 0.",
+	)
+	flag.DurationVar(&readinessRemoteMaxAge, "readiness-remote-max-age", 30*time.Second,
+		"The maximum age of the last successful health check of a remote to count towards --readiness-remote-quorum",
+	)
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "",
+		"The host:port of the OTLP gRPC collector traces are exported to. Tracing is disabled if empty.",
+	)
+	flag.BoolVar(&otlpInsecure, "otlp-insecure", false, "Connect to the OTLP collector without TLS")
+	flag.Float64Var(&traceSampleRatio, "trace-sample-ratio", 1,
+		"The ratio of admission requests which are traced. "+
+			"Requests of remote clusters keep the sampling decision of the caller.",
+	)
+	flag.BoolVar(&auditStdout, "audit-stdout", false, "Write an audit record of every admission request to stdout as JSON")
+	flag.StringVar(&auditFile, "audit-file", "", "The file audit records are written to as JSON lines")
+	flag.Int64Var(&auditFileMaxSize, "audit-file-max-size", 100*1024*1024,
+		"The size in bytes after which the audit file is rotated",
+	)
+	flag.IntVar(&auditFileMaxBackups, "audit-file-max-backups", 5, "The number of rotated audit files to keep")
+	flag.StringVar(&auditWebhookURL, "audit-webhook-url", "", "The URL batches of audit records are POSTed to")
+	flag.IntVar(&auditWebhookBatchSize, "audit-webhook-batch-size", 100,
+		"The maximum number of audit records sent in one request",
+	)
+	flag.DurationVar(&auditWebhookFlushInterval, "audit-webhook-flush-interval", 5*time.Second,
+		"The maximum time an audit record is buffered before it is sent to the audit webhook",
+	)
+	flag.IntVar(&auditWebhookMaxRetries, "audit-webhook-max-retries", 5,
+		"The number of times a batch of audit records is retried before it is dropped",
+	)
+	flag.StringVar(&multipleXPDBPolicy, "multiple-xpdb-policy", string(webhooks.MultipleXPDBPolicyReject),
+		"How disruptions of pods matching multiple XPDBs are handled, one of Reject or AllMustAllow",
+	)
+	flag.DurationVar(&disruptionCooldown, "disruption-cooldown", 10*time.Second,
+		"The time after which denied disruptions should be retried, unless the lease or a probe suggest a delay",
+	)
+	flag.BoolVar(&preActivitiesEnabled, "pre-activities-enabled", true,
+		"Whether pending pre-activities block disruptions of pods protected by XPDBs which don't configure it",
+	)
+	flag.DurationVar(&preActivityTimeout, "pre-activity-timeout", 0,
+		"The time pre-activities have to complete, 0 disables the timeout",
+	)
+	flag.StringVar(&preActivityTimeoutPolicy, "pre-activity-timeout-policy", string(preactivities.TimeoutPolicyBlock),
+		"How pre-activities which didn't complete within the timeout are handled, one of Block or AllowAfterTimeout",
+	)
+	flag.BoolVar(&disruptionRequests, "disruption-requests", false,
+		"Record evictions blocked by pre-activities and evict the pods once the pre-activities completed",
+	)
+	flag.DurationVar(&disruptionRequestTTL, "disruption-request-ttl", time.Hour,
+		"The time DisruptionRequests are pending before they expire and kept after they finished",
+	)
 	opts := zap.Options{
 		Development: true,
 	}
@@ -104,15 +280,60 @@ func main() {
 	logger := zap.New(zap.UseFlagOptions(&opts))
 	ctrl.SetLogger(logger)
 
+	switch webhooks.MultipleXPDBPolicy(multipleXPDBPolicy) {
+	case webhooks.MultipleXPDBPolicyReject, webhooks.MultipleXPDBPolicyAllMustAllow:
+	default:
+		setupLog.Error(fmt.Errorf("unknown policy %q", multipleXPDBPolicy), "invalid --multiple-xpdb-policy")
+		os.Exit(1)
+	}
+
+	switch preactivities.TimeoutPolicy(preActivityTimeoutPolicy) {
+	case preactivities.TimeoutPolicyBlock, preactivities.TimeoutPolicyAllowAfterTimeout:
+	default:
+		setupLog.Error(fmt.Errorf("unknown policy %q", preActivityTimeoutPolicy), "invalid --pre-activity-timeout-policy")
+		os.Exit(1)
+	}
+
+	if disruptionCooldown <= 0 {
+		setupLog.Error(fmt.Errorf("cooldown %s is not positive", disruptionCooldown), "invalid --disruption-cooldown")
+		os.Exit(1)
+	}
+	if auditWebhookFlushInterval <= 0 {
+		setupLog.Error(fmt.Errorf("interval %s is not positive", auditWebhookFlushInterval),
+			"invalid --audit-webhook-flush-interval")
+		os.Exit(1)
+	}
+	if auditWebhookBatchSize < 1 {
+		setupLog.Error(fmt.Errorf("batch size %d is not positive", auditWebhookBatchSize),
+			"invalid --audit-webhook-batch-size")
+		os.Exit(1)
+	}
+
 	cfg, err := config.GetConfigWithContext(kubeContext)
 	if err != nil {
 		setupLog.Error(err, "unable to get kubernetes config")
 		os.Exit(1)
 	}
+	probeSecretAccess := disruptionprobe.SecretAccess{Enabled: probeSecrets, Namespaces: splitList(probeSecretNamespaces)}
+	// only the secrets referenced by disruption probes are watched.
+	probeSecretsCache := cache.ByObject{
+		Label: labels.SelectorFromSet(labels.Set{disruptionprobe.CredentialsSecretLabel: "true"}),
+	}
+	if len(probeSecretAccess.Namespaces) > 0 {
+		probeSecretsCache.Namespaces = map[string]cache.Config{}
+		for _, ns := range probeSecretAccess.Namespaces {
+			probeSecretsCache.Namespaces[ns] = cache.Config{}
+		}
+	}
 	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
 		HealthProbeBindAddress: probeAddr,
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.Secret{}: probeSecretsCache,
+			},
+		},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -121,19 +342,97 @@ func main() {
 
 	signalHandler := ctrl.SetupSignalHandler()
 
+	shutdownTracing, err := tracing.Setup(signalHandler, tracing.Options{
+		Endpoint:    otlpEndpoint,
+		Insecure:    otlpInsecure,
+		SampleRatio: traceSampleRatio,
+		ClusterID:   clusterID,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			setupLog.Error(err, "unable to flush traces")
+		}
+	}()
+
 	cli, err := kubernetes.NewForConfig(mgr.GetConfig())
 	if err != nil {
 		setupLog.Error(err, "unable to create kubernetes client")
 		os.Exit(1)
 	}
 
-	remoteEndpointsList, err := parseEndpoints(remoteEndpoints)
+	var peers *stateserver.PeersConfig
+	if peersConfig != "" {
+		peers, err = stateserver.LoadPeersConfig(peersConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to load peers config")
+			os.Exit(1)
+		}
+	}
+
+	if selfSignedCerts {
+		if certsNamespace == "" {
+			certsNamespace = leaseNamespace
+		}
+
+		uncachedClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+		if err != nil {
+			setupLog.Error(err, "unable to create kubernetes client")
+			os.Exit(1)
+		}
+
+		certManager := certs.NewManager(uncachedClient, logger, certs.Options{
+			Namespace:                certsNamespace,
+			CASecretName:             caSecretName,
+			TrustedCAsConfigMapName:  trustedCAsConfigMap,
+			WebhookConfigurationName: webhookConfigurationName,
+			WebhookDNSNames:          splitList(webhookDNSNames),
+			WebhookCertsDir:          webhookCertsDir,
+			ControllerDNSNames:       splitList(controllerDNSNames),
+			ControllerCertsDir:       controllerCertsDir,
+			CAValidity:               caValidity,
+			CertValidity:             certValidity,
+			RenewBefore:              certRenewBefore,
+			CheckInterval:            10 * time.Minute,
+			PeerIdentities:           peers.Identities(),
+		})
+
+		// the webhook and state servers read the certificates when they start.
+		if err := certManager.Bootstrap(signalHandler); err != nil {
+			setupLog.Error(err, "unable to bootstrap certificates")
+			os.Exit(1)
+		}
+		if err := mgr.Add(certManager); err != nil {
+			setupLog.Error(err, "unable to create certificate manager")
+			os.Exit(1)
+		}
+	}
+
+	remotesConfig, err := loadRemotesConfig(remoteEndpoints, remotesConfigPath)
 	if err != nil {
-		setupLog.Error(err, "unable to parse remote endpoints")
+		setupLog.Error(err, "unable to load remotes configuration")
 		os.Exit(1)
 	}
+	remoteEndpointsList := remotesConfig.Endpoints()
+
+	var tlsProvider mtls.Provider
+	if spiffeEndpointSocket != "" {
+		setupLog.Info("waiting for the spiffe workload api to provide a svid", "socket", spiffeEndpointSocket)
+		tlsProvider, err = mtls.NewSPIFFEProvider(signalHandler, spiffeEndpointSocket, remotesConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to connect to spiffe workload api")
+			os.Exit(1)
+		}
+	} else {
+		tlsProvider = mtls.NewFileProvider(signalHandler, &logger, controllerCertsDir, remotesConfig)
+	}
 
-	stateClientPool := stateclient.NewClientPool(signalHandler, &logger, controllerCertsDir)
+	stateClientPool := stateclient.NewClientPool(&logger, tlsProvider, remotesConfig)
 
 	lockService := lock.NewService(
 		&logger,
@@ -141,11 +440,41 @@ func main() {
 		mgr.GetAPIReader(),
 		stateClientPool,
 		leaseNamespace,
-		remoteEndpointsList,
+		remotesConfig,
 	)
 
-	disruptionProbeClientPool := disruptionprobe.NewClientPool(signalHandler, &logger, controllerCertsDir)
-	disruptionProbeService := disruptionprobe.NewService(&logger, disruptionProbeClientPool)
+	var probeProxy *proxy.Config
+	if probeProxyURL != "" {
+		probeProxy = &proxy.Config{URL: probeProxyURL, PasswordFile: probeProxyPasswordFile}
+		if err := probeProxy.Validate(); err != nil {
+			setupLog.Error(err, "invalid disruption probe proxy")
+			os.Exit(1)
+		}
+	}
+
+	probeCredentials := disruptionprobe.NewCredentials(
+		mgr.GetClient(),
+		types.NamespacedName{Namespace: leaseNamespace, Name: serviceAccountName},
+		splitList(probeTokenAudiences),
+		probeSecretAccess,
+	)
+	disruptionProbeClientPool := disruptionprobe.NewClientPool(
+		signalHandler,
+		&logger,
+		controllerCertsDir,
+		probeProxy,
+		probeClientIdleTimeout,
+		probeCredentials,
+	)
+	if err := mgr.Add(disruptionProbeClientPool); err != nil {
+		setupLog.Error(err, "unable to add disruption probe client pool")
+		os.Exit(1)
+	}
+	disruptionProbeService := disruptionprobe.NewService(&logger,
+		disruptionProbeClientPool,
+		probeCredentials,
+		clusterID,
+		probeCacheTTL)
 
 	scaleFinder := pdb.NewScaleFinder(mgr.GetClient(), cli.DiscoveryClient)
 	pdbService := pdb.NewService(logger,
@@ -154,9 +483,63 @@ func main() {
 		scaleFinder,
 		stateClientPool,
 		leaseNamespace,
-		remoteEndpointsList)
+		remotesConfig)
+
+	preactivitiesService := preactivities.NewService(logger, mgr.GetClient(), preactivities.Options{
+		DisabledByDefault: !preActivitiesEnabled,
+		Timeout:           preActivityTimeout,
+		TimeoutPolicy:     preactivities.TimeoutPolicy(preActivityTimeoutPolicy),
+	})
+	preactivitiesController := preactivities.NewController(logger,
+		mgr.GetClient(),
+		mgr.GetEventRecorderFor("x-pdb"),
+		preactivitiesService)
+	if err := preactivitiesController.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create pre-activities controller")
+		os.Exit(1)
+	}
 
-	preactivitiesService := preactivities.NewService(logger, mgr.GetClient())
+	var disruptionRequestService *disruptionrequests.Service
+	if disruptionRequests {
+		disruptionRequestService = disruptionrequests.NewService(logger, mgr.GetClient(), disruptionRequestTTL)
+		disruptionRequestController := disruptionrequests.NewController(logger,
+			mgr.GetClient(),
+			preactivitiesService,
+			disruptionRequestTTL)
+		if err := disruptionRequestController.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create disruption requests controller")
+			os.Exit(1)
+		}
+	}
+
+	var auditSinks []audit.Sink
+	if auditStdout {
+		auditSinks = append(auditSinks, audit.NewWriterSink(os.Stdout))
+	}
+	var fileSink *audit.FileSink
+	if auditFile != "" {
+		fileSink, err = audit.NewFileSink(auditFile, auditFileMaxSize, auditFileMaxBackups)
+		if err != nil {
+			setupLog.Error(err, "unable to create audit file sink")
+			os.Exit(1)
+		}
+		auditSinks = append(auditSinks, fileSink)
+	}
+	if auditWebhookURL != "" {
+		httpSink := audit.NewHTTPSink(logger, audit.HTTPSinkOptions{
+			URL:           auditWebhookURL,
+			BatchSize:     auditWebhookBatchSize,
+			FlushInterval: auditWebhookFlushInterval,
+			MaxRetries:    auditWebhookMaxRetries,
+			BufferSize:    10 * auditWebhookBatchSize,
+		})
+		if err := mgr.Add(httpSink); err != nil {
+			setupLog.Error(err, "unable to create audit webhook sink")
+			os.Exit(1)
+		}
+		auditSinks = append(auditSinks, httpSink)
+	}
+	auditor := audit.NewAuditor(logger, auditSinks...)
 
 	{
 		hookServer := &webhook.DefaultServer{
@@ -182,12 +565,68 @@ func main() {
 			lockService,
 			disruptionProbeService,
 			preactivitiesService,
+			disruptionRequestService,
+			auditor,
+			webhooks.MultipleXPDBPolicy(multipleXPDBPolicy),
+			disruptionCooldown,
 		)
 		hookServer.Register("/validate", &webhook.Admission{Handler: podValidationWebhook})
 	}
 
+	peerMonitor := stateclient.NewPeerMonitor(logger, stateClientPool, remoteEndpointsList, peerMonitorInterval)
+	if err := mgr.Add(peerMonitor); err != nil {
+		setupLog.Error(err, "unable to create peer monitor")
+		os.Exit(1)
+	}
+
+	// the readiness is reported to the remotes through the grpc health service,
+	// hence it must not depend on the remotes to avoid clusters waiting for each other.
+	readiness := health.NewChecker(logger, "readiness", 5*time.Second, statepb.StateService_ServiceDesc.ServiceName)
+	readiness.AddCheck("informer-caches", health.CacheSyncCheck(mgr.GetCache()))
+	readiness.AddCheck("api-server", health.APIServerCheck(cli.Discovery().RESTClient()))
+	readiness.AddCheck("controller-certificate", health.CertificatesCheck(tlsProvider))
+	liveness := health.NewChecker(logger, "liveness", 10*time.Second)
+	liveness.AddCheck("controller-certificate", health.CertificatesCheck(tlsProvider))
+	if webhookCertsDir != "" {
+		readiness.AddCheck("webhook-certificate", health.CertificateFilesCheck(webhookCertsDir, readinessMinCertValidity))
+		liveness.AddCheck("webhook-certificate", health.CertificateFilesCheck(webhookCertsDir, 0))
+	}
+	if spiffeEndpointSocket == "" && controllerCertsDir != "" {
+		readiness.AddCheck("controller-certificate-expiry",
+			health.CertificateFilesCheck(controllerCertsDir, readinessMinCertValidity))
+	}
+	checkers := []*health.Checker{liveness, readiness}
+
+	if readinessRemoteQuorum > 0 {
+		remotesReadiness := health.NewChecker(logger, "remotes", 5*time.Second)
+		remotesReadiness.AddCheck("remote-quorum", func(_ context.Context) error {
+			return peerMonitor.CheckQuorum(readinessRemoteQuorum, readinessRemoteMaxAge)
+		})
+		checkers = append(checkers, remotesReadiness)
+	}
+
+	for _, c := range checkers {
+		if err := mgr.Add(c); err != nil {
+			setupLog.Error(err, "unable to create health checker")
+			os.Exit(1)
+		}
+	}
+
 	{
-		stateServer := stateserver.NewServer(pdbService, lockService, &logger, controllerPort, controllerCertsDir)
+		var authorizer *stateserver.Authorizer
+		if peers != nil {
+			authorizer = stateserver.NewAuthorizer(&logger, peers)
+		}
+
+		stateServer := stateserver.NewServer(
+			pdbService,
+			lockService,
+			readiness.HealthServer(),
+			authorizer,
+			tlsProvider,
+			&logger,
+			controllerPort,
+		)
 		if err := mgr.Add(stateServer); err != nil {
 			setupLog.Error(err, "unable to create state server")
 			os.Exit(1)
@@ -195,22 +634,62 @@ func main() {
 	}
 
 	// +kubebuilder:scaffold:builder
-	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+	if err := mgr.AddHealthzCheck(liveness.Name(), liveness.Healthz); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up ready check")
+	for _, c := range checkers[1:] {
+		if err := mgr.AddReadyzCheck(c.Name(), c.Healthz); err != nil {
+			setupLog.Error(err, "unable to set up ready check")
+			os.Exit(1)
+		}
+	}
+	peersHandler := health.NewDebugHandler(func() any { return peerMonitor.Peers() }, checkers...)
+	if err := mgr.AddMetricsServerExtraHandler("/debug/peers", peersHandler); err != nil {
+		setupLog.Error(err, "unable to set up peers debug handler")
 		os.Exit(1)
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(signalHandler); err != nil {
+	err = mgr.Start(signalHandler)
+	// the webhook server has stopped, no more records are written.
+	if fileSink != nil {
+		if err := fileSink.Close(); err != nil {
+			setupLog.Error(err, "unable to close audit file")
+		}
+	}
+	if err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
 
+func loadRemotesConfig(endpointString, configPath string) (*remotes.Config, error) {
+	endpoints, err := parseEndpoints(endpointString)
+	if err != nil {
+		return nil, err
+	}
+
+	if configPath == "" {
+		return remotes.NewConfigFromEndpoints(endpoints), nil
+	}
+
+	if len(endpoints) > 0 {
+		return nil, errors.New("--remote-endpoints and --remotes-config are mutually exclusive")
+	}
+	return remotes.LoadConfig(configPath)
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func parseEndpoints(endpointString string) ([]string, error) {
 	//nolint:prealloc
 	var endpoints []string
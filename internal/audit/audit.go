@@ -0,0 +1,121 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+// Record describes the decision taken on a single admission request.
+type Record struct {
+	Time time.Time `json:"time"`
+	// UID is the uid of the admission request.
+	UID       string `json:"uid"`
+	ClusterID string `json:"clusterID"`
+	Operation string `json:"operation"`
+	// SubResource is set for evictions.
+	SubResource string                    `json:"subResource,omitempty"`
+	DryRun      bool                      `json:"dryRun"`
+	User        authenticationv1.UserInfo `json:"user"`
+	Pod         ObjectReference           `json:"pod"`
+	// XPDB is the XPDB which decided the request. If the pod matches multiple XPDBs
+	// which all allowed the disruption, it is the last one evaluated.
+	XPDB *ObjectReference `json:"xpdb,omitempty"`
+	// MatchedXPDBs lists all XPDBs of the pod if it matches more than one.
+	MatchedXPDBs []ObjectReference `json:"matchedXPDBs,omitempty"`
+	Budget       *Budget           `json:"budget,omitempty"`
+	Probe        *ProbeResult      `json:"probe,omitempty"`
+	// LeaseHolderIdentity is the identity used to lock the XPDB.
+	LeaseHolderIdentity string `json:"leaseHolderIdentity,omitempty"`
+	// Verdict is either allowed or denied.
+	Verdict string `json:"verdict"`
+	// Reason names the component which decided the request.
+	Reason     string `json:"reason"`
+	Message    string `json:"message,omitempty"`
+	StatusCode int32  `json:"statusCode,omitempty"`
+	// RetryAfterSeconds is the estimated time until a denied disruption could be allowed.
+	RetryAfterSeconds int     `json:"retryAfterSeconds,omitempty"`
+	LatencySeconds    float64 `json:"latencySeconds"`
+}
+
+// ObjectReference identifies a namespaced object.
+type ObjectReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Budget holds the pod counts the disruption budget was evaluated with.
+type Budget struct {
+	Expected       int32           `json:"expected"`
+	Healthy        int32           `json:"healthy"`
+	DesiredHealthy int32           `json:"desiredHealthy"`
+	Allowed        bool            `json:"allowed"`
+	Clusters       []ClusterCounts `json:"clusters"`
+}
+
+// ClusterCounts are the pod counts of a single cluster.
+type ClusterCounts struct {
+	Cluster  string `json:"cluster"`
+	Expected int32  `json:"expected"`
+	Healthy  int32  `json:"healthy"`
+}
+
+// ProbeResult is the combined response of the disruption probes.
+type ProbeResult struct {
+	// Name and Endpoint identify the probe which blocked the disruption or failed.
+	Name     string `json:"name,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Allowed  bool   `json:"allowed"`
+	// Reason and Message explain the verdict of probes speaking protocol v2.
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// PreActivities are the names of the pre-activities requested by the probes.
+	PreActivities []string `json:"preActivities,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// Sink stores audit records.
+// Write is called while the admission request is in flight and must not block for long.
+type Sink interface {
+	Write(rec *Record) error
+}
+
+// Auditor sends audit records to all configured sinks.
+type Auditor struct {
+	logger logr.Logger
+	sinks  []Sink
+}
+
+// NewAuditor creates a new Auditor writing to the supplied sinks.
+func NewAuditor(logger logr.Logger, sinks ...Sink) *Auditor {
+	return &Auditor{
+		logger: logger.WithName("audit"),
+		sinks:  sinks,
+	}
+}
+
+// Record writes the record to every sink. Failing sinks are logged.
+func (a *Auditor) Record(rec *Record) {
+	for _, s := range a.sinks {
+		if err := s.Write(rec); err != nil {
+			a.logger.Error(err, "unable to write audit record", "uid", rec.UID)
+		}
+	}
+}
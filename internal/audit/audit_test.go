@@ -0,0 +1,183 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRecord(uid string) *Record {
+	return &Record{
+		UID:     uid,
+		Pod:     ObjectReference{Namespace: "default", Name: "pod-1"},
+		Verdict: "denied",
+		Reason:  "budget",
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	a := NewAuditor(logr.Discard(), NewWriterSink(&buf))
+	a.Record(testRecord("1"))
+	a.Record(testRecord("2"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var rec Record
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &rec))
+	assert.Equal(t, "2", rec.UID)
+	assert.Equal(t, "budget", rec.Reason)
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	line, err := json.Marshal(testRecord("0"))
+	require.NoError(t, err)
+
+	// every file holds two records.
+	s, err := NewFileSink(path, int64(2*(len(line)+1)), 2)
+	require.NoError(t, err)
+	for _, uid := range []string{"1", "2", "3", "4", "5", "6", "7"} {
+		require.NoError(t, s.Write(testRecord(uid)))
+	}
+	require.NoError(t, s.Close())
+
+	readUIDs := func(name string) []string {
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		var uids []string
+		for _, l := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var rec Record
+			require.NoError(t, json.Unmarshal([]byte(l), &rec))
+			uids = append(uids, rec.UID)
+		}
+		return uids
+	}
+
+	assert.Equal(t, []string{"7"}, readUIDs(path))
+	assert.Equal(t, []string{"5", "6"}, readUIDs(path+".1"))
+	assert.Equal(t, []string{"3", "4"}, readUIDs(path+".2"))
+	assert.NoFileExists(t, path+".3")
+}
+
+func TestFileSinkRotationFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	line, err := json.Marshal(testRecord("0"))
+	require.NoError(t, err)
+
+	s, err := NewFileSink(path, int64(len(line)+1), 1)
+	require.NoError(t, err)
+	require.NoError(t, s.Write(testRecord("1")))
+
+	// a directory in place of the backup makes the rotation fail.
+	require.NoError(t, os.MkdirAll(filepath.Join(path+".1", "blocked"), 0o700))
+	err = s.Write(testRecord("2"))
+	assert.ErrorContains(t, err, "unable to rotate audit file")
+
+	// the records are still written and rotating succeeds once possible.
+	require.NoError(t, os.RemoveAll(path+".1"))
+	require.NoError(t, s.Write(testRecord("3")))
+	require.NoError(t, s.Close())
+	assert.Error(t, s.Write(testRecord("4")), "records written after close should be rejected")
+
+	data, err := os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), "\n"))
+}
+
+func TestHTTPSink(t *testing.T) {
+	httpRetryBackoff = time.Millisecond
+
+	var mux sync.Mutex
+	var batches [][]Record
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		requests++
+		// fail every other request to exercise the retries.
+		if requests%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch []Record
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batches = append(batches, batch)
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSink(logr.Discard(), HTTPSinkOptions{
+		URL:           srv.URL,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		MaxRetries:    3,
+		BufferSize:    10,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = s.Start(ctx)
+		close(done)
+	}()
+
+	for _, uid := range []string{"1", "2", "3"} {
+		require.NoError(t, s.Write(testRecord(uid)))
+	}
+
+	// the first batch is full, the second one is flushed on shutdown.
+	require.Eventually(t, func() bool {
+		mux.Lock()
+		defer mux.Unlock()
+		return len(batches) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	mux.Lock()
+	defer mux.Unlock()
+	require.Len(t, batches, 2)
+	assert.Equal(t, "1", batches[0][0].UID)
+	assert.Equal(t, "2", batches[0][1].UID)
+	require.Len(t, batches[1], 1)
+	assert.Equal(t, "3", batches[1][0].UID)
+	assert.Equal(t, 4, requests)
+}
+
+func TestHTTPSinkClientError(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSink(logr.Discard(), HTTPSinkOptions{URL: srv.URL, MaxRetries: 3})
+	assert.Error(t, s.send(context.Background(), []*Record{testRecord("1")}))
+	assert.Equal(t, 1, requests, "client errors must not be retried")
+}
+
+func TestHTTPSinkBufferFull(t *testing.T) {
+	s := NewHTTPSink(logr.Discard(), HTTPSinkOptions{BufferSize: 1})
+	assert.NoError(t, s.Write(testRecord("1")))
+	assert.Error(t, s.Write(testRecord("2")))
+}
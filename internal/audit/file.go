@@ -0,0 +1,144 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileSink writes every record as a line of JSON to a file.
+// The file is rotated once it exceeds maxSize bytes, keeping maxBackups
+// rotated files named <path>.1 (the most recent) to <path>.<maxBackups>.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mux    sync.Mutex
+	file   *os.File
+	size   int64
+	closed bool
+}
+
+// NewFileSink opens the file at path for appending.
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write implements Sink.
+func (s *FileSink) Write(rec *Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return errors.New("audit file is closed")
+	}
+
+	var rotateErr error
+	if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			// the record is still written, rotating is attempted again with the next record.
+			rotateErr = fmt.Errorf("unable to rotate audit file: %w", err)
+		}
+	}
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return errors.Join(rotateErr, err)
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return errors.Join(rotateErr, err)
+}
+
+// Close closes the file, records written afterwards are rejected.
+func (s *FileSink) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.closed = true
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *FileSink) open() error {
+	//nolint:gosec
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open audit file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("unable to open audit file: %w", err)
+	}
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+// rotate closes the file and moves it to the first backup.
+// The file is reopened by the caller, even if rotating failed.
+func (s *FileSink) rotate() error {
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return err
+	}
+
+	if s.maxBackups < 1 {
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		err := os.Rename(s.backupName(i), s.backupName(i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(s.path, s.backupName(1))
+}
+
+func (s *FileSink) backupName(i int) string {
+	return fmt.Sprintf("%s.%d", s.path, i)
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+var (
+	httpRequestTimeout = 10 * time.Second
+	httpRetryBackoff   = 500 * time.Millisecond
+	httpShutdownFlush  = 5 * time.Second
+)
+
+// HTTPSinkOptions configures a HTTPSink.
+type HTTPSinkOptions struct {
+	// URL the batches are POSTed to as JSON array.
+	URL string
+	// BatchSize is the maximum number of records sent in a single request.
+	BatchSize int
+	// FlushInterval is the maximum time a record is buffered before it is sent.
+	FlushInterval time.Duration
+	// MaxRetries is the number of times a failed batch is sent again before it is dropped.
+	MaxRetries int
+	// BufferSize is the number of records buffered while batches are sent.
+	// Records are dropped once the buffer is full.
+	BufferSize int
+}
+
+// HTTPSink sends records in batches to a HTTP endpoint.
+// Failed batches are retried with an exponential backoff,
+// unless the endpoint rejected them with a client error.
+type HTTPSink struct {
+	logger  logr.Logger
+	client  *http.Client
+	opts    HTTPSinkOptions
+	records chan *Record
+}
+
+// NewHTTPSink creates a new HTTPSink. Records are sent once the sink is started.
+func NewHTTPSink(logger logr.Logger, opts HTTPSinkOptions) *HTTPSink {
+	return &HTTPSink{
+		logger:  logger.WithName("audit-http"),
+		client:  &http.Client{Timeout: httpRequestTimeout},
+		opts:    opts,
+		records: make(chan *Record, opts.BufferSize),
+	}
+}
+
+// Write implements Sink. It only buffers the record.
+func (s *HTTPSink) Write(rec *Record) error {
+	select {
+	case s.records <- rec:
+		return nil
+	default:
+		return errors.New("audit webhook buffer is full, dropping record")
+	}
+}
+
+// Start sends the buffered records until the context is cancelled.
+// Remaining records are flushed on shutdown.
+func (s *HTTPSink) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Record, 0, s.opts.BatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), httpShutdownFlush)
+			defer cancel()
+			s.drain(flushCtx, batch)
+			return nil
+		case rec := <-s.records:
+			batch = append(batch, rec)
+			if len(batch) >= s.opts.BatchSize {
+				batch = s.flush(ctx, batch)
+			}
+		case <-ticker.C:
+			batch = s.flush(ctx, batch)
+		}
+	}
+}
+
+// drain sends the batch and all buffered records.
+func (s *HTTPSink) drain(ctx context.Context, batch []*Record) {
+	for {
+		select {
+		case rec := <-s.records:
+			batch = append(batch, rec)
+			if len(batch) >= s.opts.BatchSize {
+				batch = s.flush(ctx, batch)
+			}
+		default:
+			s.flush(ctx, batch)
+			return
+		}
+	}
+}
+
+// flush sends the batch and returns it emptied.
+func (s *HTTPSink) flush(ctx context.Context, batch []*Record) []*Record {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := s.send(ctx, batch); err != nil {
+		s.logger.Error(err, "dropping audit records", "count", len(batch))
+	}
+	return batch[:0]
+}
+
+func (s *HTTPSink) send(ctx context.Context, batch []*Record) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	backoff := httpRetryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.opts.MaxRetries {
+			return err
+		}
+
+		s.logger.V(1).Info("sending audit records failed, retrying", "error", err.Error(), "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post sends the body and reports whether a failed request should be retried.
+func (s *HTTPSink) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("audit webhook responded with %s", resp.Status)
+	default:
+		return false, fmt.Errorf("audit webhook responded with %s", resp.Status)
+	}
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// WriterSink writes every record as a line of JSON, e.g. to stdout.
+type WriterSink struct {
+	mux sync.Mutex
+	enc *json.Encoder
+}
+
+// NewWriterSink creates a new WriterSink.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{enc: json.NewEncoder(w)}
+}
+
+// Write implements Sink.
+func (s *WriterSink) Write(rec *Record) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.enc.Encode(rec)
+}
@@ -0,0 +1,509 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/form3tech-oss/x-pdb/internal/metrics"
+	"github.com/go-logr/logr"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// CertificateRequestSecretType is the type of secrets holding a certificate signing request
+	// of a remote cluster. The manager signs the PEM encoded request stored in the `tls.csr`
+	// key and stores the certificate and the CA bundle in the `tls.crt` and `ca.crt` keys.
+	CertificateRequestSecretType corev1.SecretType = "x-pdb.form3.tech/certificate-request"
+
+	// CertificateRequestKey is the secret key holding the PEM encoded certificate signing request.
+	CertificateRequestKey = "tls.csr"
+
+	// CertificateRequestApprovedAnnotation approves a certificate request for the peer with the cluster id
+	// of its value. Requests are only signed once approved and may only claim the identities of the peer.
+	CertificateRequestApprovedAnnotation = "x-pdb.form3.tech/approved-for-cluster"
+
+	certificateNameCA         = "ca"
+	certificateNameWebhook    = "webhook"
+	certificateNameController = "controller"
+)
+
+// Options configures the certificate Manager.
+type Options struct {
+	// Namespace in which the CA secret, the published CA and the certificate requests are stored.
+	Namespace string
+	// CASecretName is the name of the secret holding the CA shared by all x-pdb replicas.
+	// The CA certificate is published in a ConfigMap of the same name to be imported by remote clusters.
+	CASecretName string
+	// TrustedCAsConfigMapName is the name of a ConfigMap holding the PEM encoded CAs of
+	// remote clusters which are trusted by the state server and client.
+	TrustedCAsConfigMapName string
+	// WebhookConfigurationName is the name of the ValidatingWebhookConfiguration whose caBundle is kept up to date.
+	WebhookConfigurationName string
+	// WebhookDNSNames are the DNS names of the webhook serving certificate.
+	WebhookDNSNames []string
+	// WebhookCertsDir is the directory the webhook certificate is written to.
+	WebhookCertsDir string
+	// ControllerDNSNames are the DNS names of the state server certificate.
+	ControllerDNSNames []string
+	// ControllerCertsDir is the directory the state server certificate and trusted CAs are written to.
+	ControllerCertsDir string
+	// CAValidity is the validity of the CA.
+	CAValidity time.Duration
+	// CertValidity is the validity of the webhook and state server certificates.
+	CertValidity time.Duration
+	// RenewBefore defines how long before expiry certificates are renewed.
+	// The CA is renewed once less than a third of its validity is left.
+	RenewBefore time.Duration
+	// CheckInterval is the interval in which certificates are checked for renewal.
+	CheckInterval time.Duration
+	// PeerIdentities are the DNS and URI SANs of the remote clusters by cluster id.
+	// Approved certificate requests may only claim the identities of their cluster, none are signed if empty.
+	PeerIdentities map[string][]string
+}
+
+// Manager bootstraps a CA and issues the webhook and state server certificates
+// without relying on cert-manager. Certificates are renewed before they expire
+// and written to the certificate directories, where they are picked up by the
+// certificate watchers of the webhook and state servers.
+type Manager struct {
+	client client.Client
+	logger logr.Logger
+	opts   Options
+
+	ca             *ca
+	caBundle       []byte
+	webhookCert    *x509.Certificate
+	controllerCert *x509.Certificate
+}
+
+// NewManager creates a new certificate Manager.
+// The supplied client should not be backed by a cache as it reads secrets.
+func NewManager(cli client.Client, logger logr.Logger, opts Options) *Manager {
+	return &Manager{
+		client: cli,
+		logger: logger.WithName("certs"),
+		opts:   opts,
+	}
+}
+
+// Bootstrap makes sure the CA exists and all certificates are written to disk.
+// It must be called before the webhook and state servers are started.
+func (m *Manager) Bootstrap(ctx context.Context) error {
+	if err := m.issueCertificates(ctx); err != nil {
+		return err
+	}
+	// the webhook configuration may not exist yet during the first installation,
+	// publishing is retried periodically.
+	if err := m.publish(ctx); err != nil {
+		m.logger.Error(err, "unable to publish certificates")
+	}
+	return nil
+}
+
+// Start periodically renews the certificates until the context is cancelled.
+func (m *Manager) Start(ctx context.Context) error {
+	ticker := time.NewTicker(m.opts.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := m.issueCertificates(ctx); err != nil {
+				m.logger.Error(err, "unable to issue certificates")
+				continue
+			}
+			if err := m.publish(ctx); err != nil {
+				m.logger.Error(err, "unable to publish certificates")
+			}
+		}
+	}
+}
+
+// issueCertificates ensures the CA and writes the webhook and controller certificates.
+func (m *Manager) issueCertificates(ctx context.Context) error {
+	if err := m.ensureCA(ctx); err != nil {
+		return fmt.Errorf("unable to ensure ca: %w", err)
+	}
+
+	trustBundle, err := m.getTrustBundle(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get trusted cas: %w", err)
+	}
+
+	var errs []error
+	m.webhookCert, err = m.ensureCertificate(m.webhookCert, certificateNameWebhook,
+		m.opts.WebhookDNSNames, m.opts.WebhookCertsDir, m.caBundle)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("unable to ensure webhook certificate: %w", err))
+	}
+
+	m.controllerCert, err = m.ensureCertificate(m.controllerCert, certificateNameController,
+		m.opts.ControllerDNSNames, m.opts.ControllerCertsDir, trustBundle)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("unable to ensure controller certificate: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// publish injects the CA into the webhook configuration, publishes it for
+// remote clusters and signs their certificate requests.
+func (m *Manager) publish(ctx context.Context) error {
+	var errs []error
+	if err := m.patchWebhookConfiguration(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("unable to patch webhook configuration: %w", err))
+	}
+
+	if err := m.publishCA(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("unable to publish ca: %w", err))
+	}
+
+	if err := m.signCertificateRequests(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("unable to sign certificate requests: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ensureCA loads the CA from its secret, creating or renewing it if required.
+// Other replicas may create or renew the CA concurrently, hence conflicts are
+// resolved by reading the CA again during the next reconciliation.
+func (m *Manager) ensureCA(ctx context.Context) error {
+	var secret corev1.Secret
+	err := m.client.Get(ctx, client.ObjectKey{Namespace: m.opts.Namespace, Name: m.opts.CASecretName}, &secret)
+	if apierrors.IsNotFound(err) {
+		return m.createCA(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	current, err := parseCA(keyPair{cert: secret.Data[corev1.TLSCertKey], key: secret.Data[corev1.TLSPrivateKeyKey]})
+	if err != nil {
+		return err
+	}
+
+	if needsRenewal(current.cert, m.opts.CAValidity/3) {
+		m.logger.Info("renewing ca", "notAfter", current.cert.NotAfter)
+		next, err := newCA(current.cert.Subject.CommonName, m.opts.CAValidity)
+		if err != nil {
+			return err
+		}
+		// keep trusting the previous ca until all certificates issued by it are renewed.
+		bundle := append(append([]byte{}, next.pem.cert...), current.pem.cert...)
+		secret.Data[corev1.TLSCertKey] = next.pem.cert
+		secret.Data[corev1.TLSPrivateKeyKey] = next.pem.key
+		secret.Data[corev1.ServiceAccountRootCAKey] = bundle
+		if err := m.client.Update(ctx, &secret); err != nil {
+			return err
+		}
+		current = next
+	}
+
+	m.setCA(current, secret.Data[corev1.ServiceAccountRootCAKey])
+	return nil
+}
+
+func (m *Manager) createCA(ctx context.Context) error {
+	m.logger.Info("creating ca", "secret", m.opts.CASecretName)
+	newCA, err := newCA(fmt.Sprintf("x-pdb-ca-%s", m.opts.Namespace), m.opts.CAValidity)
+	if err != nil {
+		return err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.opts.CASecretName,
+			Namespace: m.opts.Namespace,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:              newCA.pem.cert,
+			corev1.TLSPrivateKeyKey:        newCA.pem.key,
+			corev1.ServiceAccountRootCAKey: newCA.pem.cert,
+		},
+	}
+
+	err = m.client.Create(ctx, secret)
+	if apierrors.IsAlreadyExists(err) {
+		// another replica created the ca in the meantime.
+		return m.ensureCA(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	m.setCA(newCA, newCA.pem.cert)
+	return nil
+}
+
+func (m *Manager) setCA(c *ca, bundle []byte) {
+	if m.ca == nil || !m.ca.cert.Equal(c.cert) {
+		// force issuing new certificates with the new ca.
+		m.webhookCert = nil
+		m.controllerCert = nil
+	}
+	m.ca = c
+	m.caBundle = bundle
+	metrics.ObserveCertificateExpiry(certificateNameCA, c.cert.NotAfter)
+}
+
+// ensureCertificate issues a new certificate if none has been issued yet or if it is about to expire.
+// The certificate is written together with the CA bundle into dir.
+func (m *Manager) ensureCertificate(
+	current *x509.Certificate,
+	name string,
+	dnsNames []string,
+	dir string,
+	caBundle []byte,
+) (*x509.Certificate, error) {
+	if dir == "" {
+		return current, nil
+	}
+
+	if err := writeFileIfChanged(filepath.Join(dir, corev1.ServiceAccountRootCAKey), caBundle); err != nil {
+		return current, err
+	}
+
+	if current != nil && !needsRenewal(current, m.opts.RenewBefore) {
+		return current, nil
+	}
+
+	pair, err := m.ca.issue(fmt.Sprintf("x-pdb-%s", name), dnsNames, m.opts.CertValidity)
+	if err != nil {
+		return current, err
+	}
+	cert, err := parseCertificate(pair.cert)
+	if err != nil {
+		return current, err
+	}
+
+	// the key is written first, the certificate watchers retry
+	// reading the key pair once the certificate has been written.
+	if err := writeFileIfChanged(filepath.Join(dir, corev1.TLSPrivateKeyKey), pair.key); err != nil {
+		return current, err
+	}
+	if err := writeFileIfChanged(filepath.Join(dir, corev1.TLSCertKey), pair.cert); err != nil {
+		return current, err
+	}
+
+	m.logger.Info("issued certificate", "name", name, "dnsNames", dnsNames, "notAfter", cert.NotAfter)
+	metrics.ObserveCertificateExpiry(name, cert.NotAfter)
+	return cert, nil
+}
+
+// getTrustBundle returns the CAs of this cluster and of all trusted remote clusters.
+func (m *Manager) getTrustBundle(ctx context.Context) ([]byte, error) {
+	bundle := append([]byte{}, m.caBundle...)
+	if m.opts.TrustedCAsConfigMapName == "" {
+		return bundle, nil
+	}
+
+	var cm corev1.ConfigMap
+	err := m.client.Get(ctx, client.ObjectKey{Namespace: m.opts.Namespace, Name: m.opts.TrustedCAsConfigMapName}, &cm)
+	if apierrors.IsNotFound(err) {
+		return bundle, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(cm.Data))
+	for k := range cm.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		data := []byte(cm.Data[k])
+		if !isPEMCertificateBundle(data) {
+			m.logger.Error(nil, "ignoring invalid trusted ca", "configMap", cm.Name, "key", k)
+			continue
+		}
+		bundle = append(bundle, data...)
+		if !bytes.HasSuffix(bundle, []byte("\n")) {
+			bundle = append(bundle, '\n')
+		}
+	}
+
+	return bundle, nil
+}
+
+// patchWebhookConfiguration injects the CA bundle into all webhooks of the ValidatingWebhookConfiguration.
+func (m *Manager) patchWebhookConfiguration(ctx context.Context) error {
+	if m.opts.WebhookConfigurationName == "" {
+		return nil
+	}
+
+	var whc admissionregistrationv1.ValidatingWebhookConfiguration
+	err := m.client.Get(ctx, client.ObjectKey{Name: m.opts.WebhookConfigurationName}, &whc)
+	if err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(whc.DeepCopy())
+	changed := false
+	for i := range whc.Webhooks {
+		if !bytes.Equal(whc.Webhooks[i].ClientConfig.CABundle, m.caBundle) {
+			whc.Webhooks[i].ClientConfig.CABundle = m.caBundle
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	m.logger.Info("injecting ca bundle", "validatingWebhookConfiguration", whc.Name)
+	return m.client.Patch(ctx, &whc, patch)
+}
+
+// publishCA stores the CA bundle in a ConfigMap, so it can be imported
+// as trusted CA by remote clusters.
+func (m *Manager) publishCA(ctx context.Context) error {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.opts.CASecretName,
+			Namespace: m.opts.Namespace,
+		},
+		Data: map[string]string{
+			corev1.ServiceAccountRootCAKey: string(m.caBundle),
+		},
+	}
+
+	var existing corev1.ConfigMap
+	err := m.client.Get(ctx, client.ObjectKeyFromObject(cm), &existing)
+	if apierrors.IsNotFound(err) {
+		err = m.client.Create(ctx, cm)
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing.Data[corev1.ServiceAccountRootCAKey] == string(m.caBundle) {
+		return nil
+	}
+	existing.Data = cm.Data
+	return m.client.Update(ctx, &existing)
+}
+
+// signCertificateRequests signs the approved certificate requests of remote clusters.
+// Requests are signed again when their certificate is about to expire or was issued by a previous CA.
+func (m *Manager) signCertificateRequests(ctx context.Context) error {
+	var secrets corev1.SecretList
+	err := m.client.List(ctx, &secrets,
+		client.InNamespace(m.opts.Namespace),
+		client.MatchingFields{"type": string(CertificateRequestSecretType)},
+	)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if !m.requestNeedsSigning(secret) {
+			continue
+		}
+
+		clusterID := secret.Annotations[CertificateRequestApprovedAnnotation]
+		if clusterID == "" {
+			m.logger.V(1).Info("certificate request is not approved", "secret", secret.Name)
+			continue
+		}
+		identities, found := m.opts.PeerIdentities[clusterID]
+		if !found {
+			errs = append(errs, fmt.Errorf("unable to sign %s: approved for unknown peer %q", secret.Name, clusterID))
+			continue
+		}
+
+		certPEM, err := m.ca.signCSR(secret.Data[CertificateRequestKey], identities, m.opts.CertValidity)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to sign %s: %w", secret.Name, err))
+			continue
+		}
+
+		secret.Data[corev1.TLSCertKey] = certPEM
+		secret.Data[corev1.ServiceAccountRootCAKey] = m.caBundle
+		if err := m.client.Update(ctx, secret); err != nil {
+			errs = append(errs, fmt.Errorf("unable to update %s: %w", secret.Name, err))
+			continue
+		}
+		m.logger.Info("signed certificate request", "secret", secret.Name, "peer", clusterID)
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) requestNeedsSigning(secret *corev1.Secret) bool {
+	if len(secret.Data[CertificateRequestKey]) == 0 {
+		return false
+	}
+	cert, err := parseCertificate(secret.Data[corev1.TLSCertKey])
+	if err != nil {
+		return true
+	}
+	if cert.CheckSignatureFrom(m.ca.cert) != nil {
+		return true
+	}
+	return needsRenewal(cert, m.opts.RenewBefore)
+}
+
+func isPEMCertificateBundle(data []byte) bool {
+	found := false
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return found
+		}
+		if block.Type != "CERTIFICATE" {
+			return false
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return false
+		}
+		found = true
+	}
+}
+
+func writeFileIfChanged(path string, data []byte) error {
+	//nolint:gosec
+	existing, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	return os.WriteFile(path, data, 0o600)
+}
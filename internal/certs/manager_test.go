@@ -0,0 +1,198 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testNamespace = "x-pdb"
+
+func newTestManager(t *testing.T, objs ...client.Object) (*Manager, client.Client) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithIndex(&corev1.Secret{}, "type", func(o client.Object) []string {
+			return []string{string(o.(*corev1.Secret).Type)}
+		}).
+		Build()
+
+	m := NewManager(cli, logr.Discard(), Options{
+		Namespace:                testNamespace,
+		CASecretName:             "x-pdb-ca",
+		TrustedCAsConfigMapName:  "x-pdb-trusted-cas",
+		WebhookConfigurationName: "x-pdb",
+		WebhookDNSNames:          []string{"x-pdb.x-pdb.svc"},
+		WebhookCertsDir:          t.TempDir(),
+		ControllerDNSNames:       []string{"x-pdb.blue.example.org"},
+		ControllerCertsDir:       t.TempDir(),
+		CAValidity:               24 * time.Hour,
+		CertValidity:             time.Hour,
+		RenewBefore:              30 * time.Minute,
+		CheckInterval:            time.Minute,
+		PeerIdentities:           map[string][]string{"grey": {"x-pdb.grey.example.org", "spiffe://grey.example.org/x-pdb"}},
+	})
+	return m, cli
+}
+
+func TestBootstrap(t *testing.T) {
+	ctx := context.Background()
+	remoteCA, err := newCA("grey", time.Hour)
+	require.NoError(t, err)
+
+	m, cli := newTestManager(t,
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "x-pdb"},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{
+				{Name: "deletion.x-pdb.form3.tech"},
+				{Name: "eviction.x-pdb.form3.tech"},
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "x-pdb-trusted-cas", Namespace: testNamespace},
+			Data:       map[string]string{"grey": string(remoteCA.pem.cert)},
+		},
+	)
+	require.NoError(t, m.Bootstrap(ctx))
+
+	var secret corev1.Secret
+	require.NoError(t, cli.Get(ctx, client.ObjectKey{Namespace: testNamespace, Name: "x-pdb-ca"}, &secret))
+	caBundle := secret.Data[corev1.ServiceAccountRootCAKey]
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(caBundle))
+
+	webhookCert, err := tls.LoadX509KeyPair(
+		filepath.Join(m.opts.WebhookCertsDir, corev1.TLSCertKey),
+		filepath.Join(m.opts.WebhookCertsDir, corev1.TLSPrivateKeyKey))
+	require.NoError(t, err)
+	_, err = webhookCert.Leaf.Verify(x509.VerifyOptions{DNSName: "x-pdb.x-pdb.svc", Roots: pool})
+	assert.NoError(t, err)
+
+	controllerCert, err := tls.LoadX509KeyPair(
+		filepath.Join(m.opts.ControllerCertsDir, corev1.TLSCertKey),
+		filepath.Join(m.opts.ControllerCertsDir, corev1.TLSPrivateKeyKey))
+	require.NoError(t, err)
+	_, err = controllerCert.Leaf.Verify(x509.VerifyOptions{
+		DNSName:   "x-pdb.blue.example.org",
+		Roots:     pool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	assert.NoError(t, err)
+
+	trusted, err := os.ReadFile(filepath.Join(m.opts.ControllerCertsDir, corev1.ServiceAccountRootCAKey))
+	require.NoError(t, err)
+	assert.Contains(t, string(trusted), string(caBundle))
+	assert.Contains(t, string(trusted), string(remoteCA.pem.cert))
+
+	var whc admissionregistrationv1.ValidatingWebhookConfiguration
+	require.NoError(t, cli.Get(ctx, client.ObjectKey{Name: "x-pdb"}, &whc))
+	for _, wh := range whc.Webhooks {
+		assert.Equal(t, caBundle, wh.ClientConfig.CABundle)
+	}
+
+	var published corev1.ConfigMap
+	require.NoError(t, cli.Get(ctx, client.ObjectKey{Namespace: testNamespace, Name: "x-pdb-ca"}, &published))
+	assert.Equal(t, string(caBundle), published.Data[corev1.ServiceAccountRootCAKey])
+
+	t.Run("second replica reuses the ca", func(t *testing.T) {
+		other := NewManager(cli, logr.Discard(), m.opts)
+		require.NoError(t, other.issueCertificates(ctx))
+		assert.True(t, other.ca.cert.Equal(m.ca.cert))
+	})
+}
+
+func TestCARenewal(t *testing.T) {
+	ctx := context.Background()
+	m, cli := newTestManager(t)
+	require.NoError(t, m.issueCertificates(ctx))
+	previous := m.ca
+
+	// the ca has less than a third of its validity left.
+	m.opts.CAValidity = 100 * 24 * time.Hour
+	require.NoError(t, m.issueCertificates(ctx))
+	assert.False(t, m.ca.cert.Equal(previous.cert))
+	assert.NoError(t, m.webhookCert.CheckSignatureFrom(m.ca.cert))
+
+	var secret corev1.Secret
+	require.NoError(t, cli.Get(ctx, client.ObjectKey{Namespace: testNamespace, Name: "x-pdb-ca"}, &secret))
+	bundle := string(secret.Data[corev1.ServiceAccountRootCAKey])
+	assert.Contains(t, bundle, string(m.ca.pem.cert))
+	assert.Contains(t, bundle, string(previous.pem.cert))
+}
+
+func TestSignCertificateRequests(t *testing.T) {
+	ctx := context.Background()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	request := func(name, approvedFor string, dnsNames ...string) *corev1.Secret {
+		csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: name},
+			DNSNames: dnsNames,
+		}, key)
+		require.NoError(t, err)
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+			Type:       CertificateRequestSecretType,
+			Data: map[string][]byte{
+				CertificateRequestKey: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}),
+			},
+		}
+		if approvedFor != "" {
+			secret.Annotations = map[string]string{CertificateRequestApprovedAnnotation: approvedFor}
+		}
+		return secret
+	}
+	invalid := request("invalid", "grey")
+	invalid.Data[CertificateRequestKey] = []byte("invalid")
+
+	m, cli := newTestManager(t,
+		request("grey", "grey", "x-pdb.grey.example.org"),
+		request("unapproved", "", "x-pdb.grey.example.org"),
+		request("impersonation", "grey", "x-pdb.grey.example.org", "x-pdb.blue.example.org"),
+		request("unknown-peer", "gold", "x-pdb.gold.example.org"),
+		invalid,
+	)
+	require.NoError(t, m.issueCertificates(ctx))
+	err = m.signCertificateRequests(ctx)
+	assert.ErrorContains(t, err, "invalid")
+	assert.ErrorContains(t, err, `identity "x-pdb.blue.example.org" which is not an identity of the approved peer`)
+	assert.ErrorContains(t, err, `unknown peer "gold"`)
+
+	var secret corev1.Secret
+	require.NoError(t, cli.Get(ctx, client.ObjectKey{Namespace: testNamespace, Name: "grey"}, &secret))
+	cert, err := parseCertificate(secret.Data[corev1.TLSCertKey])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x-pdb.grey.example.org"}, cert.DNSNames)
+	assert.NoError(t, cert.CheckSignatureFrom(m.ca.cert))
+	assert.Equal(t, m.caBundle, secret.Data[corev1.ServiceAccountRootCAKey])
+	assert.False(t, m.requestNeedsSigning(&secret))
+
+	for _, name := range []string{"unapproved", "impersonation", "unknown-peer"} {
+		require.NoError(t, cli.Get(ctx, client.ObjectKey{Namespace: testNamespace, Name: name}, &secret))
+		assert.NotContains(t, secret.Data, corev1.TLSCertKey, name)
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"slices"
+	"time"
+)
+
+// keyPair is a PEM encoded certificate and private key.
+type keyPair struct {
+	cert []byte
+	key  []byte
+}
+
+// ca is a certificate authority which is able to issue certificates.
+type ca struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+	pem  keyPair
+}
+
+func newCA(commonName string, validity time.Duration) (*ca, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := encodeKeyPair(der, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCA(pair)
+}
+
+func parseCA(pair keyPair) (*ca, error) {
+	cert, err := parseCertificate(pair.cert)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(pair.key)
+	if block == nil {
+		return nil, errors.New("unable to decode ca private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse ca private key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("ca private key cannot sign certificates")
+	}
+
+	return &ca{cert: cert, key: signer, pem: pair}, nil
+}
+
+// issue creates a certificate valid for both server and client authentication.
+func (c *ca) issue(commonName string, dnsNames []string, validity time.Duration) (keyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return keyPair{}, err
+	}
+
+	der, err := c.sign(&x509.CertificateRequest{
+		Subject:   pkix.Name{CommonName: commonName},
+		DNSNames:  dnsNames,
+		PublicKey: key.Public(),
+	}, validity)
+	if err != nil {
+		return keyPair{}, err
+	}
+
+	return encodeKeyPair(der, key)
+}
+
+// signCSR signs the PEM encoded certificate signing request and returns the PEM encoded certificate.
+// The request may only claim the DNS and URI SANs in identities.
+func (c *ca) signCSR(csrPEM []byte, identities []string, validity time.Duration) ([]byte, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, errors.New("unable to decode certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate request: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid certificate request signature: %w", err)
+	}
+	if err := checkIdentities(csr, identities); err != nil {
+		return nil, err
+	}
+
+	der, err := c.sign(csr, validity)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// checkIdentities verifies that the request only claims identities in the allowed identities.
+func checkIdentities(csr *x509.CertificateRequest, allowed []string) error {
+	if len(csr.IPAddresses) > 0 || len(csr.EmailAddresses) > 0 {
+		return errors.New("certificate request must only contain DNS and URI SANs")
+	}
+	requested := slices.Clone(csr.DNSNames)
+	for _, u := range csr.URIs {
+		requested = append(requested, u.String())
+	}
+	if len(requested) == 0 {
+		return errors.New("certificate request has no DNS or URI SANs")
+	}
+	for _, id := range requested {
+		if !slices.Contains(allowed, id) {
+			return fmt.Errorf("certificate request claims identity %q which is not an identity of the approved peer", id)
+		}
+	}
+	return nil
+}
+
+func (c *ca) sign(csr *x509.CertificateRequest, validity time.Duration) ([]byte, error) {
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	notAfter := now.Add(validity)
+	// never issue certificates that outlive the ca.
+	if notAfter.After(c.cert.NotAfter) {
+		notAfter = c.cert.NotAfter
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		URIs:         csr.URIs,
+		IPAddresses:  append([]net.IP{}, csr.IPAddresses...),
+		NotBefore:    now.Add(-5 * time.Minute),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	return x509.CreateCertificate(rand.Reader, tmpl, c.cert, csr.PublicKey, c.key)
+}
+
+func encodeKeyPair(der []byte, key crypto.Signer) (keyPair, error) {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return keyPair{}, err
+	}
+	return keyPair{
+		cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		key:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("unable to decode certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// needsRenewal returns true if less than renewBefore of the certificate's lifetime is left.
+func needsRenewal(cert *x509.Certificate, renewBefore time.Duration) bool {
+	return time.Until(cert.NotAfter) < renewBefore
+}
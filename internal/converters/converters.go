@@ -1,6 +1,7 @@
 package converters
 
 import (
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
 	statepb "github.com/form3tech-oss/x-pdb/pkg/proto/state/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/ptr"
@@ -57,3 +58,19 @@ func ConvertLabelSelectorRequirementToState(r *metav1.LabelSelectorRequirement)
 
 	return req
 }
+
+func ConvertTargetRefToV1alpha1(r *statepb.TargetRef) *xpdbv1alpha1.XPodDisruptionBudgetTargetRef {
+	return &xpdbv1alpha1.XPodDisruptionBudgetTargetRef{
+		APIVersion: r.ApiVersion,
+		Kind:       r.Kind,
+		Name:       r.Name,
+	}
+}
+
+func ConvertTargetRefToState(r *xpdbv1alpha1.XPodDisruptionBudgetTargetRef) *statepb.TargetRef {
+	return &statepb.TargetRef{
+		ApiVersion: r.APIVersion,
+		Kind:       r.Kind,
+		Name:       r.Name,
+	}
+}
@@ -0,0 +1,153 @@
+package disruptionprobe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// maxCacheAge caps the max-age probes may set for their verdicts.
+var maxCacheAge = 5 * time.Minute
+
+// cacheSweepInterval is the interval expired verdicts are removed from the cache at.
+var cacheSweepInterval = time.Minute
+
+// resultCache caches the verdicts of probes per XPDB and pod, and coalesces concurrent
+// calls of a probe for the same pod and disruption into a single call, whose verdict all callers share.
+type resultCache struct {
+	// ttl is the time verdicts are cached for if the probe doesn't set a max-age.
+	ttl time.Duration
+	now func() time.Time
+
+	mux       sync.Mutex
+	entries   map[cacheKey]cacheEntry
+	lastSweep time.Time
+
+	calls singleflight.Group
+}
+
+// cacheKey identifies the verdict of a probe for a pod.
+// The generation of the XPDB is part of the key, so changes of the probes invalidate their verdicts.
+// Verdicts of dry-run disruptions are kept apart, probes may respond differently to them.
+type cacheKey struct {
+	xpdbNamespace  string
+	xpdbName       string
+	xpdbGeneration int64
+	probe          string
+	podName        string
+	podUID         types.UID
+	dryRun         bool
+}
+
+func (k cacheKey) String() string {
+	return fmt.Sprintf("%s/%s/%d/%s/%s/%s/%t",
+		k.xpdbNamespace, k.xpdbName, k.xpdbGeneration, k.probe, k.podName, k.podUID, k.dryRun)
+}
+
+// callKey identifies a call of a probe for a disruption.
+// Only calls with the same details of the disruption are coalesced, as the probe receives them.
+type callKey struct {
+	cacheKey
+	operation   string
+	subResource string
+	username    string
+}
+
+func (k callKey) String() string {
+	return fmt.Sprintf("%s/%s/%s/%s", k.cacheKey, k.operation, k.subResource, k.username)
+}
+
+type cacheEntry struct {
+	result  *Result
+	expires time.Time
+}
+
+func newResultCache(ttl time.Duration) *resultCache {
+	return &resultCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[cacheKey]cacheEntry{},
+	}
+}
+
+// do returns the cached verdict of the key or calls fn, sharing the call with concurrent callers of the same key.
+// Calls are shared by their callKey and cached by their cacheKey.
+// fn is called with a context which isn't canceled with the context of the caller,
+// as other callers wait for its verdict, but which keeps the deadline of the caller. Errors are not cached.
+func (c *resultCache) do(
+	ctx context.Context,
+	key callKey,
+	fn func(context.Context) (*Result, error),
+) (result *Result, cached bool, err error) {
+	if result, found := c.get(key.cacheKey); found {
+		return result, true, nil
+	}
+
+	ch := c.calls.DoChan(key.String(), func() (any, error) {
+		callCtx := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			callCtx, cancel = context.WithDeadline(callCtx, deadline)
+			defer cancel()
+		}
+		result, err := fn(callCtx)
+		if err != nil {
+			return nil, err
+		}
+		c.set(key.cacheKey, result)
+		return result, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, false, res.Err
+		}
+		return res.Val.(*Result), res.Shared, nil
+	}
+}
+
+func (c *resultCache) get(key cacheKey) (*Result, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	entry, found := c.entries[key]
+	if !found || !c.now().Before(entry.expires) {
+		return nil, false
+	}
+	return entry.result, true
+}
+
+// set caches the verdict for the max-age set by the probe or the ttl.
+// Verdicts requesting pre-activities aren't cached, the pre-activities may be completed any time.
+func (c *resultCache) set(key cacheKey, result *Result) {
+	age := c.ttl
+	if result.MaxAge != nil {
+		age = min(*result.MaxAge, maxCacheAge)
+	}
+	if age <= 0 || len(result.PreActivities) > 0 {
+		return
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	now := c.now()
+	c.entries[key] = cacheEntry{result: result, expires: now.Add(age)}
+
+	if now.Sub(c.lastSweep) < cacheSweepInterval {
+		return
+	}
+	c.lastSweep = now
+	for k, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+}
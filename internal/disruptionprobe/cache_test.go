@@ -0,0 +1,256 @@
+package disruptionprobe
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+)
+
+func TestResultCache(t *testing.T) {
+	key := callKey{cacheKey: cacheKey{xpdbNamespace: "db", xpdbName: "postgres", probe: "raft", podName: "db-0", podUID: "uid-1"}}
+
+	tests := []struct {
+		name       string
+		ttl        time.Duration
+		result     *Result
+		elapsed    time.Duration
+		wantCached bool
+	}{
+		{
+			name:   "should not cache without ttl",
+			result: &Result{Allowed: true},
+		},
+		{
+			name:       "should cache for the ttl",
+			ttl:        time.Minute,
+			result:     &Result{Allowed: true},
+			elapsed:    59 * time.Second,
+			wantCached: true,
+		},
+		{
+			name:    "should expire after the ttl",
+			ttl:     time.Minute,
+			result:  &Result{Allowed: true},
+			elapsed: time.Minute,
+		},
+		{
+			name:       "should cache for the max-age of the probe",
+			result:     &Result{Allowed: true, MaxAge: ptr.To(30 * time.Second)},
+			elapsed:    29 * time.Second,
+			wantCached: true,
+		},
+		{
+			name:    "should cap the max-age of the probe",
+			result:  &Result{Allowed: true, MaxAge: ptr.To(time.Hour)},
+			elapsed: maxCacheAge,
+		},
+		{
+			name:   "should not cache if the probe disabled caching",
+			ttl:    time.Minute,
+			result: &Result{Allowed: true, MaxAge: ptr.To(time.Duration(0))},
+		},
+		{
+			name:   "should not cache verdicts requesting pre-activities",
+			ttl:    time.Minute,
+			result: &Result{PreActivities: []PreActivity{{Name: "transfer-leadership"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			cache := newResultCache(tt.ttl)
+			cache.now = func() time.Time { return now }
+			var calls int
+			fn := func(context.Context) (*Result, error) {
+				calls++
+				return tt.result, nil
+			}
+
+			result, cached, err := cache.do(context.Background(), key, fn)
+			require.NoError(t, err)
+			assert.False(t, cached)
+			assert.Equal(t, tt.result, result)
+
+			now = now.Add(tt.elapsed)
+			result, cached, err = cache.do(context.Background(), key, fn)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCached, cached)
+			assert.Equal(t, tt.result, result)
+			if tt.wantCached {
+				assert.Equal(t, 1, calls)
+			} else {
+				assert.Equal(t, 2, calls)
+			}
+		})
+	}
+}
+
+func TestResultCache_Coalesce(t *testing.T) {
+	key := callKey{cacheKey: cacheKey{xpdbNamespace: "db", xpdbName: "postgres", probe: "raft", podName: "db-0", podUID: "uid-1"}}
+	cache := newResultCache(0)
+
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fn := func(context.Context) (*Result, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return &Result{Allowed: true}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan *Result, 5)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		result, _, _ := cache.do(context.Background(), key, fn)
+		results <- result
+	}()
+	<-started
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, _, _ := cache.do(context.Background(), key, fn)
+			results <- result
+		}()
+	}
+
+	// give the callers time to join the pending call before it completes.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	assert.Equal(t, int32(1), calls.Load())
+	for result := range results {
+		assert.Equal(t, &Result{Allowed: true}, result)
+	}
+
+	t.Run("should return when the context of a caller is canceled", func(t *testing.T) {
+		blocked := make(chan struct{})
+		defer close(blocked)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, _, err := cache.do(ctx, key, func(context.Context) (*Result, error) {
+			<-blocked
+			return &Result{Allowed: true}, nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestResultCache_Keys(t *testing.T) {
+	key := callKey{
+		cacheKey:  cacheKey{xpdbNamespace: "db", xpdbName: "postgres", probe: "raft", podName: "db-0", podUID: "uid-1"},
+		operation: "CREATE", subResource: "eviction", username: "system:serviceaccount:kube-system:node-drainer",
+	}
+	dryRun := key
+	dryRun.dryRun = true
+	otherUser := key
+	otherUser.username = "alice"
+
+	tests := []struct {
+		name          string
+		other         callKey
+		wantCoalesced bool
+	}{
+		{name: "should coalesce the same disruption", other: key, wantCoalesced: true},
+		{name: "should not coalesce dry-run with real disruptions", other: dryRun},
+		{name: "should not coalesce disruptions of other users", other: otherUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newResultCache(0)
+			var calls atomic.Int32
+			started := make(chan struct{})
+			release := make(chan struct{})
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_, _, _ = cache.do(context.Background(), key, func(context.Context) (*Result, error) {
+					close(started)
+					calls.Add(1)
+					<-release
+					return &Result{Allowed: true}, nil
+				})
+			}()
+			<-started
+
+			other := make(chan struct{})
+			go func() {
+				defer close(other)
+				_, _, _ = cache.do(context.Background(), tt.other, func(context.Context) (*Result, error) {
+					calls.Add(1)
+					return &Result{Allowed: true}, nil
+				})
+			}()
+			if !tt.wantCoalesced {
+				<-other
+			} else {
+				// give the caller time to join the pending call before it completes.
+				time.Sleep(50 * time.Millisecond)
+			}
+			close(release)
+			<-done
+			<-other
+
+			if tt.wantCoalesced {
+				assert.Equal(t, int32(1), calls.Load())
+			} else {
+				assert.Equal(t, int32(2), calls.Load())
+			}
+		})
+	}
+
+	t.Run("should not serve cached verdicts of dry-run disruptions to real disruptions", func(t *testing.T) {
+		cache := newResultCache(time.Minute)
+		var calls int
+		fn := func(context.Context) (*Result, error) {
+			calls++
+			return &Result{Allowed: true}, nil
+		}
+		_, _, err := cache.do(context.Background(), dryRun, fn)
+		require.NoError(t, err)
+		_, cached, err := cache.do(context.Background(), key, fn)
+		require.NoError(t, err)
+		assert.False(t, cached)
+		assert.Equal(t, 2, calls)
+	})
+}
+
+func TestResultCache_Deadline(t *testing.T) {
+	key := callKey{cacheKey: cacheKey{xpdbNamespace: "db", xpdbName: "postgres", probe: "raft", podName: "db-0", podUID: "uid-1"}}
+	cache := newResultCache(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	done := make(chan error, 1)
+	_, _, err := cache.do(ctx, key, func(ctx context.Context) (*Result, error) {
+		callDeadline, ok := ctx.Deadline()
+		assert.True(t, ok, "shared call should keep the deadline of the caller")
+		assert.Equal(t, deadline, callDeadline)
+		<-ctx.Done()
+		done <- ctx.Err()
+		return nil, ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("shared call should end at the deadline of the caller")
+	}
+}
@@ -5,89 +5,274 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
 	"github.com/form3tech-oss/x-pdb/internal/metrics"
-	disruptionprobepb "github.com/form3tech-oss/x-pdb/pkg/proto/disruptionprobe/v1"
+	"github.com/form3tech-oss/x-pdb/internal/mtls"
+	"github.com/form3tech-oss/x-pdb/internal/proxy"
+	"github.com/form3tech-oss/x-pdb/internal/tracing"
 	"github.com/go-logr/logr"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 )
 
+// keepaliveParams are the keepalive parameters of the connections to the disruption probes.
+// The probe servers are not run by x-pdb, hence the pings are not sent more often than
+// permitted by the default enforcement policy of grpc servers.
+var keepaliveParams = keepalive.ClientParameters{
+	Time:                5 * time.Minute,
+	Timeout:             20 * time.Second,
+	PermitWithoutStream: true,
+}
+
 type ClientPool struct {
-	clients  map[string]disruptionprobepb.DisruptionProbeServiceClient
-	mux      *sync.Mutex
-	ctx      context.Context
-	logger   *logr.Logger
-	certsDir string
+	clients     map[string]*pooledClient
+	httpClients map[string]*http.Client
+	mux         *sync.Mutex
+	ctx         context.Context
+	logger      *logr.Logger
+	certsDir    string
+	proxy       *proxy.Config
+	idleTimeout time.Duration
+	credentials *Credentials
 }
 
+// pooledClient is a connection to a probe endpoint and the time it was last used.
+type pooledClient struct {
+	conn     *grpc.ClientConn
+	lastUsed time.Time
+	// v1Only is set once the probe turned out not to implement disruptionprobe.v2.
+	v1Only atomic.Bool
+}
+
+// NewClientPool creates a new ClientPool.
+// When proxyConfig is set, the probe endpoints are reached through the egress proxy.
+// Clients which haven't been used for idleTimeout are closed, this keeps the connections
+// to per-pod endpoints short-lived. Clients are never closed if idleTimeout is 0.
+// The CAs and client certificates referenced by the probes are supplied by creds.
 func NewClientPool(
 	ctx context.Context,
 	logger *logr.Logger,
 	certsDir string,
+	proxyConfig *proxy.Config,
+	idleTimeout time.Duration,
+	creds *Credentials,
 ) *ClientPool {
 	return &ClientPool{
-		certsDir: certsDir,
-		clients:  make(map[string]disruptionprobepb.DisruptionProbeServiceClient),
-		ctx:      ctx,
-		mux:      &sync.Mutex{},
-		logger:   logger,
+		certsDir:    certsDir,
+		proxy:       proxyConfig,
+		clients:     make(map[string]*pooledClient),
+		httpClients: make(map[string]*http.Client),
+		ctx:         ctx,
+		mux:         &sync.Mutex{},
+		logger:      logger,
+		idleTimeout: idleTimeout,
+		credentials: creds,
 	}
 }
 
-func (p *ClientPool) Get(endpoint string) (disruptionprobepb.DisruptionProbeServiceClient, error) {
+// get returns the client of the grpc probe endpoint of an XPDB in the namespace.
+// Clients are shared by probes with the same endpoint and TLS configuration.
+func (p *ClientPool) get(
+	ctx context.Context,
+	endpoint string,
+	namespace string,
+	spec *xpdbv1alpha1.XPodDisruptionBudgetProbeTLS,
+) (*pooledClient, error) {
 	p.mux.Lock()
 	defer p.mux.Unlock()
 
-	c, found := p.clients[endpoint]
+	key := endpoint + "|" + tlsKey(namespace, spec)
+	c, found := p.clients[key]
 	if !found {
-		c, err := p.newClient(endpoint)
+		conn, err := p.newConn(ctx, endpoint, namespace, spec)
 		if err != nil {
 			return nil, err
 		}
 
-		p.clients[endpoint] = c
-		return c, nil
+		c = &pooledClient{conn: conn}
+		p.clients[key] = c
 	}
 
+	c.lastUsed = time.Now()
 	return c, nil
 }
 
-func (p *ClientPool) newClient(endpoint string) (disruptionprobepb.DisruptionProbeServiceClient, error) {
-	certPool := x509.NewCertPool()
+// Start closes idle clients periodically until the context is cancelled.
+// All clients are closed once the context is cancelled.
+func (p *ClientPool) Start(ctx context.Context) error {
+	defer func() { p.evictIdle(time.Now()) }()
 
-	p.logger.Info("certs dir", "certsdir", p.certsDir)
-	//nolint:gosec
-	clientCABytes, err := os.ReadFile(filepath.Join(p.certsDir, "ca.crt"))
-	if err != nil {
-		return nil, fmt.Errorf("failed to read client CA cert: %w", err)
+	if p.idleTimeout <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			p.evictIdle(time.Now().Add(-p.idleTimeout))
+		}
 	}
+}
 
-	ok := certPool.AppendCertsFromPEM(clientCABytes)
-	if !ok {
+// evictIdle closes the clients which haven't been used since the given time.
+func (p *ClientPool) evictIdle(since time.Time) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	for endpoint, c := range p.clients {
+		if c.lastUsed.After(since) {
+			continue
+		}
+		delete(p.clients, endpoint)
+		if err := c.conn.Close(); err != nil {
+			p.logger.Error(err, "unable to close disruption probe connection", "endpoint", endpoint)
+		}
+	}
+}
+
+// HTTPClient returns the client used to call HTTP probes of an XPDB in the namespace.
+// Unless the probe references a CA, it trusts the system CAs and the CA defined in the certs dir.
+func (p *ClientPool) HTTPClient(
+	ctx context.Context,
+	namespace string,
+	spec *xpdbv1alpha1.XPodDisruptionBudgetProbeTLS,
+) (*http.Client, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	key := tlsKey(namespace, spec)
+	if c, found := p.httpClients[key]; found {
+		return c, nil
+	}
+
+	certPool, err := x509.SystemCertPool()
+	if err != nil {
+		certPool = x509.NewCertPool()
+	}
+	//nolint:gosec
+	caBytes, err := os.ReadFile(filepath.Join(p.certsDir, "ca.crt"))
+	if err == nil && !certPool.AppendCertsFromPEM(caBytes) {
 		return nil, fmt.Errorf("failed to append client CA cert to CA pool")
 	}
 
-	conn, err := grpc.NewClient(
-		endpoint,
+	if _, err := p.credentials.TLSConfig(ctx, namespace, spec, certPool, ""); err != nil {
+		return nil, err
+	}
+
+	dial := (&net.Dialer{}).DialContext
+	if p.proxy != nil {
+		dialer, err := proxy.NewDialer(p.proxy, "disruption-probe")
+		if err != nil {
+			return nil, err
+		}
+		dial = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, addr)
+		}
+	}
+
+	transport := &http.Transport{
+		DialContext: dial,
+		// the client is shared by the per-pod endpoints of the probe,
+		// hence the TLS configuration is created for the host of each connection.
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			tlsConfig, err := p.credentials.TLSConfig(ctx, namespace, spec, certPool, mtls.ServerName(addr))
+			if err != nil {
+				return nil, err
+			}
+			conn, err := dial(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			tlsConn := tls.Client(conn, tlsConfig)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				_ = conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		},
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     keepaliveParams.Time,
+	}
+
+	c := &http.Client{Transport: transport}
+	p.httpClients[key] = c
+	return c, nil
+}
+
+func (p *ClientPool) newConn(
+	ctx context.Context,
+	endpoint string,
+	namespace string,
+	spec *xpdbv1alpha1.XPodDisruptionBudgetProbeTLS,
+) (*grpc.ClientConn, error) {
+	var certPool *x509.CertPool
+	if spec == nil || spec.CA == nil {
+		certPool = x509.NewCertPool()
+
+		p.logger.Info("certs dir", "certsdir", p.certsDir)
+		//nolint:gosec
+		clientCABytes, err := os.ReadFile(filepath.Join(p.certsDir, "ca.crt"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA cert: %w", err)
+		}
+
+		ok := certPool.AppendCertsFromPEM(clientCABytes)
+		if !ok {
+			return nil, fmt.Errorf("failed to append client CA cert to CA pool")
+		}
+	}
+
+	tlsConfig, err := p.credentials.TLSConfig(ctx, namespace, spec, certPool, mtls.ServerName(endpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	target := endpoint
+	opts := []grpc.DialOption{
 		grpc.WithChainUnaryInterceptor(
 			metrics.GrpcClientMetrics.UnaryClientInterceptor(),
 		),
-		grpc.WithTransportCredentials(
-			credentials.NewTLS(
-				&tls.Config{
-					RootCAs:    certPool,
-					MinVersion: tls.VersionTLS12,
-				},
-			),
-		),
-	)
-	if err != nil {
-		return nil, err
+		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+		grpc.WithKeepaliveParams(keepaliveParams),
+		grpc.WithStatsHandler(tracing.ClientHandler()),
 	}
 
-	return disruptionprobepb.NewDisruptionProbeServiceClient(conn), nil
+	if p.proxy != nil {
+		dialer, err := proxy.NewDialer(p.proxy, "disruption-probe")
+		if err != nil {
+			return nil, err
+		}
+		var proxyOpts []grpc.DialOption
+		target, proxyOpts = dialer.DialOptions(endpoint)
+		opts = append(opts, proxyOpts...)
+	}
+
+	return grpc.NewClient(target, opts...)
+}
+
+// tlsKey identifies the TLS configuration of a probe of an XPDB in the namespace.
+func tlsKey(namespace string, spec *xpdbv1alpha1.XPodDisruptionBudgetProbeTLS) string {
+	if spec == nil {
+		return ""
+	}
+	var ca string
+	if spec.CA != nil {
+		ca = spec.CA.Name + "/" + spec.CA.Key
+	}
+	return namespace + "|" + ca + "|" + spec.ClientCertificateSecretName
 }
@@ -0,0 +1,79 @@
+package disruptionprobe
+
+import (
+	"context"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestClientPool_EvictIdle(t *testing.T) {
+	logger := zap.New(zap.UseDevMode(true))
+	p := NewClientPool(context.Background(), &logger, t.TempDir(), nil, time.Minute, nil)
+
+	now := time.Now()
+	for endpoint, lastUsed := range map[string]time.Time{
+		"10.0.0.12:9090": now.Add(-2 * time.Minute),
+		"10.0.0.13:9090": now.Add(-30 * time.Second),
+	} {
+		conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		p.clients[endpoint] = &pooledClient{
+			conn:     conn,
+			lastUsed: lastUsed,
+		}
+	}
+
+	p.evictIdle(now.Add(-time.Minute))
+	assert.NotContains(t, p.clients, "10.0.0.12:9090")
+	assert.Contains(t, p.clients, "10.0.0.13:9090")
+
+	p.evictIdle(now)
+	assert.Empty(t, p.clients)
+}
+
+func TestClientPool_HTTPClient(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	// the certificate of the test server is issued for 127.0.0.1 and example.com.
+	caSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "probe-ca"},
+		Data:       map[string][]byte{"ca.crt": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})},
+	}
+	cl := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(caSecret).Build()
+	logger := logr.Discard()
+	p := NewClientPool(ctx, &logger, t.TempDir(), nil, time.Minute, NewCredentials(cl, types.NamespacedName{}, nil, SecretAccess{Enabled: true}))
+
+	c, err := p.HTTPClient(ctx, "db", &xpdbv1alpha1.XPodDisruptionBudgetProbeTLS{
+		CA: &xpdbv1alpha1.XPodDisruptionBudgetSecretKeyRef{Name: "probe-ca", Key: "ca.crt"},
+	})
+	require.NoError(t, err)
+
+	resp, err := c.Get(srv.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	t.Run("should reject certificate not issued for the host of the endpoint", func(t *testing.T) {
+		_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+		require.NoError(t, err)
+		_, err = c.Get("https://localhost:" + port)
+		assert.Error(t, err)
+	})
+}
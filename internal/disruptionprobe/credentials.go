@@ -0,0 +1,238 @@
+package disruptionprobe
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	"github.com/form3tech-oss/x-pdb/internal/mtls"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CredentialsSecretLabel must be set to "true" on the Secrets referenced by probes.
+// Only these Secrets are watched by x-pdb.
+const CredentialsSecretLabel = "x-pdb.form3.tech/probe-credentials"
+
+// SecretAccess defines the Secrets probes may reference.
+type SecretAccess struct {
+	// Enabled allows probes to reference Secrets.
+	Enabled bool
+	// Namespaces restricts the Secrets to these namespaces, all namespaces if empty.
+	Namespaces []string
+}
+
+// defaultTokenExpirationSeconds is the lifetime of service account tokens if the probe doesn't define it.
+const defaultTokenExpirationSeconds = 3600
+
+// Credentials supplies the CAs, client certificates and tokens of the probes.
+// Secrets are read from the cache of the manager, hence rotated Secrets are picked up
+// as soon as the watch delivers them.
+type Credentials struct {
+	client         client.Client
+	serviceAccount types.NamespacedName
+	audiences      []string
+	secretAccess   SecretAccess
+
+	mux    sync.Mutex
+	tokens map[tokenKey]*cachedToken
+}
+
+type tokenKey struct {
+	audience          string
+	expirationSeconds int64
+}
+
+type cachedToken struct {
+	token     string
+	refreshAt time.Time
+}
+
+// NewCredentials creates new Credentials.
+// Service account tokens are requested for serviceAccount and only for the allowed audiences.
+// Secrets are only read if secretAccess allows it, as x-pdb can only be granted access to all Secrets of a namespace.
+func NewCredentials(
+	c client.Client,
+	serviceAccount types.NamespacedName,
+	audiences []string,
+	secretAccess SecretAccess,
+) *Credentials {
+	return &Credentials{
+		client:         c,
+		serviceAccount: serviceAccount,
+		audiences:      audiences,
+		secretAccess:   secretAccess,
+		tokens:         map[tokenKey]*cachedToken{},
+	}
+}
+
+// TLSConfig returns the TLS configuration used to connect to a probe of an XPDB in the namespace at serverName.
+// roots are used to verify the probe if the spec doesn't reference a CA.
+// The referenced Secrets are read on every handshake.
+func (c *Credentials) TLSConfig(
+	ctx context.Context,
+	namespace string,
+	spec *xpdbv1alpha1.XPodDisruptionBudgetProbeTLS,
+	roots *x509.CertPool,
+	serverName string,
+) (*tls.Config, error) {
+	cfg := &tls.Config{
+		RootCAs:    roots,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if spec == nil {
+		return cfg, nil
+	}
+
+	if ca := spec.CA; ca != nil {
+		if _, err := c.caPool(ctx, namespace, ca); err != nil {
+			return nil, err
+		}
+		// the certificate of the probe is verified in VerifyConnection against
+		// the current CA bundle, so clients pick up rotated CAs.
+		cfg.InsecureSkipVerify = true //nolint:gosec
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			roots, err := c.caPool(context.Background(), namespace, ca)
+			if err != nil {
+				return err
+			}
+			return mtls.VerifyServerCertificate(cs, roots, serverName)
+		}
+	}
+
+	if name := spec.ClientCertificateSecretName; name != "" {
+		if _, err := c.clientCertificate(ctx, namespace, name); err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = func(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return c.clientCertificate(info.Context(), namespace, name)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Token returns the bearer token of a probe of an XPDB in the namespace.
+// It returns an empty token if the probe doesn't define any.
+// Service account tokens are cached until 80% of their lifetime has passed.
+func (c *Credentials) Token(ctx context.Context, namespace string, auth *xpdbv1alpha1.XPodDisruptionBudgetProbeAuth) (string, error) {
+	switch {
+	case auth == nil:
+		return "", nil
+	case auth.BearerToken != nil:
+		token, err := c.secretKey(ctx, namespace, auth.BearerToken.Name, auth.BearerToken.Key)
+		if err != nil {
+			return "", err
+		}
+		return string(bytes.TrimSpace(token)), nil
+	case auth.ServiceAccountToken != nil:
+		return c.serviceAccountToken(ctx, auth.ServiceAccountToken)
+	}
+	return "", nil
+}
+
+func (c *Credentials) serviceAccountToken(
+	ctx context.Context,
+	spec *xpdbv1alpha1.XPodDisruptionBudgetProbeServiceAccountToken,
+) (string, error) {
+	// tokens of the x-pdb service account must not be usable against the api server or other services.
+	if !slices.Contains(c.audiences, spec.Audience) {
+		return "", fmt.Errorf("service account token audience %q is not allowed", spec.Audience)
+	}
+	if c.serviceAccount.Name == "" {
+		return "", fmt.Errorf("service account of x-pdb is not configured")
+	}
+
+	key := tokenKey{audience: spec.Audience, expirationSeconds: ptr.Deref(spec.ExpirationSeconds, defaultTokenExpirationSeconds)}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if t, found := c.tokens[key]; found && time.Now().Before(t.refreshAt) {
+		return t.token, nil
+	}
+
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Namespace: c.serviceAccount.Namespace,
+		Name:      c.serviceAccount.Name,
+	}}
+	tr := &authenticationv1.TokenRequest{Spec: authenticationv1.TokenRequestSpec{
+		Audiences:         []string{key.audience},
+		ExpirationSeconds: ptr.To(key.expirationSeconds),
+	}}
+	if err := c.client.SubResource("token").Create(ctx, sa, tr); err != nil {
+		return "", fmt.Errorf("unable to request service account token: %w", err)
+	}
+
+	now := time.Now()
+	lifetime := tr.Status.ExpirationTimestamp.Sub(now)
+	c.tokens[key] = &cachedToken{token: tr.Status.Token, refreshAt: now.Add(lifetime * 8 / 10)}
+	return tr.Status.Token, nil
+}
+
+func (c *Credentials) caPool(ctx context.Context, namespace string, ref *xpdbv1alpha1.XPodDisruptionBudgetSecretKeyRef) (*x509.CertPool, error) {
+	caBytes, err := c.secretKey(ctx, namespace, ref.Name, ref.Key)
+	if err != nil {
+		return nil, err
+	}
+	certPool := x509.NewCertPool()
+	if ok := certPool.AppendCertsFromPEM(caBytes); !ok {
+		return nil, fmt.Errorf("failed to append CA cert of secret %s/%s to CA pool", namespace, ref.Name)
+	}
+	return certPool, nil
+}
+
+func (c *Credentials) clientCertificate(ctx context.Context, namespace, name string) (*tls.Certificate, error) {
+	secret, err := c.secret(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid client certificate in secret %s/%s: %w", namespace, name, err)
+	}
+	return &cert, nil
+}
+
+func (c *Credentials) secretKey(ctx context.Context, namespace, name, key string) ([]byte, error) {
+	secret, err := c.secret(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	value, found := secret.Data[key]
+	if !found {
+		return nil, fmt.Errorf("secret %s/%s has no key %s", namespace, name, key)
+	}
+	return value, nil
+}
+
+func (c *Credentials) secret(ctx context.Context, namespace, name string) (*corev1.Secret, error) {
+	if !c.secretAccess.Enabled {
+		return nil, fmt.Errorf("secret %s/%s can't be read, secrets of probes are disabled", namespace, name)
+	}
+	if len(c.secretAccess.Namespaces) > 0 && !slices.Contains(c.secretAccess.Namespaces, namespace) {
+		return nil, fmt.Errorf("secret %s/%s can't be read, secrets of probes are not enabled in namespace %s", namespace, name, namespace)
+	}
+
+	var secret corev1.Secret
+	err := c.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &secret)
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("secret %s/%s not found, it must be labelled with %s=true", namespace, name, CredentialsSecretLabel)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get secret %s/%s: %w", namespace, name, err)
+	}
+	return &secret, nil
+}
@@ -0,0 +1,253 @@
+package disruptionprobe
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) pem() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+}
+
+func (ca *testCA) issue(t *testing.T, dnsName string) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) serverConfig(t *testing.T, dnsName string, clientCA *testCA) *tls.Config {
+	certPEM, keyPEM := ca.issue(t, dnsName)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.cert)
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+}
+
+func handshake(t *testing.T, serverCfg, clientCfg *tls.Config) error {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer conn.Close()
+		errCh <- tls.Server(conn, serverCfg).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", lis.Addr().String(), clientCfg)
+	if err != nil {
+		<-errCh
+		return err
+	}
+	defer conn.Close()
+
+	// with TLS 1.3 the client finishes the handshake before the server
+	// has verified the client certificate.
+	return <-errCh
+}
+
+func newTestScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	return scheme
+}
+
+func TestCredentials_TLSConfig(t *testing.T) {
+	ctx := context.Background()
+	probeCA := newTestCA(t, "probe")
+	rotatedCA := newTestCA(t, "rotated")
+	clientCA := newTestCA(t, "x-pdb")
+	clientCert, clientKey := clientCA.issue(t, "x-pdb")
+
+	caSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "probe-ca"},
+		Data:       map[string][]byte{"ca.crt": probeCA.pem()},
+	}
+	certSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "probe-client"},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: clientCert, corev1.TLSPrivateKeyKey: clientKey},
+	}
+	cl := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(caSecret, certSecret).Build()
+	creds := NewCredentials(cl, types.NamespacedName{}, nil, SecretAccess{Enabled: true})
+
+	spec := &xpdbv1alpha1.XPodDisruptionBudgetProbeTLS{
+		CA:                          &xpdbv1alpha1.XPodDisruptionBudgetSecretKeyRef{Name: "probe-ca", Key: "ca.crt"},
+		ClientCertificateSecretName: "probe-client",
+	}
+	clientCfg, err := creds.TLSConfig(ctx, "db", spec, nil, "probe.db.svc")
+	require.NoError(t, err)
+
+	t.Run("should verify probe with ca of secret and present client certificate", func(t *testing.T) {
+		assert.NoError(t, handshake(t, probeCA.serverConfig(t, "probe.db.svc", clientCA), clientCfg))
+	})
+
+	t.Run("should reject probe of unknown ca", func(t *testing.T) {
+		assert.Error(t, handshake(t, rotatedCA.serverConfig(t, "probe.db.svc", clientCA), clientCfg))
+	})
+
+	t.Run("should pick up rotated ca", func(t *testing.T) {
+		caSecret.Data["ca.crt"] = append(probeCA.pem(), rotatedCA.pem()...)
+		require.NoError(t, cl.Update(ctx, caSecret))
+		assert.NoError(t, handshake(t, rotatedCA.serverConfig(t, "probe.db.svc", clientCA), clientCfg))
+	})
+
+	t.Run("should reject probe certificate not issued for the ip address of the endpoint", func(t *testing.T) {
+		// per-pod endpoints like {{.PodIP}}:9090 are dialed without SNI.
+		ipCfg, err := creds.TLSConfig(ctx, "db", spec, nil, "127.0.0.1")
+		require.NoError(t, err)
+		assert.Error(t, handshake(t, probeCA.serverConfig(t, "probe.db.svc", clientCA), ipCfg))
+	})
+
+	t.Run("should error on missing secret", func(t *testing.T) {
+		_, err := creds.TLSConfig(ctx, "other", spec, nil, "probe.db.svc")
+		assert.ErrorContains(t, err, CredentialsSecretLabel)
+	})
+
+	t.Run("should not read secrets unless secret access allows it", func(t *testing.T) {
+		for _, access := range []SecretAccess{{}, {Enabled: true, Namespaces: []string{"other"}}} {
+			_, err := NewCredentials(cl, types.NamespacedName{}, nil, access).TLSConfig(ctx, "db", spec, nil, "probe.db.svc")
+			assert.ErrorContains(t, err, "secrets of probes are")
+		}
+	})
+}
+
+func TestCredentials_Token(t *testing.T) {
+	ctx := context.Background()
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "probe-token"},
+		Data:       map[string][]byte{"token": []byte("static-token\n")},
+	}
+
+	var tokenRequests []*authenticationv1.TokenRequest
+	cl := fake.NewClientBuilder().
+		WithScheme(newTestScheme()).
+		WithObjects(tokenSecret).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(_ context.Context, _ client.Client, subResource string, obj client.Object, subResourceObj client.Object, _ ...client.SubResourceCreateOption) error {
+				assert.Equal(t, "token", subResource)
+				assert.Equal(t, "x-pdb", obj.GetName())
+				tr := subResourceObj.(*authenticationv1.TokenRequest)
+				tr.Status.Token = "sa-token"
+				tr.Status.ExpirationTimestamp = metav1.NewTime(time.Now().Add(time.Duration(*tr.Spec.ExpirationSeconds) * time.Second))
+				tokenRequests = append(tokenRequests, tr)
+				return nil
+			},
+		}).
+		Build()
+	creds := NewCredentials(cl, types.NamespacedName{Namespace: "x-pdb", Name: "x-pdb"}, []string{"probes.db"}, SecretAccess{Enabled: true})
+
+	t.Run("should return no token without auth", func(t *testing.T) {
+		token, err := creds.Token(ctx, "db", nil)
+		require.NoError(t, err)
+		assert.Empty(t, token)
+	})
+
+	t.Run("should read bearer token from secret", func(t *testing.T) {
+		token, err := creds.Token(ctx, "db", &xpdbv1alpha1.XPodDisruptionBudgetProbeAuth{
+			BearerToken: &xpdbv1alpha1.XPodDisruptionBudgetSecretKeyRef{Name: "probe-token", Key: "token"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "static-token", token)
+	})
+
+	t.Run("should request and cache service account token", func(t *testing.T) {
+		auth := &xpdbv1alpha1.XPodDisruptionBudgetProbeAuth{
+			ServiceAccountToken: &xpdbv1alpha1.XPodDisruptionBudgetProbeServiceAccountToken{Audience: "probes.db"},
+		}
+		for range 2 {
+			token, err := creds.Token(ctx, "db", auth)
+			require.NoError(t, err)
+			assert.Equal(t, "sa-token", token)
+		}
+		require.Len(t, tokenRequests, 1)
+		assert.Equal(t, []string{"probes.db"}, tokenRequests[0].Spec.Audiences)
+		assert.Equal(t, int64(defaultTokenExpirationSeconds), *tokenRequests[0].Spec.ExpirationSeconds)
+	})
+
+	t.Run("should reject audiences which are not allowed", func(t *testing.T) {
+		_, err := creds.Token(ctx, "db", &xpdbv1alpha1.XPodDisruptionBudgetProbeAuth{
+			ServiceAccountToken: &xpdbv1alpha1.XPodDisruptionBudgetProbeServiceAccountToken{
+				Audience: "https://kubernetes.default.svc.cluster.local",
+			},
+		})
+		assert.Error(t, err)
+		assert.Len(t, tokenRequests, 1)
+	})
+}
@@ -0,0 +1,173 @@
+package disruptionprobe
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	disruptionprobepb "github.com/form3tech-oss/x-pdb/pkg/proto/disruptionprobe/v1"
+	disruptionprobev2pb "github.com/form3tech-oss/x-pdb/pkg/proto/disruptionprobe/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/protobuf/encoding/protojson"
+	"k8s.io/utils/ptr"
+)
+
+// maxHTTPResponseSize limits the size of the responses read from HTTP probes.
+const maxHTTPResponseSize = 1 << 20
+
+// httpProber calls a HTTP probe.
+// The request is the IsDisruptionAllowedRequest encoded as JSON, sent as body or,
+// for GET requests, as query parameters. A 2xx response is decoded as
+// IsDisruptionAllowedResponse, an empty body allows the disruption.
+// A 429 response denies the disruption and may carry a Retry-After header.
+// Any other status is an error.
+// With protocol version v2 the v2 request and response are used instead, GET is not supported.
+type httpProber struct {
+	client  *http.Client
+	spec    *xpdbv1alpha1.XPodDisruptionBudgetHTTPProbe
+	version xpdbv1alpha1.ProbeProtocolVersion
+	// token is sent as bearer token if set.
+	token string
+}
+
+func (p *httpProber) IsDisruptionAllowed(ctx context.Context, req *disruptionprobev2pb.IsDisruptionAllowedRequest) (*Result, error) {
+	result, maxAge, err := p.isDisruptionAllowed(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	// max_age_seconds of v2 responses takes precedence over the Cache-Control header.
+	if result.MaxAge == nil {
+		result.MaxAge = maxAge
+	}
+	return result, nil
+}
+
+func (p *httpProber) isDisruptionAllowed(
+	ctx context.Context,
+	req *disruptionprobev2pb.IsDisruptionAllowedRequest,
+) (*Result, *time.Duration, error) {
+	httpReq, err := p.newRequest(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	maxAge := parseMaxAge(resp.Header.Get("Cache-Control"))
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read probe response: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &Result{Allowed: false, RetryAfter: time.Duration(retryAfter) * time.Second}, maxAge, nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, nil, fmt.Errorf("probe responded with %s", resp.Status)
+	case len(bytes.TrimSpace(data)) == 0:
+		return &Result{Allowed: true}, maxAge, nil
+	}
+
+	decoder := protojson.UnmarshalOptions{DiscardUnknown: true}
+	if p.version == xpdbv1alpha1.ProbeProtocolVersionV2 {
+		var probeResp disruptionprobev2pb.IsDisruptionAllowedResponse
+		if err := decoder.Unmarshal(data, &probeResp); err != nil {
+			return nil, nil, fmt.Errorf("unable to decode probe response: %w", err)
+		}
+		result, err := resultFromV2Response(&probeResp)
+		return result, maxAge, err
+	}
+
+	var probeResp disruptionprobepb.IsDisruptionAllowedResponse
+	if err := decoder.Unmarshal(data, &probeResp); err != nil {
+		return nil, nil, fmt.Errorf("unable to decode probe response: %w", err)
+	}
+	result, err := resultFromResponse(&probeResp)
+	return result, maxAge, err
+}
+
+func (p *httpProber) newRequest(ctx context.Context, req *disruptionprobev2pb.IsDisruptionAllowedRequest) (*http.Request, error) {
+	method := cmp.Or(p.spec.Method, http.MethodPost)
+
+	u, err := url.Parse(p.spec.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid probe url: %w", err)
+	}
+
+	var body io.Reader
+	switch {
+	case p.version == xpdbv1alpha1.ProbeProtocolVersionV2:
+		if method == http.MethodGet {
+			return nil, fmt.Errorf("method GET is not supported by protocol version v2")
+		}
+		data, err := protojson.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	case method == http.MethodGet:
+		v1 := toV1Request(req)
+		query := u.Query()
+		query.Set("podName", v1.PodName)
+		query.Set("podNamespace", v1.PodNamespace)
+		query.Set("xpdbName", v1.XpdbName)
+		query.Set("xpdbNamespace", v1.XpdbNamespace)
+		u.RawQuery = query.Encode()
+	default:
+		data, err := protojson.Marshal(toV1Request(req))
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	for _, h := range p.spec.Headers {
+		httpReq.Header.Set(h.Name, h.Value)
+	}
+	if p.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+	return httpReq, nil
+}
+
+// parseMaxAge returns the max-age of a Cache-Control header, no-cache and no-store disable caching.
+// It returns nil if the header sets neither.
+func parseMaxAge(cacheControl string) *time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-cache" || directive == "no-store":
+			return ptr.To(time.Duration(0))
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err != nil || seconds < 0 {
+				return ptr.To(time.Duration(0))
+			}
+			return ptr.To(time.Duration(seconds) * time.Second)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package disruptionprobe
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	disruptionprobev2pb "github.com/form3tech-oss/x-pdb/pkg/proto/disruptionprobe/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+)
+
+func TestHTTPProber(t *testing.T) {
+	req := &disruptionprobev2pb.IsDisruptionAllowedRequest{
+		Pod:       &disruptionprobev2pb.Pod{Name: "db-0", Namespace: "db", Labels: map[string]string{"app": "db"}},
+		Xpdb:      &disruptionprobev2pb.XPodDisruptionBudget{Name: "postgres", Namespace: "db"},
+		Operation: "CREATE",
+		ClusterId: "blue",
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		status     int
+		header     http.Header
+		body       string
+		wantResult *Result
+		wantErr    bool
+	}{
+		{
+			name:       "should allow disruption",
+			status:     http.StatusOK,
+			body:       `{"isAllowed": true}`,
+			wantResult: &Result{Allowed: true},
+		},
+		{
+			name:       "should allow disruption with empty body",
+			method:     http.MethodGet,
+			status:     http.StatusNoContent,
+			wantResult: &Result{Allowed: true},
+		},
+		{
+			name:       "should deny disruption",
+			status:     http.StatusOK,
+			body:       `{"isAllowed": false, "retryAfterSeconds": 30, "unknown": 1}`,
+			wantResult: &Result{Allowed: false, RetryAfter: 30 * time.Second},
+		},
+		{
+			name:       "should deny disruption on 429",
+			status:     http.StatusTooManyRequests,
+			header:     http.Header{"Retry-After": []string{"5"}},
+			wantResult: &Result{Allowed: false, RetryAfter: 5 * time.Second},
+		},
+		{
+			name:    "should error on probe error",
+			status:  http.StatusOK,
+			body:    `{"error": "raft leader unknown"}`,
+			wantErr: true,
+		},
+		{
+			name:    "should error on unexpected status",
+			status:  http.StatusInternalServerError,
+			wantErr: true,
+		},
+		{
+			name:    "should error on invalid response",
+			status:  http.StatusOK,
+			body:    `allowed`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "secret", r.Header.Get("X-Token"))
+				if r.Method == http.MethodGet {
+					assert.Equal(t, "db-0", r.URL.Query().Get("podName"))
+					assert.Equal(t, "postgres", r.URL.Query().Get("xpdbName"))
+				} else {
+					assert.Equal(t, http.MethodPost, r.Method)
+					var body map[string]string
+					assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+					assert.Equal(t, map[string]string{
+						"podName":       "db-0",
+						"podNamespace":  "db",
+						"xpdbName":      "postgres",
+						"xpdbNamespace": "db",
+					}, body)
+				}
+				for k, v := range tt.header {
+					w.Header()[k] = v
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			p := &httpProber{
+				client: srv.Client(),
+				spec: &xpdbv1alpha1.XPodDisruptionBudgetHTTPProbe{
+					URL:     srv.URL + "/disruption",
+					Method:  tt.method,
+					Headers: []xpdbv1alpha1.XPodDisruptionBudgetHTTPHeader{{Name: "X-Token", Value: "secret"}},
+				},
+			}
+			result, err := p.IsDisruptionAllowed(context.Background(), req)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantResult, result)
+		})
+	}
+}
+
+func TestHTTPProberV2(t *testing.T) {
+	req := &disruptionprobev2pb.IsDisruptionAllowedRequest{
+		Pod:       &disruptionprobev2pb.Pod{Name: "db-0", Namespace: "db"},
+		Xpdb:      &disruptionprobev2pb.XPodDisruptionBudget{Name: "postgres", Namespace: "db"},
+		Operation: "CREATE",
+		ClusterId: "blue",
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "CREATE", body["operation"])
+		assert.Equal(t, "blue", body["clusterId"])
+		assert.Equal(t, map[string]any{"name": "db-0", "namespace": "db"}, body["pod"])
+		_, _ = w.Write([]byte(`{"reason": "RaftLeader", "message": "db-0 is the leader", "preActivities": [{"name": "step-down"}]}`))
+	}))
+	defer srv.Close()
+
+	p := &httpProber{
+		client:  srv.Client(),
+		spec:    &xpdbv1alpha1.XPodDisruptionBudgetHTTPProbe{URL: srv.URL},
+		version: xpdbv1alpha1.ProbeProtocolVersionV2,
+	}
+	result, err := p.IsDisruptionAllowed(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, &Result{
+		Reason:        "RaftLeader",
+		Message:       "db-0 is the leader",
+		PreActivities: []PreActivity{{Name: "step-down"}},
+	}, result)
+
+	p.spec.Method = http.MethodGet
+	_, err = p.IsDisruptionAllowed(context.Background(), req)
+	assert.Error(t, err)
+}
+
+func TestHTTPProberMaxAge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Cache-Control", "private, max-age=30")
+		_, _ = w.Write([]byte(`{"isAllowed": true, "maxAgeSeconds": 10}`))
+	}))
+	defer srv.Close()
+
+	p := &httpProber{client: srv.Client(), spec: &xpdbv1alpha1.XPodDisruptionBudgetHTTPProbe{URL: srv.URL}}
+	result, err := p.IsDisruptionAllowed(context.Background(), &disruptionprobev2pb.IsDisruptionAllowedRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, ptr.To(30*time.Second), result.MaxAge)
+
+	p.version = xpdbv1alpha1.ProbeProtocolVersionV2
+	result, err = p.IsDisruptionAllowed(context.Background(), &disruptionprobev2pb.IsDisruptionAllowedRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, ptr.To(10*time.Second), result.MaxAge)
+}
+
+func TestParseMaxAge(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		want         *time.Duration
+	}{
+		{cacheControl: "", want: nil},
+		{cacheControl: "private", want: nil},
+		{cacheControl: "max-age=30", want: ptr.To(30 * time.Second)},
+		{cacheControl: "private, Max-Age=5", want: ptr.To(5 * time.Second)},
+		{cacheControl: "no-store", want: ptr.To(time.Duration(0))},
+		{cacheControl: "max-age=soon", want: ptr.To(time.Duration(0))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cacheControl, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseMaxAge(tt.cacheControl))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package disruptionprobe
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	disruptionprobepb "github.com/form3tech-oss/x-pdb/pkg/proto/disruptionprobe/v1"
+	disruptionprobev2pb "github.com/form3tech-oss/x-pdb/pkg/proto/disruptionprobe/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"k8s.io/utils/ptr"
+)
+
+// Prober asks a disruption probe whether a pod can be disrupted.
+// The request is a v2 request, probers speaking v1 send the subset known to v1.
+type Prober interface {
+	IsDisruptionAllowed(ctx context.Context, req *disruptionprobev2pb.IsDisruptionAllowedRequest) (*Result, error)
+}
+
+// Endpoint returns the grpc endpoint or the URL of the probe.
+func Endpoint(probe *xpdbv1alpha1.XPodDisruptionBudgetProbeSpec) string {
+	if probe.HTTP != nil {
+		return probe.HTTP.URL
+	}
+	return probe.Endpoint
+}
+
+// grpcProber calls a probe implementing the DisruptionProbeService.
+// Unless the version is set, v2 is negotiated: probes which don't implement v2
+// are called with v1, which is remembered as long as the client is pooled.
+type grpcProber struct {
+	client  *pooledClient
+	version xpdbv1alpha1.ProbeProtocolVersion
+	// token is sent as bearer token in the authorization metadata if set.
+	token string
+}
+
+func (p *grpcProber) IsDisruptionAllowed(ctx context.Context, req *disruptionprobev2pb.IsDisruptionAllowedRequest) (*Result, error) {
+	if p.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+p.token)
+	}
+
+	if p.version != xpdbv1alpha1.ProbeProtocolVersionV1 && !p.client.v1Only.Load() {
+		resp, err := disruptionprobev2pb.NewDisruptionProbeServiceClient(p.client.conn).IsDisruptionAllowed(ctx, req)
+		if status.Code(err) != codes.Unimplemented || p.version == xpdbv1alpha1.ProbeProtocolVersionV2 {
+			if err != nil {
+				return nil, err
+			}
+			return resultFromV2Response(resp)
+		}
+		p.client.v1Only.Store(true)
+	}
+
+	resp, err := disruptionprobepb.NewDisruptionProbeServiceClient(p.client.conn).IsDisruptionAllowed(ctx, toV1Request(req))
+	if err != nil {
+		return nil, err
+	}
+	return resultFromResponse(resp)
+}
+
+// toV1Request returns the subset of the request known to v1.
+func toV1Request(req *disruptionprobev2pb.IsDisruptionAllowedRequest) *disruptionprobepb.IsDisruptionAllowedRequest {
+	return &disruptionprobepb.IsDisruptionAllowedRequest{
+		PodName:       req.GetPod().GetName(),
+		PodNamespace:  req.GetPod().GetNamespace(),
+		XpdbName:      req.GetXpdb().GetName(),
+		XpdbNamespace: req.GetXpdb().GetNamespace(),
+	}
+}
+
+func resultFromResponse(resp *disruptionprobepb.IsDisruptionAllowedResponse) (*Result, error) {
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	return &Result{
+		Allowed:    resp.IsAllowed,
+		RetryAfter: time.Duration(resp.RetryAfterSeconds) * time.Second,
+	}, nil
+}
+
+func resultFromV2Response(resp *disruptionprobev2pb.IsDisruptionAllowedResponse) (*Result, error) {
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	result := &Result{
+		Allowed:    resp.IsAllowed,
+		RetryAfter: time.Duration(resp.RetryAfterSeconds) * time.Second,
+		Reason:     resp.Reason,
+		Message:    resp.Message,
+	}
+	for _, pa := range resp.PreActivities {
+		result.PreActivities = append(result.PreActivities, PreActivity{Name: pa.Name, Description: pa.Description})
+	}
+	if resp.MaxAgeSeconds != nil {
+		result.MaxAge = ptr.To(time.Duration(*resp.MaxAgeSeconds) * time.Second)
+	}
+	return result, nil
+}
@@ -0,0 +1,122 @@
+package disruptionprobe
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	disruptionprobepb "github.com/form3tech-oss/x-pdb/pkg/proto/disruptionprobe/v1"
+	disruptionprobev2pb "github.com/form3tech-oss/x-pdb/pkg/proto/disruptionprobe/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type v1Probe struct {
+	disruptionprobepb.UnimplementedDisruptionProbeServiceServer
+	calls int
+}
+
+func (p *v1Probe) IsDisruptionAllowed(
+	_ context.Context,
+	req *disruptionprobepb.IsDisruptionAllowedRequest,
+) (*disruptionprobepb.IsDisruptionAllowedResponse, error) {
+	p.calls++
+	return &disruptionprobepb.IsDisruptionAllowedResponse{IsAllowed: req.PodName == "db-0"}, nil
+}
+
+type v2Probe struct {
+	disruptionprobev2pb.UnimplementedDisruptionProbeServiceServer
+	calls int
+}
+
+func (p *v2Probe) IsDisruptionAllowed(
+	_ context.Context,
+	req *disruptionprobev2pb.IsDisruptionAllowedRequest,
+) (*disruptionprobev2pb.IsDisruptionAllowedResponse, error) {
+	p.calls++
+	return &disruptionprobev2pb.IsDisruptionAllowedResponse{
+		Reason:        "ClusterBusy",
+		Message:       "cluster " + req.ClusterId + " is rebalancing",
+		PreActivities: []*disruptionprobev2pb.PreActivity{{Name: "drain"}},
+	}, nil
+}
+
+func startProbe(t *testing.T, register func(*grpc.Server)) *pooledClient {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	register(srv)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return &pooledClient{conn: conn}
+}
+
+func TestGRPCProberNegotiation(t *testing.T) {
+	ctx := context.Background()
+	req := &disruptionprobev2pb.IsDisruptionAllowedRequest{
+		Pod:       &disruptionprobev2pb.Pod{Name: "db-0", Namespace: "db"},
+		Xpdb:      &disruptionprobev2pb.XPodDisruptionBudget{Name: "postgres", Namespace: "db"},
+		ClusterId: "blue",
+	}
+
+	t.Run("should fall back to v1 and remember it", func(t *testing.T) {
+		probe := &v1Probe{}
+		client := startProbe(t, func(s *grpc.Server) { disruptionprobepb.RegisterDisruptionProbeServiceServer(s, probe) })
+		p := &grpcProber{client: client}
+
+		for range 2 {
+			result, err := p.IsDisruptionAllowed(ctx, req)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+		}
+		assert.Equal(t, 2, probe.calls)
+		assert.True(t, client.v1Only.Load())
+	})
+
+	t.Run("should call v2", func(t *testing.T) {
+		probe := &v2Probe{}
+		client := startProbe(t, func(s *grpc.Server) { disruptionprobev2pb.RegisterDisruptionProbeServiceServer(s, probe) })
+		p := &grpcProber{client: client}
+
+		result, err := p.IsDisruptionAllowed(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, &Result{
+			Reason:        "ClusterBusy",
+			Message:       "cluster blue is rebalancing",
+			PreActivities: []PreActivity{{Name: "drain"}},
+		}, result)
+		assert.False(t, client.v1Only.Load())
+	})
+
+	t.Run("should not fall back if v2 is required", func(t *testing.T) {
+		client := startProbe(t, func(s *grpc.Server) { disruptionprobepb.RegisterDisruptionProbeServiceServer(s, &v1Probe{}) })
+		p := &grpcProber{client: client, version: xpdbv1alpha1.ProbeProtocolVersionV2}
+
+		_, err := p.IsDisruptionAllowed(ctx, req)
+		assert.Error(t, err)
+	})
+
+	t.Run("should call v1 if required", func(t *testing.T) {
+		probe := &v2Probe{}
+		client := startProbe(t, func(s *grpc.Server) {
+			disruptionprobepb.RegisterDisruptionProbeServiceServer(s, &v1Probe{})
+			disruptionprobev2pb.RegisterDisruptionProbeServiceServer(s, probe)
+		})
+		p := &grpcProber{client: client, version: xpdbv1alpha1.ProbeProtocolVersionV1}
+
+		result, err := p.IsDisruptionAllowed(ctx, req)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Zero(t, probe.calls)
+	})
+}
@@ -1,61 +1,407 @@
 package disruptionprobe
 
 import (
+	"cmp"
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
-	disruptionprobepb "github.com/form3tech-oss/x-pdb/pkg/proto/disruptionprobe/v1"
+	"github.com/form3tech-oss/x-pdb/internal/tracing"
+	disruptionprobev2pb "github.com/form3tech-oss/x-pdb/pkg/proto/disruptionprobe/v2"
 	"github.com/go-logr/logr"
+	"github.com/sourcegraph/conc/pool"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 )
 
+// probeTimeout is the default timeout of a single call of a probe.
 var probeTimeout = 2 * time.Second
 
+// probeRetryBackoff is the delay between retries of a probe, it grows linearly with every attempt.
+// The validation of the probe timeouts in the XPDB CRD accounts for it.
+var probeRetryBackoff = 100 * time.Millisecond
+
+// Result is the response of the disruption probes.
+type Result struct {
+	// Allowed is true if the probes allow the disruption.
+	Allowed bool
+	// RetryAfter is the delay suggested by the probe before a denied disruption is attempted again.
+	RetryAfter time.Duration
+	// Probe names the probe which blocked the disruption.
+	// If the probes are combined with ProbeModeAny, it names all of them.
+	Probe string
+	// Endpoint is the endpoint or URL of the probe which blocked the disruption.
+	Endpoint string
+	// Reason and Message explain the verdict of probes speaking protocol v2.
+	Reason  string
+	Message string
+	// PreActivities the workload wants performed before the pod is disrupted.
+	PreActivities []PreActivity
+	// MaxAge is the time the verdict of the probe may be cached for, nil if the probe didn't set it.
+	MaxAge *time.Duration
+	// Denials are the verdicts of the probes which denied the disruption, each naming its probe.
+	Denials []*Result
+
+	mode xpdbv1alpha1.ProbeMode
+}
+
+// Ignore returns the verdict of the probes as if the probes of the denials ignore returns true for had allowed the disruption.
+func (r *Result) Ignore(ignore func(denial *Result) bool) *Result {
+	if r.Allowed {
+		return r
+	}
+	outcomes := make([]outcome, 0, len(r.Denials))
+	for _, d := range r.Denials {
+		o := outcome{name: d.Probe, endpoint: d.Endpoint, result: d}
+		if ignore(d) {
+			o.result = &Result{Allowed: true}
+		}
+		outcomes = append(outcomes, o)
+	}
+	// outcomes without errors are always combined into a verdict.
+	result, _ := combine(r.mode, outcomes)
+	return result
+}
+
+// PreActivity is an activity a probe wants performed before the pod is disrupted.
+type PreActivity struct {
+	Name        string
+	Description string
+}
+
+// Disruption describes the admission request disrupting a pod.
+// It is sent to probes speaking protocol v2.
+type Disruption struct {
+	Operation   string
+	SubResource string
+	DryRun      bool
+	User        authenticationv1.UserInfo
+	// Expected, Healthy and DesiredHealthy are the pod counts of the XPDB across all clusters.
+	Expected       int32
+	Healthy        int32
+	DesiredHealthy int32
+}
+
+// ProbeError is returned if a probe with failure policy Fail couldn't be called.
+type ProbeError struct {
+	Probe    string
+	Endpoint string
+	Err      error
+}
+
+func (e *ProbeError) Error() string {
+	return fmt.Sprintf("probe %s: %s", e.Probe, e.Err)
+}
+
+func (e *ProbeError) Unwrap() error {
+	return e.Err
+}
+
+// outcome is the result of a single probe.
+type outcome struct {
+	result   *Result
+	err      error
+	skipped  bool
+	name     string
+	endpoint string
+}
+
 type Service struct {
-	clientPool *ClientPool
-	logger     *logr.Logger
+	clientPool  *ClientPool
+	credentials *Credentials
+	logger      *logr.Logger
+	clusterID   string
+	cache       *resultCache
 }
 
+// NewService creates a new Service.
+// Verdicts of probes are cached for cacheTTL unless the probe sets a max-age, 0 disables caching.
+// Concurrent calls of a probe for the same pod and disruption are coalesced regardless of the cacheTTL.
 func NewService(
 	logger *logr.Logger,
 	clientPool *ClientPool,
+	creds *Credentials,
+	clusterID string,
+	cacheTTL time.Duration,
 ) *Service {
 	return &Service{
-		logger:     logger,
-		clientPool: clientPool,
+		logger:      logger,
+		clientPool:  clientPool,
+		credentials: creds,
+		clusterID:   clusterID,
+		cache:       newResultCache(cacheTTL),
 	}
 }
 
-func (s *Service) CanPodBeDisrupted(ctx context.Context, pod *corev1.Pod, xpdb *xpdbv1alpha1.XPodDisruptionBudget) (bool, error) {
-	if xpdb.Spec.Probe == nil {
-		return true, nil
+// Probes returns the enabled probes of the xpdb.
+func Probes(xpdb *xpdbv1alpha1.XPodDisruptionBudget) []*xpdbv1alpha1.XPodDisruptionBudgetProbeSpec {
+	var probes []*xpdbv1alpha1.XPodDisruptionBudgetProbeSpec
+	if xpdb.Spec.Probe != nil {
+		probes = append(probes, xpdb.Spec.Probe)
 	}
+	for i := range xpdb.Spec.Probes {
+		probes = append(probes, &xpdb.Spec.Probes[i])
+	}
+	return slices.DeleteFunc(probes, func(p *xpdbv1alpha1.XPodDisruptionBudgetProbeSpec) bool {
+		return p.Enabled != nil && !*p.Enabled
+	})
+}
+
+// Name returns the name of the probe.
+func Name(probe *xpdbv1alpha1.XPodDisruptionBudgetProbeSpec) string {
+	return cmp.Or(probe.Name, Endpoint(probe))
+}
 
-	c, err := s.clientPool.Get(xpdb.Spec.Probe.Endpoint)
+// CanPodBeDisrupted calls all probes of the xpdb concurrently and combines their verdicts according to the probe mode.
+// Probes with failure policy Ignore which couldn't be called are skipped.
+func (s *Service) CanPodBeDisrupted(
+	ctx context.Context,
+	pod *corev1.Pod,
+	xpdb *xpdbv1alpha1.XPodDisruptionBudget,
+	disruption *Disruption,
+) (result *Result, err error) {
+	probes := Probes(xpdb)
+	if len(probes) == 0 {
+		return &Result{Allowed: true}, nil
+	}
+
+	ctx, span := tracing.Start(ctx, "disruptionprobe.Service.CanPodBeDisrupted",
+		tracing.AttributeNamespace.String(pod.Namespace),
+		tracing.AttributePodName.String(pod.Name),
+		tracing.AttributeXPDBName.String(xpdb.Name))
+	defer func() {
+		if result != nil {
+			span.SetAttributes(tracing.AttributeDisruptionAllowed.Bool(result.Allowed))
+		}
+		tracing.End(span, err)
+	}()
+
+	req := s.newRequest(pod, xpdb, disruption)
+
+	outcomes := make([]outcome, len(probes))
+	p := pool.New().WithMaxGoroutines(len(probes))
+	for i, probe := range probes {
+		p.Go(func() {
+			outcomes[i] = s.call(ctx, xpdb, probe, pod, req)
+		})
+	}
+	p.Wait()
+
+	return combine(xpdb.Spec.ProbeMode, outcomes)
+}
+
+// newRequest returns the v2 request of the disruption of the pod.
+func (s *Service) newRequest(
+	pod *corev1.Pod,
+	xpdb *xpdbv1alpha1.XPodDisruptionBudget,
+	disruption *Disruption,
+) *disruptionprobev2pb.IsDisruptionAllowedRequest {
+	req := &disruptionprobev2pb.IsDisruptionAllowedRequest{
+		Pod: &disruptionprobev2pb.Pod{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			Labels:    pod.Labels,
+			NodeName:  pod.Spec.NodeName,
+		},
+		Xpdb:      &disruptionprobev2pb.XPodDisruptionBudget{Name: xpdb.Name, Namespace: xpdb.Namespace},
+		ClusterId: s.clusterID,
+	}
+	if disruption != nil {
+		req.Operation = disruption.Operation
+		req.SubResource = disruption.SubResource
+		req.DryRun = disruption.DryRun
+		req.User = &disruptionprobev2pb.UserInfo{
+			Username: disruption.User.Username,
+			Uid:      disruption.User.UID,
+			Groups:   disruption.User.Groups,
+		}
+		req.Counts = &disruptionprobev2pb.PodCounts{
+			Expected:       disruption.Expected,
+			Healthy:        disruption.Healthy,
+			DesiredHealthy: disruption.DesiredHealthy,
+		}
+	}
+	return req
+}
+
+// call calls the probe of the pod unless its verdict is cached.
+func (s *Service) call(
+	ctx context.Context,
+	xpdb *xpdbv1alpha1.XPodDisruptionBudget,
+	probe *xpdbv1alpha1.XPodDisruptionBudgetProbeSpec,
+	pod *corev1.Pod,
+	req *disruptionprobev2pb.IsDisruptionAllowedRequest,
+) outcome {
+	o := outcome{name: Name(probe), endpoint: Endpoint(probe)}
+
+	endpoint, err := RenderEndpoint(probe, pod)
+	if err == nil {
+		o.endpoint = endpoint
+	}
+
+	ctx, span := tracing.Start(ctx, "disruptionprobe.Service.call", tracing.AttributeEndpoint.String(o.endpoint))
+	defer func() { tracing.End(span, o.err) }()
+
+	if err != nil {
+		o.err = err
+	} else {
+		key := callKey{
+			cacheKey: cacheKey{
+				xpdbNamespace:  xpdb.Namespace,
+				xpdbName:       xpdb.Name,
+				xpdbGeneration: xpdb.Generation,
+				probe:          o.name,
+				podName:        pod.Name,
+				podUID:         pod.UID,
+				dryRun:         req.GetDryRun(),
+			},
+			operation:   req.GetOperation(),
+			subResource: req.GetSubResource(),
+			username:    req.GetUser().GetUsername(),
+		}
+		var cached bool
+		o.result, cached, o.err = s.cache.do(ctx, key, func(ctx context.Context) (*Result, error) {
+			return s.probe(ctx, probe, endpoint, req)
+		})
+		span.SetAttributes(tracing.AttributeProbeCached.Bool(cached))
+	}
+
+	if o.err != nil && probe.FailurePolicy == xpdbv1alpha1.ProbeFailurePolicyIgnore {
+		s.logger.Info("ignoring failed disruption probe", "probe", o.name, "error", o.err.Error())
+		o.skipped = true
+	}
+	return o
+}
+
+// probe calls the probe at the rendered endpoint, retrying failed calls.
+func (s *Service) probe(
+	ctx context.Context,
+	probe *xpdbv1alpha1.XPodDisruptionBudgetProbeSpec,
+	endpoint string,
+	req *disruptionprobev2pb.IsDisruptionAllowedRequest,
+) (result *Result, err error) {
+	prober, err := s.prober(ctx, probe, req.GetXpdb().GetNamespace(), endpoint)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	req := &disruptionprobepb.IsDisruptionAllowedRequest{
-		PodName:       pod.Name,
-		PodNamespace:  pod.Namespace,
-		XpdbName:      xpdb.Name,
-		XpdbNamespace: xpdb.Namespace,
+	timeout := probeTimeout
+	if probe.Timeout != nil {
+		timeout = probe.Timeout.Duration
 	}
+	for attempt := 0; attempt <= int(probe.Retries); attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				// the deadline of the probe phase passed, report the error of the last attempt.
+				return result, err
+			case <-time.After(time.Duration(attempt) * probeRetryBackoff):
+			}
+		}
 
-	cctx, cancel := context.WithTimeout(ctx, probeTimeout)
-	defer cancel()
+		cctx, cancel := context.WithTimeout(ctx, timeout)
+		result, err = prober.IsDisruptionAllowed(cctx, req)
+		cancel()
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+		s.logger.V(1).Info("disruption probe failed", "probe", Name(probe), "attempt", attempt, "error", err.Error())
+	}
+	return result, err
+}
+
+// combine combines the outcomes of the probes according to the mode.
+func combine(mode xpdbv1alpha1.ProbeMode, outcomes []outcome) (*Result, error) {
+	var denied []outcome
+	var errs []error
+	for _, o := range outcomes {
+		switch {
+		case o.skipped:
+		case o.err != nil:
+			errs = append(errs, &ProbeError{Probe: o.name, Endpoint: o.endpoint, Err: o.err})
+		case o.result.Allowed:
+			if mode == xpdbv1alpha1.ProbeModeAny {
+				return &Result{Allowed: true}, nil
+			}
+		default:
+			denied = append(denied, o)
+		}
+	}
 
-	resp, err := c.IsDisruptionAllowed(cctx, req)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(denied) == 0 {
+		// all probes allowed the disruption or were skipped.
+		return &Result{Allowed: true}, nil
+	}
+	// the first probe which denied the disruption explains the verdict,
+	// the pre-activities of all of them have to be performed.
+	result := &Result{
+		Allowed:    false,
+		RetryAfter: denied[0].result.RetryAfter,
+		Probe:      denied[0].name,
+		Endpoint:   denied[0].endpoint,
+		Reason:     denied[0].result.Reason,
+		Message:    denied[0].result.Message,
+		mode:       mode,
+	}
+	for _, o := range denied {
+		result.PreActivities = append(result.PreActivities, o.result.PreActivities...)
+		// the verdicts of the probes may be cached, hence they are copied.
+		denial := *o.result
+		denial.Probe, denial.Endpoint, denial.Denials = o.name, o.endpoint, nil
+		result.Denials = append(result.Denials, &denial)
+	}
+	if mode != xpdbv1alpha1.ProbeModeAny {
+		return result, nil
+	}
+
+	// none of the probes allowed the disruption, retry once the first of them may allow it.
+	var names []string
+	for _, o := range denied {
+		names = append(names, o.name)
+		if result.RetryAfter == 0 || (o.result.RetryAfter > 0 && o.result.RetryAfter < result.RetryAfter) {
+			result.RetryAfter = o.result.RetryAfter
+		}
+	}
+	result.Probe = strings.Join(names, ", ")
+	return result, nil
+}
+
+// prober returns the Prober of the probe type configured in the spec calling the rendered endpoint.
+// namespace is the namespace of the XPDB the referenced Secrets are read from.
+func (s *Service) prober(
+	ctx context.Context,
+	probe *xpdbv1alpha1.XPodDisruptionBudgetProbeSpec,
+	namespace string,
+	endpoint string,
+) (Prober, error) {
+	token, err := s.credentials.Token(ctx, namespace, probe.Auth)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	if resp.Error != "" {
-		return false, errors.New(resp.Error)
+	if probe.HTTP != nil {
+		if token != "" && !strings.HasPrefix(endpoint, "https://") {
+			return nil, fmt.Errorf("bearer tokens are only sent to https probes")
+		}
+		c, err := s.clientPool.HTTPClient(ctx, namespace, probe.TLS)
+		if err != nil {
+			return nil, err
+		}
+		spec := probe.HTTP.DeepCopy()
+		spec.URL = endpoint
+		return &httpProber{client: c, spec: spec, version: probe.ProtocolVersion, token: token}, nil
 	}
 
-	return resp.IsAllowed, nil
+	c, err := s.clientPool.get(ctx, endpoint, namespace, probe.TLS)
+	if err != nil {
+		return nil, err
+	}
+	return &grpcProber{client: c, version: probe.ProtocolVersion, token: token}, nil
 }
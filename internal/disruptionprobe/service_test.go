@@ -0,0 +1,147 @@
+package disruptionprobe
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/ptr"
+)
+
+func TestCombine(t *testing.T) {
+	allowed := func(name string) outcome {
+		return outcome{name: name, endpoint: name + ":8080", result: &Result{Allowed: true}}
+	}
+	denied := func(name string, retryAfter time.Duration) outcome {
+		return outcome{name: name, endpoint: name + ":8080", result: &Result{RetryAfter: retryAfter}}
+	}
+	failed := func(name string) outcome {
+		return outcome{name: name, endpoint: name + ":8080", err: errors.New("connection refused")}
+	}
+	ignored := func(name string) outcome {
+		o := failed(name)
+		o.skipped = true
+		return o
+	}
+
+	tests := []struct {
+		name       string
+		mode       xpdbv1alpha1.ProbeMode
+		outcomes   []outcome
+		wantResult *Result
+		wantErr    bool
+	}{
+		{
+			name:       "all should allow if all probes allow",
+			outcomes:   []outcome{allowed("raft"), allowed("backup")},
+			wantResult: &Result{Allowed: true},
+		},
+		{
+			name:       "all should name the first probe which denied",
+			mode:       xpdbv1alpha1.ProbeModeAll,
+			outcomes:   []outcome{allowed("raft"), denied("backup", time.Minute), denied("lag", 0)},
+			wantResult: &Result{Allowed: false, RetryAfter: time.Minute, Probe: "backup", Endpoint: "backup:8080"},
+		},
+		{
+			name:     "all should error if a probe failed",
+			outcomes: []outcome{denied("raft", 0), failed("backup")},
+			wantErr:  true,
+		},
+		{
+			name:       "all should skip ignored probes",
+			outcomes:   []outcome{allowed("raft"), ignored("backup")},
+			wantResult: &Result{Allowed: true},
+		},
+		{
+			name:       "any should allow if one probe allows",
+			mode:       xpdbv1alpha1.ProbeModeAny,
+			outcomes:   []outcome{denied("raft", 0), failed("backup"), allowed("lag")},
+			wantResult: &Result{Allowed: true},
+		},
+		{
+			name:       "any should name all probes if none allowed",
+			mode:       xpdbv1alpha1.ProbeModeAny,
+			outcomes:   []outcome{denied("raft", 0), denied("backup", time.Minute), denied("lag", 30*time.Second)},
+			wantResult: &Result{Allowed: false, RetryAfter: 30 * time.Second, Probe: "raft, backup, lag", Endpoint: "raft:8080"},
+		},
+		{
+			name:     "any should error if no probe allowed and one failed",
+			mode:     xpdbv1alpha1.ProbeModeAny,
+			outcomes: []outcome{denied("raft", 0), failed("backup")},
+			wantErr:  true,
+		},
+		{
+			name:       "any should allow if all probes were ignored",
+			mode:       xpdbv1alpha1.ProbeModeAny,
+			outcomes:   []outcome{ignored("raft")},
+			wantResult: &Result{Allowed: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := combine(tt.mode, tt.outcomes)
+			if tt.wantErr {
+				var probeErr *ProbeError
+				assert.ErrorAs(t, err, &probeErr)
+				assert.Equal(t, "backup", probeErr.Probe)
+				return
+			}
+			assert.NoError(t, err)
+			result.Denials, result.mode = nil, ""
+			assert.Equal(t, tt.wantResult, result)
+		})
+	}
+}
+
+func TestResult_Ignore(t *testing.T) {
+	raft := outcome{name: "raft", endpoint: "raft:8080", result: &Result{
+		PreActivities: []PreActivity{{Name: "transfer-leadership"}},
+	}}
+	backup := outcome{name: "backup", endpoint: "backup:8080", result: &Result{RetryAfter: time.Minute}}
+	ignoreRaft := func(denial *Result) bool { return denial.Probe == "raft" }
+
+	t.Run("all should keep denials of other probes", func(t *testing.T) {
+		result, err := combine(xpdbv1alpha1.ProbeModeAll, []outcome{raft, backup})
+		assert.NoError(t, err)
+		assert.Equal(t, "raft", result.Probe)
+
+		result = result.Ignore(ignoreRaft)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, "backup", result.Probe)
+		assert.Equal(t, time.Minute, result.RetryAfter)
+		assert.Empty(t, result.PreActivities)
+	})
+
+	t.Run("all should allow if all denials are ignored", func(t *testing.T) {
+		result, err := combine(xpdbv1alpha1.ProbeModeAll, []outcome{raft})
+		assert.NoError(t, err)
+		assert.True(t, result.Ignore(ignoreRaft).Allowed)
+	})
+
+	t.Run("any should allow if one denial is ignored", func(t *testing.T) {
+		result, err := combine(xpdbv1alpha1.ProbeModeAny, []outcome{raft, backup})
+		assert.NoError(t, err)
+		assert.True(t, result.Ignore(ignoreRaft).Allowed)
+	})
+}
+
+func TestProbes(t *testing.T) {
+	xpdb := &xpdbv1alpha1.XPodDisruptionBudget{
+		Spec: xpdbv1alpha1.XPodDisruptionBudgetSpec{
+			Probe: &xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{Endpoint: "legacy:8080"},
+			Probes: []xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{
+				{Name: "raft", Endpoint: "raft:8080"},
+				{Name: "disabled", Endpoint: "disabled:8080", Enabled: ptr.To(false)},
+				{HTTP: &xpdbv1alpha1.XPodDisruptionBudgetHTTPProbe{URL: "http://backup/disruption"}},
+			},
+		},
+	}
+
+	var names []string
+	for _, p := range Probes(xpdb) {
+		names = append(names, Name(p))
+	}
+	assert.Equal(t, []string{"legacy:8080", "raft", "http://backup/disruption"}, names)
+}
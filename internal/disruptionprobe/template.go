@@ -0,0 +1,80 @@
+package disruptionprobe
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// EndpointData is the data available in templated probe endpoints.
+type EndpointData struct {
+	PodName      string
+	PodNamespace string
+	PodIP        string
+	NodeName     string
+	// Hostname is the hostname of the pod, it defaults to the pod name.
+	Hostname string
+	// ServiceName is the subdomain of the pod, i.e. the headless service of a StatefulSet.
+	ServiceName string
+}
+
+// IsTemplate returns true if the endpoint or URL of the probe is a template over the candidate pod.
+func IsTemplate(probe *xpdbv1alpha1.XPodDisruptionBudgetProbeSpec) bool {
+	return strings.Contains(Endpoint(probe), "{{")
+}
+
+// RenderEndpoint renders the endpoint or URL of the probe for the pod,
+// e.g. "{{.PodIP}}:9090", "{{.Hostname}}.{{.ServiceName}}:9090" or "{{.PodIP}}:{{port "probe"}}"
+// where port resolves a named container port of the pod.
+func RenderEndpoint(probe *xpdbv1alpha1.XPodDisruptionBudgetProbeSpec, pod *corev1.Pod) (string, error) {
+	endpoint := Endpoint(probe)
+	if !IsTemplate(probe) {
+		return endpoint, nil
+	}
+
+	tmpl, err := template.New("endpoint").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"port": func(name string) (string, error) { return containerPort(pod, name) }}).
+		Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid probe endpoint template: %w", err)
+	}
+
+	if pod.Status.PodIP == "" && strings.Contains(endpoint, ".PodIP") {
+		return "", fmt.Errorf("pod %s/%s has no IP", pod.Namespace, pod.Name)
+	}
+
+	data := EndpointData{
+		PodName:      pod.Name,
+		PodNamespace: pod.Namespace,
+		PodIP:        pod.Status.PodIP,
+		NodeName:     pod.Spec.NodeName,
+		Hostname:     pod.Spec.Hostname,
+		ServiceName:  pod.Spec.Subdomain,
+	}
+	if data.Hostname == "" {
+		data.Hostname = pod.Name
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("unable to render probe endpoint: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// containerPort returns the number of the named container port of the pod.
+func containerPort(pod *corev1.Pod, name string) (string, error) {
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == name {
+				return strconv.Itoa(int(p.ContainerPort)), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("pod %s/%s has no container port %q", pod.Namespace, pod.Name, name)
+}
@@ -0,0 +1,93 @@
+package disruptionprobe
+
+import (
+	"testing"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRenderEndpoint(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "postgres-0"},
+		Spec: corev1.PodSpec{
+			Subdomain: "postgres",
+			NodeName:  "node-a",
+			Containers: []corev1.Container{
+				{Name: "postgres", Ports: []corev1.ContainerPort{{Name: "sql", ContainerPort: 5432}}},
+				{Name: "agent", Ports: []corev1.ContainerPort{{Name: "probe", ContainerPort: 9090}}},
+			},
+		},
+		Status: corev1.PodStatus{PodIP: "10.0.0.12"},
+	}
+
+	tests := []struct {
+		name    string
+		probe   *xpdbv1alpha1.XPodDisruptionBudgetProbeSpec
+		pod     *corev1.Pod
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "should pass static endpoints unchanged",
+			probe: &xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{Endpoint: "probe.db.svc:8080"},
+			want:  "probe.db.svc:8080",
+		},
+		{
+			name:  "should render pod ip",
+			probe: &xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{Endpoint: "{{.PodIP}}:9090"},
+			want:  "10.0.0.12:9090",
+		},
+		{
+			name:  "should render hostname of statefulset pods",
+			probe: &xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{Endpoint: "{{.Hostname}}.{{.ServiceName}}.{{.PodNamespace}}.svc:9090"},
+			want:  "postgres-0.postgres.db.svc:9090",
+		},
+		{
+			name:  "should render named container port",
+			probe: &xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{Endpoint: `{{.PodIP}}:{{port "probe"}}`},
+			want:  "10.0.0.12:9090",
+		},
+		{
+			name: "should render http url",
+			probe: &xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{
+				HTTP: &xpdbv1alpha1.XPodDisruptionBudgetHTTPProbe{URL: `http://{{.PodIP}}:{{port "probe"}}/disruption`},
+			},
+			want: "http://10.0.0.12:9090/disruption",
+		},
+		{
+			name:    "should error on unknown container port",
+			probe:   &xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{Endpoint: `{{.PodIP}}:{{port "metrics"}}`},
+			wantErr: true,
+		},
+		{
+			name:    "should error on unknown field",
+			probe:   &xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{Endpoint: "{{.PodUID}}:9090"},
+			wantErr: true,
+		},
+		{
+			name:    "should error if pod has no ip",
+			probe:   &xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{Endpoint: "{{.PodIP}}:9090"},
+			pod:     &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "postgres-1"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := pod
+			if tt.pod != nil {
+				p = tt.pod
+			}
+			got, err := RenderEndpoint(tt.probe, p)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package disruptionrequests
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	"github.com/form3tech-oss/x-pdb/internal/preactivities"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// pollInterval is the interval pending requests are retried at.
+var pollInterval = 10 * time.Second
+
+// Controller evicts the pods of DisruptionRequests through the Eviction API once
+// their pre-activities completed. The evictions pass the validation webhook,
+// hence they are only performed if the XPDB allows them.
+// Finished requests are deleted once they are older than the ttl.
+type Controller struct {
+	logger               logr.Logger
+	cli                  client.Client
+	preactivitiesService *preactivities.Service
+	ttl                  time.Duration
+	now                  func() time.Time
+}
+
+// NewController creates a new Controller.
+func NewController(
+	logger logr.Logger,
+	cli client.Client,
+	preactivitiesService *preactivities.Service,
+	ttl time.Duration,
+) *Controller {
+	return &Controller{
+		logger:               logger,
+		cli:                  cli,
+		preactivitiesService: preactivitiesService,
+		ttl:                  ttl,
+		now:                  time.Now,
+	}
+}
+
+// SetupWithManager registers the controller for DisruptionRequests.
+func (c *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("disruption-requests").
+		For(&xpdbv1alpha1.DisruptionRequest{}).
+		Complete(c)
+}
+
+func (c *Controller) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	logger := c.logger.WithValues("disruptionRequest", req.Name, "namespace", req.Namespace)
+
+	var dr xpdbv1alpha1.DisruptionRequest
+	if err := c.cli.Get(ctx, req.NamespacedName, &dr); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+	now := c.now()
+
+	if dr.Status.Phase.IsFinished() {
+		expiresAt := finishedAt(&dr).Add(c.ttl)
+		if now.Before(expiresAt) {
+			return reconcile.Result{RequeueAfter: expiresAt.Sub(now)}, nil
+		}
+		return reconcile.Result{}, client.IgnoreNotFound(c.cli.Delete(ctx, &dr, client.Preconditions{UID: &dr.UID}))
+	}
+
+	var pod corev1.Pod
+	err := c.cli.Get(ctx, client.ObjectKey{Namespace: dr.Namespace, Name: dr.Spec.PodName}, &pod)
+	if client.IgnoreNotFound(err) != nil {
+		return reconcile.Result{}, err
+	}
+	if apierrors.IsNotFound(err) || pod.UID != dr.Spec.PodUID || !pod.DeletionTimestamp.IsZero() {
+		return c.setPhase(ctx, &dr, xpdbv1alpha1.DisruptionRequestPhaseCanceled, "pod no longer exists", 0)
+	}
+
+	if !now.Before(dr.Spec.ExpiresAt.Time) {
+		return c.setPhase(ctx, &dr, xpdbv1alpha1.DisruptionRequestPhaseExpired,
+			fmt.Sprintf("pod was not evicted before %s", dr.Spec.ExpiresAt.UTC().Format(time.RFC3339)), 0)
+	}
+
+	if blocking := c.preactivitiesService.BlockingPreActivities(&pod); len(blocking) > 0 {
+		return c.setPhase(ctx, &dr, xpdbv1alpha1.DisruptionRequestPhasePending,
+			fmt.Sprintf("waiting for pre-activities: %s", strings.Join(blocking, ", ")), pollInterval)
+	}
+
+	eviction := &policyv1.Eviction{
+		ObjectMeta:    metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name},
+		DeleteOptions: &metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &dr.Spec.PodUID}},
+	}
+	err = c.cli.SubResource("eviction").Create(ctx, &pod, eviction)
+	switch {
+	case err == nil:
+		logger.Info("evicted pod", "pod", pod.Name, "requester", dr.Spec.Requester)
+		return c.setPhase(ctx, &dr, xpdbv1alpha1.DisruptionRequestPhaseEvicted,
+			fmt.Sprintf("evicted on behalf of %s", dr.Spec.Requester), 0)
+	case apierrors.IsNotFound(err) || apierrors.IsConflict(err):
+		// the precondition on the UID fails with a conflict if the pod was replaced.
+		return c.setPhase(ctx, &dr, xpdbv1alpha1.DisruptionRequestPhaseCanceled, "pod no longer exists", 0)
+	default:
+		retryAfter := pollInterval
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		logger.V(1).Info("eviction denied", "pod", pod.Name, "error", err.Error())
+		return c.setPhase(ctx, &dr, xpdbv1alpha1.DisruptionRequestPhaseWaitingForBudget, err.Error(), retryAfter)
+	}
+}
+
+// setPhase updates the status of the request and records a transition if the phase changed.
+// Requests which aren't finished are reconciled again after requeueAfter.
+func (c *Controller) setPhase(
+	ctx context.Context,
+	dr *xpdbv1alpha1.DisruptionRequest,
+	phase xpdbv1alpha1.DisruptionRequestPhase,
+	message string,
+	requeueAfter time.Duration,
+) (reconcile.Result, error) {
+	if dr.Status.Phase == phase && dr.Status.Message == message {
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	if dr.Status.Phase != phase {
+		dr.Status.Transitions = append(dr.Status.Transitions, xpdbv1alpha1.DisruptionRequestTransition{
+			Phase:              phase,
+			Message:            message,
+			LastTransitionTime: metav1.NewTime(c.now()),
+		})
+	}
+	dr.Status.Phase = phase
+	dr.Status.Message = message
+
+	if err := c.cli.Status().Update(ctx, dr); err != nil {
+		if apierrors.IsConflict(err) {
+			return reconcile.Result{Requeue: true}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("unable to update disruption request status: %w", err)
+	}
+	if phase.IsFinished() {
+		return reconcile.Result{RequeueAfter: c.ttl}, nil
+	}
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// finishedAt returns the time the request finished at.
+func finishedAt(dr *xpdbv1alpha1.DisruptionRequest) time.Time {
+	if n := len(dr.Status.Transitions); n > 0 {
+		return dr.Status.Transitions[n-1].LastTransitionTime.Time
+	}
+	return dr.CreationTimestamp.Time
+}
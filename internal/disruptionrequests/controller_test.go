@@ -0,0 +1,195 @@
+package disruptionrequests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	"github.com/form3tech-oss/x-pdb/internal/preactivities"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newTestScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = xpdbv1alpha1.AddToScheme(scheme)
+	return scheme
+}
+
+func TestController_Reconcile(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	pod := func(annotations map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "db-0", UID: "uid-1", Annotations: annotations}}
+	}
+	request := func(phase xpdbv1alpha1.DisruptionRequestPhase, finishedAt time.Time) *xpdbv1alpha1.DisruptionRequest {
+		dr := &xpdbv1alpha1.DisruptionRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "db-0"},
+			Spec: xpdbv1alpha1.DisruptionRequestSpec{
+				PodName:   "db-0",
+				PodUID:    "uid-1",
+				Requester: "system:serviceaccount:kube-system:drain",
+				ExpiresAt: metav1.NewTime(now.Add(time.Hour)),
+			},
+		}
+		if phase != "" {
+			dr.Status.Phase = phase
+			dr.Status.Transitions = []xpdbv1alpha1.DisruptionRequestTransition{
+				{Phase: phase, LastTransitionTime: metav1.NewTime(finishedAt)},
+			}
+		}
+		return dr
+	}
+
+	tests := []struct {
+		name             string
+		objects          []client.Object
+		evictionErr      error
+		wantPhase        xpdbv1alpha1.DisruptionRequestPhase
+		wantMessage      string
+		wantRequeueAfter time.Duration
+		wantEvicted      bool
+		wantDeleted      bool
+	}{
+		{
+			name: "should wait for pre-activities",
+			objects: []client.Object{
+				pod(map[string]string{preactivities.PreActivityAnnotationNamePrefix + "transfer-leadership": ""}),
+				request("", time.Time{}),
+			},
+			wantPhase:        xpdbv1alpha1.DisruptionRequestPhasePending,
+			wantMessage:      "waiting for pre-activities: transfer-leadership",
+			wantRequeueAfter: pollInterval,
+		},
+		{
+			name:        "should evict pod once pre-activities completed",
+			objects:     []client.Object{pod(nil), request(xpdbv1alpha1.DisruptionRequestPhasePending, now)},
+			wantPhase:   xpdbv1alpha1.DisruptionRequestPhaseEvicted,
+			wantMessage: "evicted on behalf of system:serviceaccount:kube-system:drain",
+			wantEvicted: true,
+		},
+		{
+			name:             "should retry denied evictions",
+			objects:          []client.Object{pod(nil), request(xpdbv1alpha1.DisruptionRequestPhasePending, now)},
+			evictionErr:      apierrors.NewTooManyRequests("Cannot disrupt pod", 30),
+			wantPhase:        xpdbv1alpha1.DisruptionRequestPhaseWaitingForBudget,
+			wantMessage:      "Cannot disrupt pod",
+			wantRequeueAfter: 30 * time.Second,
+			wantEvicted:      true,
+		},
+		{
+			name:        "should cancel request of deleted pod",
+			objects:     []client.Object{request(xpdbv1alpha1.DisruptionRequestPhasePending, now)},
+			wantPhase:   xpdbv1alpha1.DisruptionRequestPhaseCanceled,
+			wantMessage: "pod no longer exists",
+		},
+		{
+			name: "should expire request",
+			objects: []client.Object{pod(nil), func() client.Object {
+				dr := request(xpdbv1alpha1.DisruptionRequestPhaseWaitingForBudget, now)
+				dr.Spec.ExpiresAt = metav1.NewTime(now)
+				return dr
+			}()},
+			wantPhase:   xpdbv1alpha1.DisruptionRequestPhaseExpired,
+			wantMessage: "pod was not evicted before 2024-01-01T12:00:00Z",
+		},
+		{
+			name:             "should keep finished request until ttl passed",
+			objects:          []client.Object{request(xpdbv1alpha1.DisruptionRequestPhaseEvicted, now.Add(-time.Minute))},
+			wantPhase:        xpdbv1alpha1.DisruptionRequestPhaseEvicted,
+			wantRequeueAfter: 59 * time.Minute,
+		},
+		{
+			name:        "should delete finished request once ttl passed",
+			objects:     []client.Object{request(xpdbv1alpha1.DisruptionRequestPhaseEvicted, now.Add(-time.Hour))},
+			wantDeleted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			var evicted bool
+			cl := fake.NewClientBuilder().
+				WithScheme(newTestScheme()).
+				WithObjects(tt.objects...).
+				WithStatusSubresource(&xpdbv1alpha1.DisruptionRequest{}).
+				WithInterceptorFuncs(interceptor.Funcs{
+					SubResourceCreate: func(_ context.Context, _ client.Client, subResource string, obj client.Object, _ client.Object, _ ...client.SubResourceCreateOption) error {
+						assert.Equal(t, "eviction", subResource)
+						assert.Equal(t, "db-0", obj.GetName())
+						evicted = true
+						return tt.evictionErr
+					},
+				}).
+				Build()
+
+			service := preactivities.NewService(logr.Discard(), cl, preactivities.Options{})
+			controller := NewController(logr.Discard(), cl, service, time.Hour)
+			controller.now = func() time.Time { return now }
+
+			result, err := controller.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "db", Name: "db-0"}})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantEvicted, evicted)
+
+			var dr xpdbv1alpha1.DisruptionRequest
+			err = cl.Get(ctx, types.NamespacedName{Namespace: "db", Name: "db-0"}, &dr)
+			if tt.wantDeleted {
+				assert.True(t, apierrors.IsNotFound(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPhase, dr.Status.Phase)
+			assert.Equal(t, tt.wantMessage, dr.Status.Message)
+			assert.Equal(t, tt.wantPhase, dr.Status.Transitions[len(dr.Status.Transitions)-1].Phase)
+			if !tt.wantPhase.IsFinished() || tt.wantRequeueAfter > 0 {
+				assert.Equal(t, tt.wantRequeueAfter, result.RequeueAfter)
+			}
+		})
+	}
+}
+
+func TestService_Record(t *testing.T) {
+	ctx := context.Background()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "db-0", UID: "uid-1"}}
+	cl := fake.NewClientBuilder().
+		WithScheme(newTestScheme()).
+		WithStatusSubresource(&xpdbv1alpha1.DisruptionRequest{}).
+		Build()
+	service := NewService(logr.Discard(), cl, time.Hour)
+	key := types.NamespacedName{Namespace: "db", Name: "db-0"}
+
+	require.NoError(t, service.Record(ctx, pod, "db", "drain"))
+	var dr xpdbv1alpha1.DisruptionRequest
+	require.NoError(t, cl.Get(ctx, key, &dr))
+	assert.Equal(t, types.UID("uid-1"), dr.Spec.PodUID)
+	assert.Equal(t, "drain", dr.Spec.Requester)
+
+	t.Run("should keep pending request of the pod", func(t *testing.T) {
+		require.NoError(t, service.Record(ctx, pod, "db", "autoscaler"))
+		var got xpdbv1alpha1.DisruptionRequest
+		require.NoError(t, cl.Get(ctx, key, &got))
+		assert.Equal(t, "drain", got.Spec.Requester)
+	})
+
+	t.Run("should replace request of a previous pod", func(t *testing.T) {
+		replaced := pod.DeepCopy()
+		replaced.UID = "uid-2"
+		require.NoError(t, service.Record(ctx, replaced, "db", "autoscaler"))
+		var got xpdbv1alpha1.DisruptionRequest
+		require.NoError(t, cl.Get(ctx, key, &got))
+		assert.Equal(t, types.UID("uid-2"), got.Spec.PodUID)
+		assert.Equal(t, "autoscaler", got.Spec.Requester)
+	})
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package disruptionrequests
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Service records evictions which were blocked by pre-activities as DisruptionRequests.
+// A DisruptionRequest is named after its pod, so there is at most one per pod.
+type Service struct {
+	logger logr.Logger
+	cli    client.Client
+	ttl    time.Duration
+}
+
+// NewService creates a new Service. Recorded requests expire after ttl.
+func NewService(logger logr.Logger, cli client.Client, ttl time.Duration) *Service {
+	return &Service{
+		logger: logger,
+		cli:    cli,
+		ttl:    ttl,
+	}
+}
+
+// Record records the blocked eviction of the pod requested by requester.
+// Pending requests of the pod are kept, finished ones and the ones of previous pods of the same name are replaced.
+func (s *Service) Record(ctx context.Context, pod *corev1.Pod, xpdbName, requester string) error {
+	dr := &xpdbv1alpha1.DisruptionRequest{
+		ObjectMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name},
+		Spec: xpdbv1alpha1.DisruptionRequestSpec{
+			PodName:   pod.Name,
+			PodUID:    pod.UID,
+			XPDBName:  xpdbName,
+			Requester: requester,
+			ExpiresAt: metav1.NewTime(time.Now().Add(s.ttl)),
+		},
+	}
+
+	err := s.cli.Create(ctx, dr)
+	if err == nil {
+		s.logger.Info("recorded disruption request", "pod", pod.Name, "namespace", pod.Namespace, "requester", requester)
+		return nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("unable to create disruption request: %w", err)
+	}
+
+	var existing xpdbv1alpha1.DisruptionRequest
+	if err := s.cli.Get(ctx, client.ObjectKeyFromObject(dr), &existing); err != nil {
+		return fmt.Errorf("unable to get disruption request: %w", err)
+	}
+	if existing.Spec.PodUID == pod.UID && !existing.Status.Phase.IsFinished() {
+		return nil
+	}
+
+	if err := s.cli.Delete(ctx, &existing, client.Preconditions{UID: &existing.UID}); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("unable to replace disruption request: %w", err)
+	}
+	if err := s.cli.Create(ctx, dr); err != nil {
+		return fmt.Errorf("unable to create disruption request: %w", err)
+	}
+	s.logger.Info("recorded disruption request", "pod", pod.Name, "namespace", pod.Namespace, "requester", requester)
+	return nil
+}
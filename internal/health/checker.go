@@ -0,0 +1,196 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a health signal is healthy.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Name    string    `json:"name"`
+	Healthy bool      `json:"healthy"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Checker periodically evaluates a set of checks.
+// If grpc services are supplied, the result is reported through the standard grpc health service.
+type Checker struct {
+	name     string
+	logger   logr.Logger
+	interval time.Duration
+	services []string
+
+	mux         sync.RWMutex
+	checks      []namedCheck
+	lastErr     error
+	lastResults []Result
+
+	healthServer *health.Server
+}
+
+// NewChecker creates a new Checker which reports the status of the supplied grpc services.
+// The checker and the services are not healthy until all checks succeeded once.
+func NewChecker(logger logr.Logger, name string, interval time.Duration, services ...string) *Checker {
+	c := &Checker{
+		name:     name,
+		logger:   logger.WithName("health").WithValues("checker", name),
+		interval: interval,
+		lastErr:  errors.New("checks have not run yet"),
+	}
+	if len(services) > 0 {
+		c.services = append([]string{""}, services...)
+		c.healthServer = health.NewServer()
+		for _, s := range c.services {
+			c.healthServer.SetServingStatus(s, healthpb.HealthCheckResponse_NOT_SERVING)
+		}
+	}
+	return c
+}
+
+// Name returns the name of the checker.
+func (c *Checker) Name() string {
+	return c.name
+}
+
+// AddCheck registers a check.
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// HealthServer returns the grpc health service backed by the checks.
+// It is nil if the checker was created without grpc services.
+func (c *Checker) HealthServer() healthpb.HealthServer {
+	return c.healthServer
+}
+
+// Check runs all the checks and returns the joined errors of the failing ones.
+func (c *Checker) Check(ctx context.Context) error {
+	_, err := c.run(ctx)
+	return err
+}
+
+func (c *Checker) run(ctx context.Context) ([]Result, error) {
+	c.mux.RLock()
+	checks := c.checks
+	c.mux.RUnlock()
+
+	results := make([]Result, 0, len(checks))
+	var errs []error
+	for _, nc := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := nc.check(checkCtx)
+		cancel()
+
+		result := Result{Name: nc.name, Healthy: err == nil, Time: time.Now()}
+		if err != nil {
+			result.Error = err.Error()
+			errs = append(errs, fmt.Errorf("%s: %w", nc.name, err))
+		}
+		results = append(results, result)
+	}
+	return results, errors.Join(errs...)
+}
+
+// Err returns the result of the last evaluation of the checks.
+func (c *Checker) Err() error {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.lastErr
+}
+
+// Results returns the results of the last evaluation of every check.
+func (c *Checker) Results() []Result {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.lastResults
+}
+
+// Healthz returns the result of the last evaluation as a healthz check of the manager.
+func (c *Checker) Healthz(_ *http.Request) error {
+	return c.Err()
+}
+
+// Start evaluates the checks periodically until the context is cancelled.
+func (c *Checker) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.update(ctx)
+
+		select {
+		case <-ctx.Done():
+			// make sure peers and load balancers stop sending requests while shutting down.
+			if c.healthServer != nil {
+				c.healthServer.Shutdown()
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Checker) update(ctx context.Context) {
+	results, err := c.run(ctx)
+
+	c.mux.Lock()
+	changed := (err == nil) != (c.lastErr == nil)
+	c.lastErr = err
+	c.lastResults = results
+	c.mux.Unlock()
+
+	if c.healthServer != nil {
+		status := healthpb.HealthCheckResponse_SERVING
+		if err != nil {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		for _, s := range c.services {
+			c.healthServer.SetServingStatus(s, status)
+		}
+	}
+
+	if changed {
+		if err != nil {
+			c.logger.Error(err, "checks failed")
+		} else {
+			c.logger.Info("checks succeeded")
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+	const service = "state.v1.StateService"
+
+	var failing error
+	c := NewChecker(logr.Discard(), "readiness", time.Second, service)
+	c.AddCheck("ok", func(context.Context) error { return nil })
+	c.AddCheck("toggle", func(context.Context) error { return failing })
+
+	status := func() healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := c.HealthServer().Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		return resp.Status
+	}
+
+	assert.Error(t, c.Err(), "not ready before the checks ran")
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status())
+
+	c.update(ctx)
+	assert.NoError(t, c.Err())
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status())
+
+	failing = errors.New("boom")
+	c.update(ctx)
+	assert.ErrorContains(t, c.Err(), "toggle: boom")
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status())
+	results := c.Results()
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Healthy)
+	assert.False(t, results[1].Healthy)
+	assert.Equal(t, "boom", results[1].Error)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/form3tech-oss/x-pdb/internal/mtls"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// CacheSyncCheck verifies that the informer caches have been synced.
+func CacheSyncCheck(c cache.Cache) Check {
+	return func(ctx context.Context) error {
+		if !c.WaitForCacheSync(ctx) {
+			return errors.New("informer caches are not synced")
+		}
+		return nil
+	}
+}
+
+// APIServerCheck verifies that the API server is reachable and ready.
+func APIServerCheck(client rest.Interface) Check {
+	return func(ctx context.Context) error {
+		return client.Get().AbsPath("/readyz").Do(ctx).Error()
+	}
+}
+
+// CertificatesCheck verifies that the certificates of the state server can be loaded.
+func CertificatesCheck(provider mtls.Provider) Check {
+	return func(_ context.Context) error {
+		_, err := provider.ServerConfig()
+		return err
+	}
+}
+
+// CertificateFilesCheck verifies that the key pair in certsDir can be loaded
+// and that the certificate is valid for at least minValidity.
+func CertificateFilesCheck(certsDir string, minValidity time.Duration) Check {
+	return func(_ context.Context) error {
+		cert, err := tls.LoadX509KeyPair(filepath.Join(certsDir, "tls.crt"), filepath.Join(certsDir, "tls.key"))
+		if err != nil {
+			return err
+		}
+
+		if remaining := time.Until(cert.Leaf.NotAfter); remaining < minValidity {
+			return fmt.Errorf("certificate expires in %s at %s", remaining.Round(time.Second), cert.Leaf.NotAfter)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type checkerStatus struct {
+	Name    string   `json:"name"`
+	Healthy bool     `json:"healthy"`
+	Checks  []Result `json:"checks"`
+}
+
+type debugResponse struct {
+	Checkers []checkerStatus `json:"checkers"`
+	Peers    any             `json:"peers"`
+}
+
+// NewDebugHandler returns a handler which explains the results of the
+// supplied checkers together with the status of the remote peers.
+func NewDebugHandler(peers func() any, checkers ...*Checker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		resp := debugResponse{Peers: peers()}
+		for _, c := range checkers {
+			resp.Checkers = append(resp.Checkers, checkerStatus{
+				Name:    c.Name(),
+				Healthy: c.Err() == nil,
+				Checks:  c.Results(),
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(resp)
+	})
+}
@@ -32,6 +32,7 @@ import (
 const (
 	leaseAnnotationNamespace = "xpdb.form3.tech/pod-namespace"
 	leaseAnnotationSelector  = "xpdb.form3.tech/pod-selector"
+	leaseAnnotationXPDBName  = "xpdb.form3.tech/xpdb-name"
 )
 
 // LeaseDurationSeconds is the default duration for the Lease object.
@@ -65,15 +66,38 @@ func createLeaseNameForSelector(namespace string, selector *metav1.LabelSelector
 	return fmt.Sprintf("xpdb-%s", prefixStr)
 }
 
+// createLeaseNameForXPDB derives the lease name from the identity of the xpdb,
+// which is the same in all clusters.
+func createLeaseNameForXPDB(namespace, xpdbName string) string {
+	leaseHash := sha256.New()
+	leaseHash.Write([]byte(namespace + "/" + xpdbName))
+	leaseHashBytes := leaseHash.Sum(nil)
+	// using base32 to prevent usage of special characters like + and /
+	// trim padding character `=`
+	prefixStr := strings.ToLower(strings.TrimRight(base32.StdEncoding.EncodeToString(leaseHashBytes[0:24]), "="))
+	return fmt.Sprintf("xpdb-%s", prefixStr)
+}
+
+func createLeaseForXPDB(leaseNamespace, leaseHolderIdentity, namespace, xpdbName string) *coordv1.Lease {
+	return newLease(leaseNamespace, leaseHolderIdentity, createLeaseNameForXPDB(namespace, xpdbName), map[string]string{
+		leaseAnnotationNamespace: namespace,
+		leaseAnnotationXPDBName:  xpdbName,
+	})
+}
+
 func createLeaseForSelector(leaseNamespace, leaseHolderIdentity, namespace string, selector *metav1.LabelSelector) *coordv1.Lease {
+	return newLease(leaseNamespace, leaseHolderIdentity, createLeaseNameForSelector(namespace, selector), map[string]string{
+		leaseAnnotationNamespace: namespace,
+		leaseAnnotationSelector:  selector.String(),
+	})
+}
+
+func newLease(leaseNamespace, leaseHolderIdentity, name string, annotations map[string]string) *coordv1.Lease {
 	return &coordv1.Lease{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      createLeaseNameForSelector(namespace, selector),
-			Namespace: leaseNamespace,
-			Annotations: map[string]string{
-				leaseAnnotationNamespace: namespace,
-				leaseAnnotationSelector:  selector.String(),
-			},
+			Name:        name,
+			Namespace:   leaseNamespace,
+			Annotations: annotations,
 			Labels: map[string]string{
 				"app": "x-pdb",
 			},
@@ -22,11 +22,15 @@ import (
 	"fmt"
 	"time"
 
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
 	"github.com/form3tech-oss/x-pdb/internal/converters"
+	"github.com/form3tech-oss/x-pdb/internal/remotes"
 	stateclient "github.com/form3tech-oss/x-pdb/internal/state/client"
+	"github.com/form3tech-oss/x-pdb/internal/tracing"
 	statepb "github.com/form3tech-oss/x-pdb/pkg/proto/state/v1"
 	"github.com/go-logr/logr"
 	"github.com/sourcegraph/conc/pool"
+	coordv1 "k8s.io/api/coordination/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -34,6 +38,41 @@ import (
 
 var remoteLockTimeout = 2 * time.Second
 
+// LeaseHeldError is returned if a lock can not be acquired,
+// because the lease is held by another identity.
+type LeaseHeldError struct {
+	// Holder is the identity holding the lease, empty if it is unknown.
+	Holder string
+	// RetryAfter is the time until the lease expires.
+	RetryAfter time.Duration
+}
+
+func (e *LeaseHeldError) Error() string {
+	if e.Holder == "" {
+		return fmt.Sprintf("lease deadline not reached, expires in %s", e.RetryAfter)
+	}
+	return fmt.Sprintf("lease deadline not reached, held by %q for %s", e.Holder, e.RetryAfter)
+}
+
+// RetryAfter returns the longest time until a lease blocking the lock expires.
+// It returns 0 if err was not caused by a held lease.
+func RetryAfter(err error) time.Duration {
+	switch e := err.(type) {
+	case nil:
+		return 0
+	case *LeaseHeldError:
+		return e.RetryAfter
+	case interface{ Unwrap() []error }:
+		var retryAfter time.Duration
+		for _, err := range e.Unwrap() {
+			retryAfter = max(retryAfter, RetryAfter(err))
+		}
+		return retryAfter
+	default:
+		return RetryAfter(errors.Unwrap(err))
+	}
+}
+
 // Service is responsible to manage the locks
 // used to guarantee that there are no race conditions
 // when doing disruptions across clusters.
@@ -43,6 +82,7 @@ type Service struct {
 	reader          client.Reader
 	stateClientPool *stateclient.ClientPool
 	leaseNamespace  string
+	remotes         *remotes.Config
 	remoteEndpoints []string
 }
 
@@ -53,7 +93,7 @@ func NewService(
 	reader client.Reader,
 	stateClientPool *stateclient.ClientPool,
 	leaseNamespace string,
-	remoteEndpoints []string,
+	remotesConfig *remotes.Config,
 ) *Service {
 	return &Service{
 		logger:          logger,
@@ -61,20 +101,26 @@ func NewService(
 		reader:          reader,
 		stateClientPool: stateClientPool,
 		leaseNamespace:  leaseNamespace,
-		remoteEndpoints: remoteEndpoints,
+		remotes:         remotesConfig,
+		remoteEndpoints: remotesConfig.Endpoints(),
 	}
 }
 
-// Lock creates / updates leases on the local and remote clusters to disallow concurrent pod disruptions for a given namespace and
-// selector.
-func (s *Service) Lock(ctx context.Context, leaseHolderIdentity, namespace string, selector *metav1.LabelSelector) error {
-	err := s.LocalLock(ctx, leaseHolderIdentity, namespace, selector)
+// Lock creates / updates leases on the local and remote clusters to disallow concurrent pod disruptions for a given xpdb.
+func (s *Service) Lock(ctx context.Context, leaseHolderIdentity string, xpdb *xpdbv1alpha1.XPodDisruptionBudget) (err error) {
+	ctx, span := tracing.Start(ctx, "lock.Service.Lock",
+		tracing.AttributeLeaseHolder.String(leaseHolderIdentity),
+		tracing.AttributeNamespace.String(xpdb.Namespace),
+		tracing.AttributeXPDBName.String(xpdb.Name))
+	defer func() { tracing.End(span, err) }()
+
+	err = s.LocalLock(ctx, leaseHolderIdentity, xpdb.Namespace, xpdb.Name, legacySelector(xpdb))
 	if err != nil {
 		return fmt.Errorf("unable to lock local cluster: %w", err)
 	}
 
 	if len(s.remoteEndpoints) > 0 {
-		err = s.remoteLock(ctx, leaseHolderIdentity, namespace, selector)
+		err = s.remoteLock(ctx, leaseHolderIdentity, xpdb)
 		if err != nil {
 			return fmt.Errorf("unable to lock remote clusters: %w", err)
 		}
@@ -84,14 +130,20 @@ func (s *Service) Lock(ctx context.Context, leaseHolderIdentity, namespace strin
 }
 
 // Unlock deletes leases on local and remote clusters to allow pod disruptions to happen.
-func (s *Service) Unlock(ctx context.Context, leaseHolderIdentity, namespace string, selector *metav1.LabelSelector) error {
-	err := s.LocalUnlock(ctx, leaseHolderIdentity, namespace, selector)
+func (s *Service) Unlock(ctx context.Context, leaseHolderIdentity string, xpdb *xpdbv1alpha1.XPodDisruptionBudget) (err error) {
+	ctx, span := tracing.Start(ctx, "lock.Service.Unlock",
+		tracing.AttributeLeaseHolder.String(leaseHolderIdentity),
+		tracing.AttributeNamespace.String(xpdb.Namespace),
+		tracing.AttributeXPDBName.String(xpdb.Name))
+	defer func() { tracing.End(span, err) }()
+
+	err = s.LocalUnlock(ctx, leaseHolderIdentity, xpdb.Namespace, xpdb.Name, legacySelector(xpdb))
 	if err != nil {
 		return fmt.Errorf("unable to unlock local cluster: %w", err)
 	}
 
 	if len(s.remoteEndpoints) > 0 {
-		err = s.remoteUnlock(ctx, leaseHolderIdentity, namespace, selector)
+		err = s.remoteUnlock(ctx, leaseHolderIdentity, xpdb)
 		if err != nil {
 			return fmt.Errorf("unable to unlock remote clusters: %w", err)
 		}
@@ -100,9 +152,52 @@ func (s *Service) Unlock(ctx context.Context, leaseHolderIdentity, namespace str
 	return nil
 }
 
-// LocalLock creates / updates leases on the local cluster to disallow concurrent pod disruptions for a given namespace and selector.
-func (s *Service) LocalLock(ctx context.Context, leaseHolderIdentity, namespace string, selector *metav1.LabelSelector) error {
-	lease := createLeaseForSelector(s.leaseNamespace, leaseHolderIdentity, namespace, selector)
+// LocalLock creates / updates the lease of the xpdb with the given namespace and name on the local cluster
+// to disallow concurrent pod disruptions.
+// If the selector is given, the lease of the selector is acquired as well. Peers of releases before the
+// lease was keyed by the xpdb lock by selector only, holding both leases keeps disruptions mutually
+// exclusive while the clusters are upgraded one after another.
+func (s *Service) LocalLock(
+	ctx context.Context,
+	leaseHolderIdentity, namespace, xpdbName string,
+	selector *metav1.LabelSelector,
+) (err error) {
+	ctx, span := tracing.Start(ctx, "lock.Service.LocalLock",
+		tracing.AttributeLeaseHolder.String(leaseHolderIdentity),
+		tracing.AttributeNamespace.String(namespace),
+		tracing.AttributeXPDBName.String(xpdbName))
+	defer func() { tracing.End(span, err) }()
+
+	lease := createLeaseForXPDB(s.leaseNamespace, leaseHolderIdentity, namespace, xpdbName)
+	err = s.acquire(ctx, leaseHolderIdentity, lease)
+	if err != nil || selector == nil {
+		return err
+	}
+
+	err = s.acquire(ctx, leaseHolderIdentity, createLeaseForSelector(s.leaseNamespace, leaseHolderIdentity, namespace, selector))
+	if err != nil {
+		// don't block disruptions of the xpdb until the lease expires.
+		if releaseErr := s.release(ctx, leaseHolderIdentity, lease); releaseErr != nil {
+			s.logger.Error(releaseErr, "unable to release lease", "lease", lease.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// LocalLockSelector creates / updates leases on the local cluster to disallow concurrent pod disruptions
+// for a given namespace and selector.
+// It serves lock requests of remotes which don't send the name of the xpdb.
+func (s *Service) LocalLockSelector(ctx context.Context, leaseHolderIdentity, namespace string, selector *metav1.LabelSelector) (err error) {
+	ctx, span := tracing.Start(ctx, "lock.Service.LocalLockSelector",
+		tracing.AttributeLeaseHolder.String(leaseHolderIdentity),
+		tracing.AttributeNamespace.String(namespace))
+	defer func() { tracing.End(span, err) }()
+
+	return s.acquire(ctx, leaseHolderIdentity, createLeaseForSelector(s.leaseNamespace, leaseHolderIdentity, namespace, selector))
+}
+
+func (s *Service) acquire(ctx context.Context, leaseHolderIdentity string, lease *coordv1.Lease) error {
 	s.logger.V(2).Info("attempting to lock", "lease", lease.Name, "identity", leaseHolderIdentity)
 
 	err := s.client.Create(ctx, lease)
@@ -114,6 +209,12 @@ func (s *Service) LocalLock(ctx context.Context, leaseHolderIdentity, namespace
 			return fmt.Errorf("unable to take over lease: %w", err)
 		}
 
+		// the lease is already held by this request, e.g. because the pod matches
+		// multiple xpdbs sharing a lease.
+		if lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity == leaseHolderIdentity {
+			return nil
+		}
+
 		deadline := lease.Spec.AcquireTime.Add(time.Second * time.Duration(*lease.Spec.LeaseDurationSeconds))
 		if deadline.After(time.Now()) {
 			s.logger.Info(
@@ -122,7 +223,7 @@ func (s *Service) LocalLock(ctx context.Context, leaseHolderIdentity, namespace
 				"acquired", lease.Spec.AcquireTime.String(),
 				"durationSeconds", *lease.Spec.LeaseDurationSeconds,
 				"expiresSeconds", time.Until(deadline).Seconds())
-			return errors.New("lease deadline not reached")
+			return &LeaseHeldError{Holder: *lease.Spec.HolderIdentity, RetryAfter: time.Until(deadline)}
 		}
 
 		// the lease timed out, update identity + acquire time
@@ -153,9 +254,39 @@ func (s *Service) LocalLock(ctx context.Context, leaseHolderIdentity, namespace
 	return nil
 }
 
-// LocalUnlock deletes leases on the local cluster to allow pod disruptions to happen.
-func (s *Service) LocalUnlock(ctx context.Context, leaseHolderIdentity, namespace string, selector *metav1.LabelSelector) error {
-	lease := createLeaseForSelector(s.leaseNamespace, leaseHolderIdentity, namespace, selector)
+// LocalUnlock deletes the lease of the xpdb with the given namespace and name on the local cluster
+// to allow pod disruptions to happen.
+// If the selector is given, the lease of the selector acquired by LocalLock is deleted as well.
+func (s *Service) LocalUnlock(
+	ctx context.Context,
+	leaseHolderIdentity, namespace, xpdbName string,
+	selector *metav1.LabelSelector,
+) (err error) {
+	ctx, span := tracing.Start(ctx, "lock.Service.LocalUnlock",
+		tracing.AttributeLeaseHolder.String(leaseHolderIdentity),
+		tracing.AttributeNamespace.String(namespace),
+		tracing.AttributeXPDBName.String(xpdbName))
+	defer func() { tracing.End(span, err) }()
+
+	err = s.release(ctx, leaseHolderIdentity, createLeaseForXPDB(s.leaseNamespace, leaseHolderIdentity, namespace, xpdbName))
+	if err != nil || selector == nil {
+		return err
+	}
+	return s.release(ctx, leaseHolderIdentity, createLeaseForSelector(s.leaseNamespace, leaseHolderIdentity, namespace, selector))
+}
+
+// LocalUnlockSelector deletes leases on the local cluster to allow pod disruptions to happen.
+// It serves unlock requests of remotes which don't send the name of the xpdb.
+func (s *Service) LocalUnlockSelector(ctx context.Context, leaseHolderIdentity, namespace string, selector *metav1.LabelSelector) (err error) {
+	ctx, span := tracing.Start(ctx, "lock.Service.LocalUnlockSelector",
+		tracing.AttributeLeaseHolder.String(leaseHolderIdentity),
+		tracing.AttributeNamespace.String(namespace))
+	defer func() { tracing.End(span, err) }()
+
+	return s.release(ctx, leaseHolderIdentity, createLeaseForSelector(s.leaseNamespace, leaseHolderIdentity, namespace, selector))
+}
+
+func (s *Service) release(ctx context.Context, leaseHolderIdentity string, lease *coordv1.Lease) error {
 	err := s.reader.Get(ctx, client.ObjectKeyFromObject(lease), lease)
 	if apierrors.IsNotFound(err) {
 		return nil
@@ -168,15 +299,19 @@ func (s *Service) LocalUnlock(ctx context.Context, leaseHolderIdentity, namespac
 	return s.client.Delete(ctx, lease)
 }
 
-func (s *Service) remoteLock(ctx context.Context, leaseHolderIdentity, namespace string, selector *metav1.LabelSelector) error {
-	if len(s.remoteEndpoints) == 0 {
+// legacySelector returns the selector whose lease peers of older releases acquire for the xpdb.
+// It is nil for xpdbs targeting a workload, which older releases don't support, as their empty
+// selector would share the lease with all other such xpdbs of the namespace.
+func legacySelector(xpdb *xpdbv1alpha1.XPodDisruptionBudget) *metav1.LabelSelector {
+	if xpdb.Spec.TargetRef != nil {
 		return nil
 	}
+	return &xpdb.Spec.Selector
+}
 
-	req := &statepb.LockRequest{
-		LeaseHolderIdentity: leaseHolderIdentity,
-		Namespace:           namespace,
-		LabelSelector:       converters.ConvertLabelSelectorToState(selector),
+func (s *Service) remoteLock(ctx context.Context, leaseHolderIdentity string, xpdb *xpdbv1alpha1.XPodDisruptionBudget) error {
+	if len(s.remoteEndpoints) == 0 {
+		return nil
 	}
 
 	p := pool.NewWithResults[*statepb.LockResponse]().
@@ -185,6 +320,16 @@ func (s *Service) remoteLock(ctx context.Context, leaseHolderIdentity, namespace
 		WithContext(ctx)
 
 	for _, e := range s.remoteEndpoints {
+		namespace, selector := s.remotes.MapXPDB(e, xpdb)
+		req := &statepb.LockRequest{
+			LeaseHolderIdentity: leaseHolderIdentity,
+			Namespace:           namespace,
+			XpdbName:            xpdb.Name,
+		}
+		if legacySelector(xpdb) != nil {
+			req.LabelSelector = converters.ConvertLabelSelectorToState(selector)
+		}
+
 		p.Go(func(ctx context.Context) (*statepb.LockResponse, error) {
 			cli, err := s.stateClientPool.Get(e)
 			if err != nil {
@@ -205,7 +350,13 @@ func (s *Service) remoteLock(ctx context.Context, leaseHolderIdentity, namespace
 
 	var errs []error
 	for _, r := range results {
-		if !r.Acquired {
+		if r.Acquired {
+			continue
+		}
+		if r.RetryAfterSeconds > 0 {
+			errs = append(errs, fmt.Errorf("lock not acquired: %w",
+				&LeaseHeldError{RetryAfter: time.Duration(r.RetryAfterSeconds) * time.Second}))
+		} else {
 			errs = append(errs, fmt.Errorf("lock not acquired: %s", r.Error))
 		}
 	}
@@ -215,23 +366,27 @@ func (s *Service) remoteLock(ctx context.Context, leaseHolderIdentity, namespace
 	return nil
 }
 
-func (s *Service) remoteUnlock(ctx context.Context, leaseHolderIdentity, namespace string, selector *metav1.LabelSelector) error {
+func (s *Service) remoteUnlock(ctx context.Context, leaseHolderIdentity string, xpdb *xpdbv1alpha1.XPodDisruptionBudget) error {
 	if len(s.remoteEndpoints) == 0 {
 		return nil
 	}
 
-	req := &statepb.UnlockRequest{
-		LeaseHolderIdentity: leaseHolderIdentity,
-		Namespace:           namespace,
-		LabelSelector:       converters.ConvertLabelSelectorToState(selector),
-	}
-
 	p := pool.NewWithResults[*statepb.UnlockResponse]().
 		WithErrors().
 		WithMaxGoroutines(len(s.remoteEndpoints)).
 		WithContext(ctx)
 
 	for _, e := range s.remoteEndpoints {
+		namespace, selector := s.remotes.MapXPDB(e, xpdb)
+		req := &statepb.UnlockRequest{
+			LeaseHolderIdentity: leaseHolderIdentity,
+			Namespace:           namespace,
+			XpdbName:            xpdb.Name,
+		}
+		if legacySelector(xpdb) != nil {
+			req.LabelSelector = converters.ConvertLabelSelectorToState(selector)
+		}
+
 		p.Go(func(ctx context.Context) (*statepb.UnlockResponse, error) {
 			cli, err := s.stateClientPool.Get(e)
 			if err != nil {
@@ -2,9 +2,12 @@ package lock
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
 	"github.com/stretchr/testify/assert"
 	coordv1 "k8s.io/api/coordination/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -28,15 +31,11 @@ func TestService_Lock(t *testing.T) {
 	leaseNamespace := "default"
 	leaseIdentity := "x-pdb-123"
 	otherLeaseIdentity := "x-pdb-666-from-other-cluster"
-	testPodSelector := &metav1.LabelSelector{
-		MatchLabels: map[string]string{
-			"app": "test",
-		},
-	}
+	testXPDBName := "test"
 
 	type args struct {
 		podNamespace string
-		podSelector  *metav1.LabelSelector
+		xpdbName     string
 	}
 	tests := []struct {
 		name          string
@@ -49,11 +48,11 @@ func TestService_Lock(t *testing.T) {
 			name: "should acquire lock",
 			args: args{
 				podNamespace: "default",
-				podSelector:  testPodSelector,
+				xpdbName:     testXPDBName,
 			},
 			wantErr: false,
 			assert: func(cl client.Client) {
-				expectedLease := createLeaseForSelector(leaseNamespace, leaseIdentity, "default", testPodSelector)
+				expectedLease := createLeaseForXPDB(leaseNamespace, leaseIdentity, "default", testXPDBName)
 				err := cl.Get(context.Background(), client.ObjectKeyFromObject(expectedLease), expectedLease)
 				assert.NoError(t, err, "get lease failed")
 				assert.Equal(t, leaseIdentity, *expectedLease.Spec.HolderIdentity)
@@ -61,14 +60,14 @@ func TestService_Lock(t *testing.T) {
 		},
 		{
 			name:          "should error if lock already exists and has not expired",
-			existingLease: makeTestLease(leaseNamespace, otherLeaseIdentity, "default", testPodSelector),
+			existingLease: makeTestLease(leaseNamespace, otherLeaseIdentity, "default", testXPDBName),
 			args: args{
 				podNamespace: "default",
-				podSelector:  testPodSelector,
+				xpdbName:     testXPDBName,
 			},
 			wantErr: true,
 			assert: func(cl client.Client) {
-				expectedLease := createLeaseForSelector(leaseNamespace, leaseIdentity, "default", testPodSelector)
+				expectedLease := createLeaseForXPDB(leaseNamespace, leaseIdentity, "default", testXPDBName)
 				err := cl.Get(context.Background(), client.ObjectKeyFromObject(expectedLease), expectedLease)
 				assert.NoError(t, err, "get lease failed")
 				assert.NotEqual(t, *expectedLease.Spec.HolderIdentity, leaseIdentity)
@@ -76,20 +75,35 @@ func TestService_Lock(t *testing.T) {
 		},
 		{
 			name:          "should take over if lock already exists and has expired",
-			existingLease: makeTestLease(leaseNamespace, otherLeaseIdentity, "default", testPodSelector, leaseExpired),
+			existingLease: makeTestLease(leaseNamespace, otherLeaseIdentity, "default", testXPDBName, leaseExpired),
 			args: args{
 				podNamespace: "default",
-				podSelector:  testPodSelector,
+				xpdbName:     testXPDBName,
 			},
 			wantErr: false,
 			assert: func(cl client.Client) {
 				// verify that we took over the lease by verifying the identity
-				expectedLease := createLeaseForSelector(leaseNamespace, leaseIdentity, "default", testPodSelector)
+				expectedLease := createLeaseForXPDB(leaseNamespace, leaseIdentity, "default", testXPDBName)
 				err := cl.Get(context.Background(), client.ObjectKeyFromObject(expectedLease), expectedLease)
 				assert.NoError(t, err, "get lease failed")
 				assert.Equal(t, *expectedLease.Spec.HolderIdentity, leaseIdentity)
 			},
 		},
+		{
+			name:          "should succeed if lock is already held by the same identity",
+			existingLease: makeTestLease(leaseNamespace, leaseIdentity, "default", testXPDBName),
+			args: args{
+				podNamespace: "default",
+				xpdbName:     testXPDBName,
+			},
+			wantErr: false,
+			assert: func(cl client.Client) {
+				expectedLease := createLeaseForXPDB(leaseNamespace, leaseIdentity, "default", testXPDBName)
+				err := cl.Get(context.Background(), client.ObjectKeyFromObject(expectedLease), expectedLease)
+				assert.NoError(t, err, "get lease failed")
+				assert.Equal(t, leaseIdentity, *expectedLease.Spec.HolderIdentity)
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -100,7 +114,7 @@ func TestService_Lock(t *testing.T) {
 			cl := clientBuilder.Build()
 			logger := zap.New(zap.UseDevMode(true))
 			s := NewService(&logger, cl, cl, nil, leaseNamespace, nil)
-			if err := s.LocalLock(context.Background(), leaseIdentity, tt.args.podNamespace, tt.args.podSelector); (err != nil) != tt.wantErr {
+			if err := s.LocalLock(context.Background(), leaseIdentity, tt.args.podNamespace, tt.args.xpdbName, nil); (err != nil) != tt.wantErr {
 				t.Errorf("Service.LockXPDB() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			if tt.assert != nil {
@@ -110,10 +124,99 @@ func TestService_Lock(t *testing.T) {
 	}
 }
 
+func TestService_LocalLock_Selector(t *testing.T) {
+	leaseNamespace := "default"
+	leaseIdentity := "x-pdb-123"
+	otherLeaseIdentity := "x-pdb-666-from-other-cluster"
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}
+
+	tests := []struct {
+		name           string
+		existingLeases []client.Object
+		wantErr        bool
+		wantXPDBLease  bool
+	}{
+		{
+			name:          "should acquire lease of xpdb and selector",
+			wantXPDBLease: true,
+		},
+		{
+			name: "should error and release lease of xpdb if selector is locked by a peer of an older release",
+			existingLeases: []client.Object{
+				createLeaseForSelector(leaseNamespace, otherLeaseIdentity, "default", selector),
+			},
+			wantErr: true,
+		},
+		{
+			name: "should error if xpdb is locked",
+			existingLeases: []client.Object{
+				createLeaseForXPDB(leaseNamespace, otherLeaseIdentity, "default", "test"),
+			},
+			wantErr:       true,
+			wantXPDBLease: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.existingLeases...).Build()
+			logger := zap.New(zap.UseDevMode(true))
+			s := NewService(&logger, cl, cl, nil, leaseNamespace, nil)
+
+			err := s.LocalLock(context.Background(), leaseIdentity, "default", "test", selector)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				lease := createLeaseForSelector(leaseNamespace, leaseIdentity, "default", selector)
+				assert.NoError(t, cl.Get(context.Background(), client.ObjectKeyFromObject(lease), lease))
+				assert.Equal(t, leaseIdentity, *lease.Spec.HolderIdentity)
+			}
+
+			lease := createLeaseForXPDB(leaseNamespace, leaseIdentity, "default", "test")
+			err = cl.Get(context.Background(), client.ObjectKeyFromObject(lease), lease)
+			assert.Equal(t, tt.wantXPDBLease, err == nil, "lease of xpdb exists")
+
+			if !tt.wantErr {
+				assert.NoError(t, s.LocalUnlock(context.Background(), leaseIdentity, "default", "test", selector))
+				leases := &coordv1.LeaseList{}
+				assert.NoError(t, cl.List(context.Background(), leases))
+				assert.Empty(t, leases.Items)
+			}
+		})
+	}
+}
+
+func TestService_Lock_TargetRef(t *testing.T) {
+	newXPDB := func(name string) *xpdbv1alpha1.XPodDisruptionBudget {
+		return &xpdbv1alpha1.XPodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: xpdbv1alpha1.XPodDisruptionBudgetSpec{
+				TargetRef: &xpdbv1alpha1.XPodDisruptionBudgetTargetRef{APIVersion: "apps/v1", Kind: "StatefulSet", Name: name},
+			},
+		}
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).Build()
+	logger := zap.New(zap.UseDevMode(true))
+	s := NewService(&logger, cl, cl, nil, "default", nil)
+
+	// both xpdbs have an empty selector, they must not share a lease.
+	assert.NoError(t, s.Lock(context.Background(), "x-pdb-123/postgres", newXPDB("postgres")))
+	assert.NoError(t, s.Lock(context.Background(), "x-pdb-123/redis", newXPDB("redis")))
+
+	leases := &coordv1.LeaseList{}
+	assert.NoError(t, cl.List(context.Background(), leases))
+	assert.Len(t, leases.Items, 2)
+
+	assert.NoError(t, s.Unlock(context.Background(), "x-pdb-123/postgres", newXPDB("postgres")))
+	assert.NoError(t, s.Unlock(context.Background(), "x-pdb-123/redis", newXPDB("redis")))
+	assert.NoError(t, cl.List(context.Background(), leases))
+	assert.Empty(t, leases.Items)
+}
+
 type testLeaseFunc func(*coordv1.Lease)
 
-func makeTestLease(leaseNs, leaseID, podNs string, podSelector *metav1.LabelSelector, modifier ...testLeaseFunc) *coordv1.Lease {
-	lease := createLeaseForSelector(leaseNs, leaseID, podNs, podSelector)
+func makeTestLease(leaseNs, leaseID, podNs, xpdbName string, modifier ...testLeaseFunc) *coordv1.Lease {
+	lease := createLeaseForXPDB(leaseNs, leaseID, podNs, xpdbName)
 	for _, m := range modifier {
 		m(lease)
 	}
@@ -123,3 +226,16 @@ func makeTestLease(leaseNs, leaseID, podNs string, podSelector *metav1.LabelSele
 func leaseExpired(lease *coordv1.Lease) {
 	lease.Spec.AcquireTime = &metav1.MicroTime{Time: time.Now().Add(-time.Minute)}
 }
+
+func TestRetryAfter(t *testing.T) {
+	held := &LeaseHeldError{Holder: "x-pdb-666", RetryAfter: 3 * time.Second}
+	assert.Equal(t, time.Duration(0), RetryAfter(nil))
+	assert.Equal(t, time.Duration(0), RetryAfter(errors.New("boom")))
+	assert.Equal(t, 3*time.Second, RetryAfter(held))
+	assert.Equal(t, 3*time.Second, RetryAfter(fmt.Errorf("unable to lock local cluster: %w", held)))
+	assert.Equal(t, 4*time.Second, RetryAfter(fmt.Errorf("unable to lock remote clusters: %w", errors.Join(
+		errors.New("boom"),
+		fmt.Errorf("lock not acquired: %w", &LeaseHeldError{RetryAfter: 4 * time.Second}),
+		held,
+	))))
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package metrics
 
 import (
+	"time"
+
 	grpcprom "github.com/grpc-ecosystem/go-grpc-middleware/providers/prometheus"
 	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
@@ -32,6 +34,74 @@ const (
 	APICallResultError = "error"
 )
 
+// Scope is the set of clusters the pod counts of a XPDB are observed for.
+type Scope string
+
+const (
+	// ScopeLocal covers the pods of the local cluster.
+	ScopeLocal Scope = "local"
+	// ScopeRemote covers the pods of all remote clusters.
+	ScopeRemote Scope = "remote"
+	// ScopeTotal covers the pods of all clusters.
+	ScopeTotal Scope = "total"
+)
+
+// Verdict is the outcome of an admission request.
+type Verdict string
+
+const (
+	// VerdictAllowed means the pod may be disrupted.
+	VerdictAllowed Verdict = "allowed"
+	// VerdictDenied means the pod must not be disrupted.
+	VerdictDenied Verdict = "denied"
+)
+
+// DecisionReason names the component which decided an admission request.
+type DecisionReason string
+
+const (
+	// DecisionReasonAllowed means the disruption budget and the probes, if any, allowed the disruption.
+	DecisionReasonAllowed DecisionReason = "allowed"
+	// DecisionReasonBudget means the disruption budget denied the disruption.
+	DecisionReasonBudget DecisionReason = "budget"
+	// DecisionReasonProbe means the disruption probe denied the disruption.
+	DecisionReasonProbe DecisionReason = "probe"
+	// DecisionReasonPreActivity means the pod has pending pre-activities.
+	DecisionReasonPreActivity DecisionReason = "pre-activity"
+	// DecisionReasonLock means the lock could not be acquired.
+	DecisionReasonLock DecisionReason = "lock"
+	// DecisionReasonError means the request could not be evaluated.
+	DecisionReasonError DecisionReason = "error"
+	// DecisionReasonSuspended means the XPDB of the pod is suspended.
+	DecisionReasonSuspended DecisionReason = "suspended"
+	// DecisionReasonIgnored means the request is not subject to a XPDB,
+	// e.g. the pod is not protected or the disruption is involuntary.
+	DecisionReasonIgnored DecisionReason = "ignored"
+)
+
+// PreActivityResult is the outcome of a pre-activity.
+type PreActivityResult string
+
+const (
+	// PreActivityResultCompleted means the pre-activity completed within the timeout.
+	PreActivityResultCompleted PreActivityResult = "completed"
+	// PreActivityResultTimedOut means the pre-activity completed after it timed out.
+	PreActivityResultTimedOut PreActivityResult = "timed-out"
+)
+
+// AdmissionPhase is a step of the evaluation of an admission request.
+type AdmissionPhase string
+
+const (
+	AdmissionPhasePreActivities AdmissionPhase = "pre-activities"
+	AdmissionPhaseLookup        AdmissionPhase = "lookup"
+	AdmissionPhaseLock          AdmissionPhase = "lock"
+	AdmissionPhaseBudget        AdmissionPhase = "budget"
+	AdmissionPhaseProbe         AdmissionPhase = "probe"
+	AdmissionPhaseUnlock        AdmissionPhase = "unlock"
+	AdmissionPhaseTotal         AdmissionPhase = "total"
+)
+
 const (
 	xpdbNamespace    = "xpdb"
 	labelNamespace   = "namespace"
@@ -40,6 +110,16 @@ const (
 	labelResource    = "resource"
 	labelSubresource = "subresource"
 	labelOperation   = "operation"
+	labelCertificate = "certificate"
+	labelClient      = "client"
+	labelProxy       = "proxy"
+	labelRemote      = "remote"
+	labelXPDB        = "xpdb"
+	labelScope       = "scope"
+	labelVerdict     = "verdict"
+	labelReason      = "reason"
+	labelPhase       = "phase"
+	labelPolicy      = "policy"
 )
 
 var (
@@ -62,6 +142,81 @@ var (
 		Help:      "Counter that represents the number of errors when obtaining locks for xpdb.",
 	}, []string{labelNamespace})
 
+	certificateExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: xpdbNamespace,
+		Name:      "certificate_expiration_timestamp_seconds",
+		Help:      "Expiry time of the certificates issued by the built-in certificate manager in seconds since epoch.",
+	}, []string{labelCertificate})
+
+	proxyConnectFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: xpdbNamespace,
+		Name:      "proxy_connect_failures",
+		Help:      "Counter that represents the number of failed attempts to connect through an egress proxy.",
+	}, []string{labelClient, labelProxy})
+
+	remoteReachable = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: xpdbNamespace,
+		Name:      "remote_reachable",
+		Help:      "Whether the remote x-pdb server answered the last health check with SERVING (1) or not (0).",
+	}, []string{labelRemote})
+
+	remoteHealthCheckDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: xpdbNamespace,
+		Name:      "remote_health_check_duration_seconds",
+		Help:      "Round-trip latency of the health checks of the remote x-pdb servers.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{labelRemote})
+
+	xpdbExpectedPods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: xpdbNamespace,
+		Name:      "expected_pods",
+		Help:      "Number of pods expected by the workloads protected by the XPDB at its last evaluation.",
+	}, []string{labelNamespace, labelXPDB, labelScope})
+
+	xpdbHealthyPods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: xpdbNamespace,
+		Name:      "healthy_pods",
+		Help:      "Number of healthy pods protected by the XPDB at its last evaluation.",
+	}, []string{labelNamespace, labelXPDB, labelScope})
+
+	xpdbDesiredHealthyPods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: xpdbNamespace,
+		Name:      "desired_healthy_pods",
+		Help:      "Minimum number of healthy pods required by the XPDB at its last evaluation.",
+	}, []string{labelNamespace, labelXPDB, labelScope})
+
+	xpdbDisruptionsAllowed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: xpdbNamespace,
+		Name:      "disruptions_allowed",
+		Help:      "Number of pod disruptions allowed by the XPDB at its last evaluation.",
+	}, []string{labelNamespace, labelXPDB, labelScope})
+
+	admissionDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: xpdbNamespace,
+		Name:      "admission_decisions",
+		Help:      "Counter that represents the number of admission requests by verdict and the reason of the verdict.",
+	}, []string{labelNamespace, labelVerdict, labelReason})
+
+	admissionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: xpdbNamespace,
+		Name:      "admission_duration_seconds",
+		Help:      "Latency of the phases of the evaluation of admission requests.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.15, 0.25, 0.5, 1, 2.5, 5},
+	}, []string{labelPhase})
+
+	preActivityDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: xpdbNamespace,
+		Name:      "pre_activity_duration_seconds",
+		Help:      "Time between the request and the completion of pre-activities.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600, 7200},
+	}, []string{labelNamespace, labelResult})
+
+	preActivityTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: xpdbNamespace,
+		Name:      "pre_activity_timeouts",
+		Help:      "Counter that represents the number of pre-activities which didn't complete within the timeout.",
+	}, []string{labelNamespace, labelPolicy})
+
 	GrpcClientMetrics = grpcprom.NewClientMetrics(
 		grpcprom.WithClientHandlingTimeHistogram(
 			grpcprom.WithHistogramBuckets([]float64{0.01, 0.1, 0.3, 0.6, 1, 3, 5}),
@@ -84,9 +239,69 @@ func ObserveLockError(namespace string) {
 	lockErrors.WithLabelValues(namespace).Inc()
 }
 
+// ObserveCertificateExpiry records the expiry time of a certificate.
+func ObserveCertificateExpiry(certificate string, notAfter time.Time) {
+	certificateExpiry.WithLabelValues(certificate).Set(float64(notAfter.Unix()))
+}
+
+// ObserveProxyConnectFailure increments the proxy connect failures counter.
+func ObserveProxyConnectFailure(client, proxy string) {
+	proxyConnectFailures.WithLabelValues(client, proxy).Inc()
+}
+
+// ObservePeerHealthCheck records the result of a health check of a remote x-pdb server.
+func ObservePeerHealthCheck(remote string, reachable bool, rtt time.Duration) {
+	value := 0.0
+	if reachable {
+		value = 1
+	}
+	remoteReachable.WithLabelValues(remote).Set(value)
+	remoteHealthCheckDuration.WithLabelValues(remote).Observe(rtt.Seconds())
+}
+
+// ObserveXPDBState records the pod counts of a XPDB for a scope.
+func ObserveXPDBState(namespace, xpdb string, scope Scope, expected, healthy, desiredHealthy int32) {
+	xpdbExpectedPods.WithLabelValues(namespace, xpdb, string(scope)).Set(float64(expected))
+	xpdbHealthyPods.WithLabelValues(namespace, xpdb, string(scope)).Set(float64(healthy))
+	xpdbDesiredHealthyPods.WithLabelValues(namespace, xpdb, string(scope)).Set(float64(desiredHealthy))
+	xpdbDisruptionsAllowed.WithLabelValues(namespace, xpdb, string(scope)).Set(float64(max(healthy-desiredHealthy, 0)))
+}
+
+// ObserveAdmissionDecision increments the admission decisions counter.
+func ObserveAdmissionDecision(namespace string, verdict Verdict, reason DecisionReason) {
+	admissionDecisions.WithLabelValues(namespace, string(verdict), string(reason)).Inc()
+}
+
+// ObserveAdmissionDuration records the latency of a phase of an admission request.
+func ObserveAdmissionDuration(phase AdmissionPhase, duration time.Duration) {
+	admissionDuration.WithLabelValues(string(phase)).Observe(duration.Seconds())
+}
+
+// ObservePreActivityCompleted records the duration of a completed pre-activity.
+func ObservePreActivityCompleted(namespace string, result PreActivityResult, duration time.Duration) {
+	preActivityDuration.WithLabelValues(namespace, string(result)).Observe(duration.Seconds())
+}
+
+// ObservePreActivityTimeout increments the pre-activity timeouts counter.
+func ObservePreActivityTimeout(namespace, policy string) {
+	preActivityTimeouts.WithLabelValues(namespace, policy).Inc()
+}
+
 func init() {
 	metrics.Registry.MustRegister(podMatchingMultipleXPDBs)
 	metrics.Registry.MustRegister(evictionRejectedCounter)
 	metrics.Registry.MustRegister(lockErrors)
+	metrics.Registry.MustRegister(certificateExpiry)
+	metrics.Registry.MustRegister(proxyConnectFailures)
+	metrics.Registry.MustRegister(remoteReachable)
+	metrics.Registry.MustRegister(remoteHealthCheckDuration)
+	metrics.Registry.MustRegister(xpdbExpectedPods)
+	metrics.Registry.MustRegister(xpdbHealthyPods)
+	metrics.Registry.MustRegister(xpdbDesiredHealthyPods)
+	metrics.Registry.MustRegister(xpdbDisruptionsAllowed)
+	metrics.Registry.MustRegister(admissionDecisions)
+	metrics.Registry.MustRegister(admissionDuration)
+	metrics.Registry.MustRegister(preActivityDuration)
+	metrics.Registry.MustRegister(preActivityTimeouts)
 	metrics.Registry.MustRegister(GrpcClientMetrics)
 }
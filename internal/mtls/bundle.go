@@ -0,0 +1,73 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mtls
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// trustBundle is a PEM encoded CA bundle stored in a file.
+// The file is read again whenever its size or modification time changes,
+// so rotated CAs are picked up without restarting.
+type trustBundle struct {
+	path string
+
+	mux     sync.Mutex
+	modTime time.Time
+	size    int64
+	pem     []byte
+	pool    *x509.CertPool
+}
+
+func newTrustBundle(path string) *trustBundle {
+	return &trustBundle{path: path}
+}
+
+// load returns the current PEM encoded bundle and its certificate pool.
+func (b *trustBundle) load() ([]byte, *x509.CertPool, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA cert: %w", err)
+	}
+	if b.pool != nil && info.ModTime().Equal(b.modTime) && info.Size() == b.size {
+		return b.pem, b.pool, nil
+	}
+
+	//nolint:gosec
+	caBytes, err := os.ReadFile(b.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA cert: %w", err)
+	}
+
+	certPool := x509.NewCertPool()
+	if ok := certPool.AppendCertsFromPEM(caBytes); !ok {
+		return nil, nil, fmt.Errorf("failed to append CA cert %s to CA pool", b.path)
+	}
+
+	b.modTime = info.ModTime()
+	b.size = info.Size()
+	b.pem = caBytes
+	b.pool = certPool
+	return b.pem, b.pool, nil
+}
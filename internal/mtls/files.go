@@ -0,0 +1,212 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/form3tech-oss/x-pdb/internal/remotes"
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+)
+
+// FileProvider reads the certificates from a directory which contains
+// `ca.crt`, `tls.crt` and `tls.key` files.
+// Remotes may override the CA bundle and client certificate with their own files.
+// Key pairs and CA bundles are reloaded when the files change.
+type FileProvider struct {
+	ctx      context.Context
+	logger   *logr.Logger
+	certsDir string
+	remotes  *remotes.Config
+
+	mux          sync.Mutex
+	certWatchers map[string]*certwatcher.CertWatcher
+	bundles      map[string]*trustBundle
+}
+
+// NewFileProvider creates a new FileProvider.
+func NewFileProvider(ctx context.Context, logger *logr.Logger, certsDir string, remotesConfig *remotes.Config) *FileProvider {
+	return &FileProvider{
+		ctx:          ctx,
+		logger:       logger,
+		certsDir:     certsDir,
+		remotes:      remotesConfig,
+		certWatchers: map[string]*certwatcher.CertWatcher{},
+		bundles:      map[string]*trustBundle{},
+	}
+}
+
+// ServerConfig returns the TLS configuration of the state server.
+// Client certificates issued by the default CA or by the CA of any remote are accepted.
+func (p *FileProvider) ServerConfig() (*tls.Config, error) {
+	cw, err := p.getCertWatcher(p.defaultCertFile(), p.defaultKeyFile())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.clientCAs(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		GetConfigForClient: func(_ *tls.ClientHelloInfo) (*tls.Config, error) {
+			certPool, err := p.clientCAs()
+			if err != nil {
+				return nil, err
+			}
+			return &tls.Config{
+				MinVersion:     tls.VersionTLS13,
+				ClientAuth:     tls.RequireAndVerifyClientCert,
+				ClientCAs:      certPool,
+				GetCertificate: cw.GetCertificate,
+				// the returned config replaces the one grpc added the h2 protocol to.
+				NextProtos: []string{"h2"},
+			}, nil
+		},
+	}, nil
+}
+
+// ClientConfig returns the TLS configuration used to connect to the supplied remote.
+func (p *FileProvider) ClientConfig(remote remotes.Remote) (*tls.Config, error) {
+	certFile, keyFile, caFile := p.defaultCertFile(), p.defaultKeyFile(), p.defaultCAFile()
+	var serverName string
+	minVersion := uint16(tls.VersionTLS12)
+	if t := remote.TLS; t != nil {
+		if t.CertFile != "" {
+			certFile, keyFile = t.CertFile, t.KeyFile
+		}
+		if t.CAFile != "" {
+			caFile = t.CAFile
+		}
+		serverName = t.ServerName
+		if t.MinVersion == remotes.TLSVersion13 {
+			minVersion = tls.VersionTLS13
+		}
+	}
+
+	cw, err := p.getCertWatcher(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// the certificate is verified against the host of the endpoint unless the remote overrides it.
+	if serverName == "" {
+		serverName = ServerName(remote.Endpoint)
+	}
+
+	bundle := p.getTrustBundle(caFile)
+	if _, _, err := bundle.load(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		ServerName: serverName,
+		MinVersion: minVersion,
+		// the server certificate is verified in VerifyConnection against
+		// the current CA bundle, so clients pick up rotated CAs.
+		//nolint:gosec
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			_, roots, err := bundle.load()
+			if err != nil {
+				return err
+			}
+			return VerifyServerCertificate(cs, roots, serverName)
+		},
+		GetClientCertificate: func(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cw.GetCertificate(nil)
+		},
+	}, nil
+}
+
+// clientCAs returns the union of the default CA bundle and the CA bundles of all remotes.
+func (p *FileProvider) clientCAs() (*x509.CertPool, error) {
+	_, certPool, err := p.getTrustBundle(p.defaultCAFile()).load()
+	if err != nil {
+		return nil, err
+	}
+	certPool = certPool.Clone()
+
+	for _, r := range p.remotes.Remotes {
+		if r.TLS == nil || r.TLS.CAFile == "" {
+			continue
+		}
+		caBytes, _, err := p.getTrustBundle(r.TLS.CAFile).load()
+		if err != nil {
+			// a broken bundle of one remote must not lock out all the others.
+			p.logger.Error(err, "unable to load ca bundle of remote", "endpoint", r.Endpoint)
+			continue
+		}
+		certPool.AppendCertsFromPEM(caBytes)
+	}
+	return certPool, nil
+}
+
+func (p *FileProvider) getCertWatcher(certFile, keyFile string) (*certwatcher.CertWatcher, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	key := certFile + ":" + keyFile
+	if cw, found := p.certWatchers[key]; found {
+		return cw, nil
+	}
+
+	cw, err := certwatcher.New(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cert watcher: %w", err)
+	}
+
+	go func() {
+		if err := cw.Start(p.ctx); err != nil {
+			p.logger.Error(err, "certificate watcher error")
+		}
+	}()
+
+	p.certWatchers[key] = cw
+	return cw, nil
+}
+
+func (p *FileProvider) getTrustBundle(caFile string) *trustBundle {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if b, found := p.bundles[caFile]; found {
+		return b
+	}
+	b := newTrustBundle(caFile)
+	p.bundles[caFile] = b
+	return b
+}
+
+func (p *FileProvider) defaultCAFile() string {
+	return filepath.Join(p.certsDir, "ca.crt")
+}
+
+func (p *FileProvider) defaultCertFile() string {
+	return filepath.Join(p.certsDir, "tls.crt")
+}
+
+func (p *FileProvider) defaultKeyFile() string {
+	return filepath.Join(p.certsDir, "tls.key")
+}
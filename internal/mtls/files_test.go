@@ -0,0 +1,147 @@
+package mtls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/x-pdb/internal/remotes"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeyPair writes a certificate for name, a DNS name or IP address, issued by ca into dir.
+func (ca *testCA) writeKeyPair(t *testing.T, dir, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	} else {
+		tmpl.DNSNames = []string{name}
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tls.crt"),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tls.key"),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600))
+}
+
+func (ca *testCA) writeBundle(t *testing.T, path string) {
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600))
+}
+
+func TestFileProviderPerRemoteTLS(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	logger := logr.Discard()
+
+	blueCA := newTestCA(t, "blue")
+	greyCA := newTestCA(t, "grey")
+
+	// blue uses its own PKI by default.
+	blueDir := t.TempDir()
+	blueCA.writeKeyPair(t, blueDir, "x-pdb.blue")
+	blueCA.writeBundle(t, filepath.Join(blueDir, "ca.crt"))
+
+	// grey is run by a different team and presents certificates of its own PKI.
+	greyDir := t.TempDir()
+	greyCA.writeKeyPair(t, greyDir, "x-pdb.grey")
+	greyCA.writeBundle(t, filepath.Join(greyDir, "ca.crt"))
+	greyCAFile := filepath.Join(t.TempDir(), "grey-ca.crt")
+
+	greyRemote := remotes.Remote{
+		Endpoint: "10.0.0.1:443",
+		TLS: &remotes.TLS{
+			CAFile:     greyCAFile,
+			ServerName: "x-pdb.grey",
+			MinVersion: remotes.TLSVersion13,
+		},
+	}
+	blue := NewFileProvider(ctx, &logger, blueDir, &remotes.Config{Remotes: []remotes.Remote{greyRemote}})
+	blueRemote := remotes.Remote{
+		Endpoint: "x-pdb.blue:443",
+		TLS:      &remotes.TLS{CAFile: filepath.Join(blueDir, "ca.crt")},
+	}
+	grey := NewFileProvider(ctx, &logger, greyDir, &remotes.Config{Remotes: []remotes.Remote{blueRemote}})
+
+	blueServerCfg, err := blue.ServerConfig()
+	require.NoError(t, err)
+	greyServerCfg, err := grey.ServerConfig()
+	require.NoError(t, err)
+
+	greyClientCfg, err := grey.ClientConfig(blueRemote)
+	require.NoError(t, err)
+	assert.Equal(t, "x-pdb.blue", greyClientCfg.ServerName)
+
+	t.Run("server rejects clients of unknown pki", func(t *testing.T) {
+		assert.Error(t, handshake(t, blueServerCfg, greyClientCfg))
+	})
+
+	t.Run("server accepts clients of remote pki once the bundle is written", func(t *testing.T) {
+		greyCA.writeBundle(t, greyCAFile)
+		assert.NoError(t, handshake(t, blueServerCfg, greyClientCfg))
+	})
+
+	t.Run("client verifies remote with remote ca and server name", func(t *testing.T) {
+		blueClientCfg, err := blue.ClientConfig(greyRemote)
+		require.NoError(t, err)
+		assert.Equal(t, uint16(tls.VersionTLS13), blueClientCfg.MinVersion)
+		assert.NoError(t, handshake(t, greyServerCfg, blueClientCfg))
+	})
+
+	t.Run("client rejects unexpected server name", func(t *testing.T) {
+		r := greyRemote
+		r.TLS = &remotes.TLS{CAFile: greyCAFile, ServerName: "x-pdb.gold"}
+		blueClientCfg, err := blue.ClientConfig(r)
+		require.NoError(t, err)
+		assert.Error(t, handshake(t, greyServerCfg, blueClientCfg))
+	})
+
+	t.Run("client verifies ip address of remote without server name", func(t *testing.T) {
+		goldCA := newTestCA(t, "gold")
+		goldDir := t.TempDir()
+		goldCA.writeKeyPair(t, goldDir, "x-pdb.gold")
+		goldCA.writeBundle(t, filepath.Join(goldDir, "ca.crt"))
+		gold := NewFileProvider(ctx, &logger, goldDir, &remotes.Config{})
+		clientCfg, err := gold.ClientConfig(remotes.Remote{Endpoint: "127.0.0.1:443"})
+		require.NoError(t, err)
+
+		for name, wantErr := range map[string]bool{"127.0.0.1": false, "10.20.30.40": true, "x-pdb.gold": true} {
+			dir := t.TempDir()
+			goldCA.writeKeyPair(t, dir, name)
+			cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"))
+			require.NoError(t, err)
+			serverCfg := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+
+			err = handshake(t, serverCfg, clientCfg)
+			if wantErr {
+				assert.Error(t, err, name)
+			} else {
+				assert.NoError(t, err, name)
+			}
+		}
+	})
+}
@@ -0,0 +1,34 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mtls provides the TLS configuration used for the mutually
+// authenticated communication between x-pdb servers.
+package mtls
+
+import (
+	"crypto/tls"
+
+	"github.com/form3tech-oss/x-pdb/internal/remotes"
+)
+
+// Provider supplies the TLS configurations of the state server
+// and of the clients connecting to remote state servers.
+type Provider interface {
+	// ServerConfig returns the TLS configuration of the state server.
+	ServerConfig() (*tls.Config, error)
+	// ClientConfig returns the TLS configuration used to connect to the supplied remote.
+	ClientConfig(remote remotes.Remote) (*tls.Config, error)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/form3tech-oss/x-pdb/internal/remotes"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+)
+
+// SPIFFEProvider obtains X.509 SVIDs and trust bundles from the SPIFFE Workload API.
+// Bundles of federated trust domains are used to verify the remote clusters.
+// SVIDs and bundles are rotated as the Workload API pushes updates.
+type SPIFFEProvider struct {
+	source     *workloadapi.X509Source
+	allowedIDs []spiffeid.ID
+}
+
+// NewSPIFFEProvider connects to the Workload API listening on socketAddr
+// (e.g. unix:///run/spire/sockets/agent.sock) and blocks until the first SVID has been received.
+// The state server only accepts clients presenting one of the SPIFFE IDs of the supplied remotes.
+func NewSPIFFEProvider(ctx context.Context, socketAddr string, cfg *remotes.Config) (*SPIFFEProvider, error) {
+	allowedIDs := make([]spiffeid.ID, 0, len(cfg.Remotes))
+	for _, r := range cfg.Remotes {
+		id, err := remoteSPIFFEID(r)
+		if err != nil {
+			return nil, err
+		}
+		allowedIDs = append(allowedIDs, id)
+	}
+
+	source, err := workloadapi.NewX509Source(ctx,
+		workloadapi.WithClientOptions(workloadapi.WithAddr(socketAddr)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create x509 source: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = source.Close()
+	}()
+
+	return &SPIFFEProvider{
+		source:     source,
+		allowedIDs: allowedIDs,
+	}, nil
+}
+
+// ServerConfig returns the TLS configuration of the state server.
+func (p *SPIFFEProvider) ServerConfig() (*tls.Config, error) {
+	cfg := tlsconfig.MTLSServerConfig(p.source, p.source, tlsconfig.AuthorizeOneOf(p.allowedIDs...))
+	cfg.MinVersion = tls.VersionTLS13
+	return cfg, nil
+}
+
+// ClientConfig returns the TLS configuration used to connect to the supplied remote.
+// The remote must present the SPIFFE ID it is configured with.
+func (p *SPIFFEProvider) ClientConfig(remote remotes.Remote) (*tls.Config, error) {
+	id, err := remoteSPIFFEID(remote)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := tlsconfig.MTLSClientConfig(p.source, p.source, tlsconfig.AuthorizeID(id))
+	cfg.MinVersion = tls.VersionTLS12
+	return cfg, nil
+}
+
+func remoteSPIFFEID(remote remotes.Remote) (spiffeid.ID, error) {
+	if remote.SPIFFEID == "" {
+		return spiffeid.ID{}, fmt.Errorf("remote %q has no spiffeID configured", remote.Endpoint)
+	}
+	id, err := spiffeid.FromString(remote.SPIFFEID)
+	if err != nil {
+		return spiffeid.ID{}, fmt.Errorf("invalid spiffeID of remote %q: %w", remote.Endpoint, err)
+	}
+	return id, nil
+}
@@ -0,0 +1,211 @@
+package mtls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/x-pdb/internal/remotes"
+	"github.com/spiffe/go-spiffe/v2/proto/spiffe/workload"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// fakeWorkloadAPI serves a static X509-SVID and bundles over a unix socket.
+type fakeWorkloadAPI struct {
+	workload.UnimplementedSpiffeWorkloadAPIServer
+	resp *workload.X509SVIDResponse
+}
+
+func (f *fakeWorkloadAPI) FetchX509SVID(_ *workload.X509SVIDRequest, stream workload.SpiffeWorkloadAPI_FetchX509SVIDServer) error {
+	if err := stream.Send(f.resp); err != nil {
+		return err
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, trustDomain string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: trustDomain},
+		URIs:                  []*url.URL{{Scheme: "spiffe", Host: trustDomain}},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) svid(t *testing.T, id string) (certDER, keyDER []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	u, err := url.Parse(id)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		URIs:         []*url.URL{u},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err = x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err = x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return certDER, keyDER
+}
+
+// startFakeWorkloadAPI starts a Workload API which hands out an SVID for id issued by ca
+// and trusts the bundles of the federated CAs.
+func startFakeWorkloadAPI(t *testing.T, ca *testCA, id string, federated map[string]*testCA) string {
+	certDER, keyDER := ca.svid(t, id)
+	resp := &workload.X509SVIDResponse{
+		Svids: []*workload.X509SVID{
+			{
+				SpiffeId:    id,
+				X509Svid:    certDER,
+				X509SvidKey: keyDER,
+				Bundle:      ca.cert.Raw,
+			},
+		},
+		FederatedBundles: map[string][]byte{},
+	}
+	for td, fca := range federated {
+		resp.FederatedBundles["spiffe://"+td] = fca.cert.Raw
+	}
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	lis, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	workload.RegisterSpiffeWorkloadAPIServer(srv, &fakeWorkloadAPI{resp: resp})
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	return "unix://" + socket
+}
+
+func handshake(t *testing.T, serverCfg, clientCfg *tls.Config) error {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer conn.Close()
+		errCh <- tls.Server(conn, serverCfg).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", lis.Addr().String(), clientCfg)
+	if err != nil {
+		<-errCh
+		return err
+	}
+	defer conn.Close()
+
+	// with TLS 1.3 the client finishes the handshake before the server
+	// has verified the client certificate.
+	return <-errCh
+}
+
+func TestSPIFFEProvider(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	const (
+		blueTD   = "blue.example.org"
+		greyTD   = "grey.example.org"
+		goldTD   = "gold.example.org"
+		blueID   = "spiffe://blue.example.org/ns/x-pdb/sa/x-pdb"
+		greyID   = "spiffe://grey.example.org/ns/x-pdb/sa/x-pdb"
+		goldID   = "spiffe://gold.example.org/ns/x-pdb/sa/x-pdb"
+		endpoint = "x-pdb.blue:9643"
+	)
+
+	blueCA := newTestCA(t, blueTD)
+	greyCA := newTestCA(t, greyTD)
+	goldCA := newTestCA(t, goldTD)
+
+	// blue trusts grey and gold, grey and gold trust blue.
+	blue, err := NewSPIFFEProvider(ctx,
+		startFakeWorkloadAPI(t, blueCA, blueID, map[string]*testCA{greyTD: greyCA, goldTD: goldCA}),
+		&remotes.Config{Remotes: []remotes.Remote{{Endpoint: "x-pdb.grey:9643", SPIFFEID: greyID}}},
+	)
+	require.NoError(t, err)
+
+	grey, err := NewSPIFFEProvider(ctx,
+		startFakeWorkloadAPI(t, greyCA, greyID, map[string]*testCA{blueTD: blueCA}),
+		&remotes.Config{Remotes: []remotes.Remote{{Endpoint: endpoint, SPIFFEID: blueID}}},
+	)
+	require.NoError(t, err)
+
+	gold, err := NewSPIFFEProvider(ctx,
+		startFakeWorkloadAPI(t, goldCA, goldID, map[string]*testCA{blueTD: blueCA}),
+		&remotes.Config{Remotes: []remotes.Remote{{Endpoint: endpoint, SPIFFEID: blueID}}},
+	)
+	require.NoError(t, err)
+
+	serverCfg, err := blue.ServerConfig()
+	require.NoError(t, err)
+
+	t.Run("configured remote can connect", func(t *testing.T) {
+		clientCfg, err := grey.ClientConfig(remotes.Remote{Endpoint: endpoint, SPIFFEID: blueID})
+		require.NoError(t, err)
+		assert.NoError(t, handshake(t, serverCfg, clientCfg))
+	})
+
+	t.Run("client rejects server with unexpected spiffe id", func(t *testing.T) {
+		clientCfg, err := grey.ClientConfig(remotes.Remote{Endpoint: endpoint, SPIFFEID: "spiffe://blue.example.org/other"})
+		require.NoError(t, err)
+		assert.Error(t, handshake(t, serverCfg, clientCfg))
+	})
+
+	t.Run("server rejects federated client which is not a configured remote", func(t *testing.T) {
+		clientCfg, err := gold.ClientConfig(remotes.Remote{Endpoint: endpoint, SPIFFEID: blueID})
+		require.NoError(t, err)
+		assert.Error(t, handshake(t, serverCfg, clientCfg))
+	})
+
+	t.Run("remote without spiffe id is rejected", func(t *testing.T) {
+		_, err := grey.ClientConfig(remotes.Remote{Endpoint: endpoint})
+		assert.Error(t, err)
+	})
+}
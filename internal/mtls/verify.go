@@ -0,0 +1,60 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// VerifyServerCertificate verifies the certificate presented by a server against roots
+// and checks that it was issued for serverName, a DNS name or an IP address.
+// It is used by configs which skip the default verification to pick up rotated CAs.
+// The server name of the connection state can't be used, crypto/tls leaves it empty
+// for IP addresses as they are not sent as SNI.
+func VerifyServerCertificate(cs tls.ConnectionState, roots *x509.CertPool, serverName string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("server did not present a certificate")
+	}
+	if serverName == "" {
+		return fmt.Errorf("no server name to verify the server certificate against")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+// ServerName returns the host of endpoint, the name its server certificate is verified against.
+func ServerName(endpoint string) string {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return strings.Trim(endpoint, "[]")
+	}
+	return host
+}
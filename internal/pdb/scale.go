@@ -20,7 +20,10 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -28,6 +31,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/discovery"
@@ -52,11 +56,32 @@ type controllerAndScale struct {
 // controllers and their scale.
 type podControllerFinder func(ctx context.Context, controllerRef *metav1.OwnerReference, namespace string) (*controllerAndScale, error)
 
+// targetSelectorTTL is the time the pod selectors of targets are memoized for.
+var targetSelectorTTL = 30 * time.Second
+
 // ScaleFinder implements the business logic
 // to find the `scale` sub resource of a pod.
 type ScaleFinder struct {
 	client          client.Client
 	discoveryClient *discovery.DiscoveryClient
+
+	now       func() time.Time
+	mux       sync.Mutex
+	selectors map[targetKey]targetSelector
+	lastSweep time.Time
+}
+
+// targetKey identifies the target of an xpdb.
+type targetKey struct {
+	namespace  string
+	apiVersion string
+	kind       string
+	name       string
+}
+
+type targetSelector struct {
+	selector labels.Selector
+	expires  time.Time
 }
 
 // NewScaleFinder instantiates a new ScaleFinder.
@@ -64,6 +89,8 @@ func NewScaleFinder(client client.Client, discoveryClient *discovery.DiscoveryCl
 	return &ScaleFinder{
 		client:          client,
 		discoveryClient: discoveryClient,
+		now:             time.Now,
+		selectors:       map[targetKey]targetSelector{},
 	}
 }
 
@@ -132,6 +159,94 @@ func (s *ScaleFinder) FindExpectedScale(ctx context.Context, pods []*corev1.Pod)
 	return expectedCount, unmanagedPods, err
 }
 
+// FindTarget resolves the workload referenced by targetRef to its pod selector and number of replicas
+// using the scale subresource of the workload.
+func (s *ScaleFinder) FindTarget(
+	ctx context.Context,
+	namespace string,
+	targetRef *xpdbv1alpha1.XPodDisruptionBudgetTargetRef,
+) (selector labels.Selector, replicas int32, err error) {
+	gv, err := schema.ParseGroupVersion(targetRef.APIVersion)
+	if err != nil {
+		return nil, 0, err
+	}
+	res := unstructured.Unstructured{}
+	res.SetGroupVersionKind(gv.WithKind(targetRef.Kind))
+	res.SetNamespace(namespace)
+	res.SetName(targetRef.Name)
+	scale := unstructured.Unstructured{}
+	scale.SetGroupVersionKind(autoscalingv1.SchemeGroupVersion.WithKind("Scale"))
+	err = s.client.SubResource("scale").Get(ctx, &res, &scale)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot get scale subresource of %s %s/%s: %w", targetRef.Kind, namespace, targetRef.Name, err)
+	}
+
+	return targetFromScale(&scale)
+}
+
+// FindTargetSelector returns the pod selector of the workload referenced by targetRef.
+// Pod selectors of workloads rarely change, they are memoized for targetSelectorTTL
+// so matching pods against xpdbs doesn't get the scale subresource of every target on every admission.
+// Errors are not memoized.
+func (s *ScaleFinder) FindTargetSelector(
+	ctx context.Context,
+	namespace string,
+	targetRef *xpdbv1alpha1.XPodDisruptionBudgetTargetRef,
+) (labels.Selector, error) {
+	key := targetKey{namespace: namespace, apiVersion: targetRef.APIVersion, kind: targetRef.Kind, name: targetRef.Name}
+
+	s.mux.Lock()
+	entry, found := s.selectors[key]
+	s.mux.Unlock()
+	if found && s.now().Before(entry.expires) {
+		return entry.selector, nil
+	}
+
+	selector, _, err := s.FindTarget(ctx, namespace, targetRef)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	now := s.now()
+	s.selectors[key] = targetSelector{selector: selector, expires: now.Add(targetSelectorTTL)}
+
+	// remove the selectors of deleted xpdbs.
+	if now.Sub(s.lastSweep) < targetSelectorTTL {
+		return selector, nil
+	}
+	s.lastSweep = now
+	for k, entry := range s.selectors {
+		if !now.Before(entry.expires) {
+			delete(s.selectors, k)
+		}
+	}
+	return selector, nil
+}
+
+// targetFromScale returns the pod selector and the desired replicas of a scale subresource.
+func targetFromScale(scale *unstructured.Unstructured) (labels.Selector, int32, error) {
+	// spec.replicas is omitted if the workload is scaled to zero.
+	replicas, _, err := unstructured.NestedInt64(scale.Object, "spec", "replicas")
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid spec.replicas in scale subresource %v: %w", scale.Object, err)
+	}
+	selector, _, err := unstructured.NestedString(scale.Object, "status", "selector")
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid status.selector in scale subresource %v: %w", scale.Object, err)
+	}
+	if selector == "" {
+		return nil, 0, fmt.Errorf("scale subresource %v does not expose a pod selector", scale.Object)
+	}
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid pod selector %q in scale subresource: %w", selector, err)
+	}
+	return sel, int32(replicas), nil
+}
+
 func (s *ScaleFinder) finders() []podControllerFinder {
 	return []podControllerFinder{s.getPodReplicationController, s.getPodDeployment, s.getPodReplicaSet, s.getPodStatefulSet, s.getScaleController}
 }
@@ -0,0 +1,66 @@
+package pdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestTargetFromScale(t *testing.T) {
+	tests := []struct {
+		name         string
+		scale        map[string]interface{}
+		wantReplicas int32
+		wantMatch    labels.Set
+		wantErr      bool
+	}{
+		{
+			name: "should return selector and replicas",
+			scale: map[string]interface{}{
+				"spec":   map[string]interface{}{"replicas": int64(3)},
+				"status": map[string]interface{}{"selector": "app=test,tier in (db)"},
+			},
+			wantReplicas: 3,
+			wantMatch:    labels.Set{"app": "test", "tier": "db"},
+		},
+		{
+			name: "should return zero replicas if scaled to zero",
+			scale: map[string]interface{}{
+				"spec":   map[string]interface{}{},
+				"status": map[string]interface{}{"selector": "app=test"},
+			},
+			wantReplicas: 0,
+			wantMatch:    labels.Set{"app": "test"},
+		},
+		{
+			name: "should error without selector",
+			scale: map[string]interface{}{
+				"spec": map[string]interface{}{"replicas": int64(3)},
+			},
+			wantErr: true,
+		},
+		{
+			name: "should error on invalid selector",
+			scale: map[string]interface{}{
+				"spec":   map[string]interface{}{"replicas": int64(3)},
+				"status": map[string]interface{}{"selector": "app in test"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, replicas, err := targetFromScale(&unstructured.Unstructured{Object: tt.scale})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantReplicas, replicas)
+			assert.True(t, selector.Matches(tt.wantMatch))
+		})
+	}
+}
@@ -17,16 +17,23 @@ limitations under the License.
 package pdb
 
 import (
+	"cmp"
 	"context"
+	"slices"
 	"time"
 
 	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
 	"github.com/form3tech-oss/x-pdb/internal/converters"
+	"github.com/form3tech-oss/x-pdb/internal/metrics"
+	"github.com/form3tech-oss/x-pdb/internal/remotes"
 	stateclient "github.com/form3tech-oss/x-pdb/internal/state/client"
+	"github.com/form3tech-oss/x-pdb/internal/tracing"
 	statepb "github.com/form3tech-oss/x-pdb/pkg/proto/state/v1"
 	"github.com/go-logr/logr"
 	"github.com/sourcegraph/conc/pool"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -35,6 +42,31 @@ import (
 
 var remoteGetStateTimeout = 2 * time.Second
 
+// LocalCluster names the local cluster in the pod counts of an Evaluation.
+const LocalCluster = "local"
+
+// ClusterCounts are the pod counts of a XPDB in a single cluster.
+type ClusterCounts struct {
+	// Cluster is LocalCluster or the endpoint of a remote cluster.
+	Cluster  string
+	Expected int32
+	Healthy  int32
+}
+
+// Evaluation is the outcome of the evaluation of a XPDB for the disruption of a pod.
+type Evaluation struct {
+	// Allowed is true if the pod can be disrupted.
+	Allowed bool
+	// Expected is the number of pods expected across all clusters.
+	Expected int32
+	// Healthy is the number of healthy pods across all clusters.
+	Healthy int32
+	// DesiredHealthy is the minimum number of healthy pods required by the XPDB.
+	DesiredHealthy int32
+	// Clusters holds the pod counts of the local and each remote cluster.
+	Clusters []ClusterCounts
+}
+
 // Service implements the business logic
 // to make a decision whether or not a Pod can
 // be disrupted. It talks to external endpoints
@@ -46,6 +78,7 @@ type Service struct {
 	leaseNamespace  string
 	scaleFinder     *ScaleFinder
 	stateClientPool *stateclient.ClientPool
+	remotes         *remotes.Config
 	remoteEndpoints []string
 }
 
@@ -57,7 +90,7 @@ func NewService(
 	scaleFinder *ScaleFinder,
 	stateClientPool *stateclient.ClientPool,
 	leaseNamespace string,
-	remoteEndpoints []string,
+	remotesConfig *remotes.Config,
 ) *Service {
 	return &Service{
 		logger:          logger,
@@ -66,12 +99,19 @@ func NewService(
 		leaseNamespace:  leaseNamespace,
 		scaleFinder:     scaleFinder,
 		stateClientPool: stateClientPool,
-		remoteEndpoints: remoteEndpoints,
+		remotes:         remotesConfig,
+		remoteEndpoints: remotesConfig.Endpoints(),
 	}
 }
 
 // GetPodCounts returns the number of desired/actual healthy pods for the supplied namespace and selector.
 func (s *Service) GetPodCounts(ctx context.Context, namespace string, selector *metav1.LabelSelector) (expectedCount, healthy int32, err error) {
+	ctx, span := tracing.Start(ctx, "pdb.Service.GetPodCounts", tracing.AttributeNamespace.String(namespace))
+	defer func() {
+		span.SetAttributes(tracing.AttributeExpectedCount.Int64(int64(expectedCount)), tracing.AttributeHealthyCount.Int64(int64(healthy)))
+		tracing.End(span, err)
+	}()
+
 	pods, err := s.getPodsMatchingSelector(ctx, namespace, selector)
 	if err != nil {
 		return expectedCount, healthy, err
@@ -87,29 +127,88 @@ func (s *Service) GetPodCounts(ctx context.Context, namespace string, selector *
 	return expectedCount, healthy, nil
 }
 
-// CanXPdbBeDisrupted looks up both local and remote pods and calculates if a disruption would be acceptable.
+// GetTargetPodCounts returns the number of desired/actual healthy pods of the workload referenced by targetRef.
+// The number of replicas of the workload is the expected number of pods.
+func (s *Service) GetTargetPodCounts(
+	ctx context.Context,
+	namespace string,
+	targetRef *xpdbv1alpha1.XPodDisruptionBudgetTargetRef,
+) (expectedCount, healthy int32, err error) {
+	ctx, span := tracing.Start(ctx, "pdb.Service.GetTargetPodCounts", tracing.AttributeNamespace.String(namespace))
+	defer func() {
+		span.SetAttributes(tracing.AttributeExpectedCount.Int64(int64(expectedCount)), tracing.AttributeHealthyCount.Int64(int64(healthy)))
+		tracing.End(span, err)
+	}()
+
+	selector, expectedCount, err := s.scaleFinder.FindTarget(ctx, namespace, targetRef)
+	if apierrors.IsNotFound(err) {
+		// like a selector which doesn't match any pods.
+		s.logger.V(1).Info("xpdb target not found", "namespace", namespace, "kind", targetRef.Kind, "name", targetRef.Name)
+		return 0, 0, nil
+	}
+	if err != nil {
+		return expectedCount, healthy, err
+	}
+
+	pods, err := s.listPods(ctx, namespace, selector)
+	if err != nil {
+		return expectedCount, healthy, err
+	}
+
+	healthy = countHealthyPods(pods)
+	s.logger.V(1).Info("get-target-pod-counts", "kind", targetRef.Kind, "name", targetRef.Name,
+		"expectedCount", expectedCount, "healthy", healthy)
+	return expectedCount, healthy, nil
+}
+
+// getLocalPodCounts returns the pod counts of the xpdb in the local cluster.
+func (s *Service) getLocalPodCounts(ctx context.Context, xpdb *xpdbv1alpha1.XPodDisruptionBudget) (int32, int32, error) {
+	if xpdb.Spec.TargetRef != nil {
+		return s.GetTargetPodCounts(ctx, xpdb.Namespace, xpdb.Spec.TargetRef)
+	}
+	return s.GetPodCounts(ctx, xpdb.Namespace, &xpdb.Spec.Selector)
+}
+
+// CanPodBeDisrupted looks up both local and remote pods and calculates if a disruption would be acceptable.
 // Note: You should use .Lock()/.Unlock() before using this func to ensure no other clusters are able to
 // evict pods while we make the calculation and return the response back to the kube-apiserver.
 //
 // Note: It is still possible for pods to become unready or die unexpectedly while we do this calculation, hence
 // the PDB would be disrupted.
-func (s *Service) CanPodBeDisrupted(ctx context.Context, candidatePod *corev1.Pod, xpdb *xpdbv1alpha1.XPodDisruptionBudget) (bool, error) {
+func (s *Service) CanPodBeDisrupted(ctx context.Context, candidatePod *corev1.Pod, xpdb *xpdbv1alpha1.XPodDisruptionBudget) (eval *Evaluation, err error) {
+	ctx, span := tracing.Start(ctx, "pdb.Service.CanPodBeDisrupted",
+		tracing.AttributeNamespace.String(xpdb.Namespace),
+		tracing.AttributeXPDBName.String(xpdb.Name),
+		tracing.AttributePodName.String(candidatePod.Name))
+	defer func() {
+		if eval != nil {
+			span.SetAttributes(tracing.AttributeDisruptionAllowed.Bool(eval.Allowed))
+		}
+		tracing.End(span, err)
+	}()
+
+	var remoteCounts []ClusterCounts
 	var remoteExpectedCount, remoteHealthy int32
-	var err error
 	if len(s.remoteEndpoints) > 0 {
-		remoteExpectedCount, remoteHealthy, err = s.getRemotePodCounts(ctx, xpdb.Namespace, &xpdb.Spec.Selector)
+		remoteCounts, err = s.getRemotePodCounts(ctx, xpdb)
 		if err != nil {
 			s.logger.Error(err, "error getting remote pod counts", "namespace", xpdb.Namespace, "name", xpdb.Name)
-			return false, err
+			return nil, err
+		}
+		for _, c := range remoteCounts {
+			remoteExpectedCount += c.Expected
+			remoteHealthy += c.Healthy
 		}
 	}
 
-	localExpectedCount, localHealthy, err := s.GetPodCounts(ctx, xpdb.Namespace, &xpdb.Spec.Selector)
+	localExpectedCount, localHealthy, err := s.getLocalPodCounts(ctx, xpdb)
 	if err != nil {
 		s.logger.Error(err, "error getting local pod counts", "namespace", xpdb.Namespace, "name", xpdb.Name)
-		return false, err
+		return nil, err
 	}
 
+	observeState(xpdb, localExpectedCount, localHealthy, remoteExpectedCount, remoteHealthy)
+
 	totalHealthy := remoteHealthy + localHealthy
 	totalExpectedCount := remoteExpectedCount + localExpectedCount
 	s.logger.Info("xpdb aggregated remote state",
@@ -120,10 +219,30 @@ func (s *Service) CanPodBeDisrupted(ctx context.Context, candidatePod *corev1.Po
 		"localHealthy", localHealthy,
 		"localExpectedCount", localExpectedCount)
 
-	return s.disruptionAllowed(xpdb, candidatePod, totalExpectedCount, totalHealthy)
+	desiredHealthy, err := getDesiredHealthy(xpdb, totalExpectedCount)
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := s.disruptionAllowed(xpdb, candidatePod, totalExpectedCount, totalHealthy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Evaluation{
+		Allowed:        allowed,
+		Expected:       totalExpectedCount,
+		Healthy:        totalHealthy,
+		DesiredHealthy: desiredHealthy,
+		Clusters: append([]ClusterCounts{{
+			Cluster:  LocalCluster,
+			Expected: localExpectedCount,
+			Healthy:  localHealthy,
+		}}, remoteCounts...),
+	}, nil
 }
 
 // GetXPdbsForPod returns all XPDBs matching the particular pod.
+// XPDBs whose target doesn't exist in this cluster, or whose kind is unknown, are skipped.
 func (s *Service) GetXPdbsForPod(ctx context.Context, pod *corev1.Pod) ([]*xpdbv1alpha1.XPodDisruptionBudget, error) {
 	var items xpdbv1alpha1.XPodDisruptionBudgetList
 	err := s.reader.List(ctx, &items, client.InNamespace(pod.Namespace))
@@ -133,7 +252,18 @@ func (s *Service) GetXPdbsForPod(ctx context.Context, pod *corev1.Pod) ([]*xpdbv
 
 	xpbds := make([]*xpdbv1alpha1.XPodDisruptionBudget, 0)
 	for i := range items.Items {
-		selector, err := metav1.LabelSelectorAsSelector(&items.Items[i].Spec.Selector)
+		selector, err := s.getSelector(ctx, &items.Items[i])
+		if apierrors.IsNotFound(err) {
+			// the workload of the xpdb doesn't exist in this cluster.
+			s.logger.V(1).Info("xpdb target not found", "namespace", items.Items[i].Namespace, "name", items.Items[i].Name)
+			continue
+		}
+		if meta.IsNoMatchError(err) {
+			// the kind of the target doesn't exist in this cluster, the xpdb can't protect any pods.
+			s.logger.Error(err, "xpdb targets unknown kind, skipping it",
+				"namespace", items.Items[i].Namespace, "name", items.Items[i].Name)
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -146,14 +276,25 @@ func (s *Service) GetXPdbsForPod(ctx context.Context, pod *corev1.Pod) ([]*xpdbv
 	return xpbds, nil
 }
 
+// getSelector returns the pod selector of the xpdb, resolving its target reference if set.
+func (s *Service) getSelector(ctx context.Context, xpdb *xpdbv1alpha1.XPodDisruptionBudget) (labels.Selector, error) {
+	if xpdb.Spec.TargetRef != nil {
+		return s.scaleFinder.FindTargetSelector(ctx, xpdb.Namespace, xpdb.Spec.TargetRef)
+	}
+	return metav1.LabelSelectorAsSelector(&xpdb.Spec.Selector)
+}
+
 func (s *Service) getPodsMatchingSelector(ctx context.Context, namespace string, selector *metav1.LabelSelector) ([]*corev1.Pod, error) {
 	sel, err := metav1.LabelSelectorAsSelector(selector)
 	if err != nil {
 		return []*corev1.Pod{}, err
 	}
+	return s.listPods(ctx, namespace, sel)
+}
 
+func (s *Service) listPods(ctx context.Context, namespace string, selector labels.Selector) ([]*corev1.Pod, error) {
 	var podList corev1.PodList
-	err = s.reader.List(ctx, &podList, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: sel})
+	err := s.reader.List(ctx, &podList, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector})
 	if err != nil {
 		return []*corev1.Pod{}, err
 	}
@@ -170,24 +311,9 @@ func (s *Service) disruptionAllowed(xpdb *xpdbv1alpha1.XPodDisruptionBudget, can
 	if xpdb == nil {
 		return true, nil
 	}
-	var desiredHealthy int32
-	if xpdb.Spec.MaxUnavailable != nil {
-		maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(xpdb.Spec.MaxUnavailable, int(expectedCount), true)
-		if err != nil {
-			return false, err
-		}
-		desiredHealthy = expectedCount - int32(maxUnavailable)
-	} else if xpdb.Spec.MinAvailable != nil {
-		if xpdb.Spec.MinAvailable.Type == intstr.Int {
-			desiredHealthy = xpdb.Spec.MinAvailable.IntVal
-		} else if xpdb.Spec.MinAvailable.Type == intstr.String {
-			var minAvailable int
-			minAvailable, err := intstr.GetScaledValueFromIntOrPercent(xpdb.Spec.MinAvailable, int(expectedCount), true)
-			if err != nil {
-				return false, err
-			}
-			desiredHealthy = int32(minAvailable)
-		}
+	desiredHealthy, err := getDesiredHealthy(xpdb, expectedCount)
+	if err != nil {
+		return false, err
 	}
 
 	// In the case the pod being deleted/evicted is not ready
@@ -214,26 +340,36 @@ func (s *Service) disruptionAllowed(xpdb *xpdbv1alpha1.XPodDisruptionBudget, can
 	return allowed, nil
 }
 
-func (s *Service) getRemotePodCounts(ctx context.Context, namespace string, selector *metav1.LabelSelector) (remoteDesiredHealthy, remoteHealthy int32, err error) {
+func (s *Service) getRemotePodCounts(ctx context.Context, xpdb *xpdbv1alpha1.XPodDisruptionBudget) (counts []ClusterCounts, err error) {
 	if len(s.remoteEndpoints) == 0 {
-		return remoteDesiredHealthy, remoteHealthy, err
+		return nil, nil
 	}
 
-	req := &statepb.GetStateRequest{
-		Namespace:     namespace,
-		LabelSelector: converters.ConvertLabelSelectorToState(selector),
-	}
+	ctx, span := tracing.Start(ctx, "pdb.Service.getRemotePodCounts", tracing.AttributeNamespace.String(xpdb.Namespace))
+	defer func() { tracing.End(span, err) }()
 
-	p := pool.NewWithResults[*statepb.GetStateResponse]().
+	p := pool.NewWithResults[ClusterCounts]().
 		WithErrors().
 		WithMaxGoroutines(len(s.remoteEndpoints)).
 		WithContext(ctx)
 
 	for _, e := range s.remoteEndpoints {
-		p.Go(func(ctx context.Context) (*statepb.GetStateResponse, error) {
+		// namespace and selector are translated for each remote, a target reference
+		// is resolved by the remote cluster itself, as the workload may differ between clusters.
+		namespace, selector := s.remotes.MapXPDB(e, xpdb)
+		req := &statepb.GetStateRequest{Namespace: namespace}
+		target := selector.String()
+		if xpdb.Spec.TargetRef != nil {
+			req.TargetRef = converters.ConvertTargetRefToState(xpdb.Spec.TargetRef)
+			target = xpdb.Spec.TargetRef.Kind + "/" + xpdb.Spec.TargetRef.Name
+		} else {
+			req.LabelSelector = converters.ConvertLabelSelectorToState(selector)
+		}
+
+		p.Go(func(ctx context.Context) (ClusterCounts, error) {
 			cli, err := s.stateClientPool.Get(e)
 			if err != nil {
-				return nil, err
+				return ClusterCounts{}, err
 			}
 
 			cctx, cancel := context.WithTimeout(ctx, remoteGetStateTimeout)
@@ -242,29 +378,69 @@ func (s *Service) getRemotePodCounts(ctx context.Context, namespace string, sele
 			res, err := cli.GetState(cctx, req)
 			if err != nil {
 				s.logger.Error(err, "error obtaining remote state", "endpoint", e)
-				return nil, err
+				return ClusterCounts{}, err
 			}
 			s.logger.Info("xpdb remote count",
 				"endpoint", e,
 				"namespace", namespace,
-				"selector", selector.String(),
+				"target", target,
 				"desiredhealthy", res.DesiredHealthy,
 				"healthy", res.Healthy,
 			)
-			return res, nil
+			return ClusterCounts{Cluster: e, Expected: res.DesiredHealthy, Healthy: res.Healthy}, nil
 		})
 	}
 
-	results, err := p.Wait()
+	counts, err = p.Wait()
 	if err != nil {
-		return 0, 0, err
+		return nil, err
+	}
+	slices.SortFunc(counts, func(a, b ClusterCounts) int { return cmp.Compare(a.Cluster, b.Cluster) })
+	return counts, nil
+}
+
+// getDesiredHealthy returns the minimum number of healthy pods required by the xpdb
+// if expectedCount pods are expected.
+func getDesiredHealthy(xpdb *xpdbv1alpha1.XPodDisruptionBudget, expectedCount int32) (int32, error) {
+	var desiredHealthy int32
+	if xpdb.Spec.MaxUnavailable != nil {
+		maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(xpdb.Spec.MaxUnavailable, int(expectedCount), true)
+		if err != nil {
+			return 0, err
+		}
+		desiredHealthy = expectedCount - int32(maxUnavailable)
+	} else if xpdb.Spec.MinAvailable != nil {
+		if xpdb.Spec.MinAvailable.Type == intstr.Int {
+			desiredHealthy = xpdb.Spec.MinAvailable.IntVal
+		} else if xpdb.Spec.MinAvailable.Type == intstr.String {
+			minAvailable, err := intstr.GetScaledValueFromIntOrPercent(xpdb.Spec.MinAvailable, int(expectedCount), true)
+			if err != nil {
+				return 0, err
+			}
+			desiredHealthy = int32(minAvailable)
+		}
 	}
+	return desiredHealthy, nil
+}
 
-	for _, res := range results {
-		remoteDesiredHealthy += res.DesiredHealthy
-		remoteHealthy += res.Healthy
+// observeState records the pod counts of the xpdb for the local and remote clusters and in total.
+// The desired healthy pods of a scope are computed by applying the budget to the pods of the scope.
+func observeState(xpdb *xpdbv1alpha1.XPodDisruptionBudget, localExpected, localHealthy, remoteExpected, remoteHealthy int32) {
+	scopes := []struct {
+		scope             metrics.Scope
+		expected, healthy int32
+	}{
+		{metrics.ScopeLocal, localExpected, localHealthy},
+		{metrics.ScopeRemote, remoteExpected, remoteHealthy},
+		{metrics.ScopeTotal, localExpected + remoteExpected, localHealthy + remoteHealthy},
+	}
+	for _, sc := range scopes {
+		desiredHealthy, err := getDesiredHealthy(xpdb, sc.expected)
+		if err != nil {
+			continue
+		}
+		metrics.ObserveXPDBState(xpdb.Namespace, xpdb.Name, sc.scope, sc.expected, sc.healthy, desiredHealthy)
 	}
-	return remoteDesiredHealthy, remoteHealthy, err
 }
 
 func countHealthyPods(pods []*corev1.Pod) (currentHealthy int32) {
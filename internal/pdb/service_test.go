@@ -17,15 +17,27 @@ limitations under the License.
 package pdb
 
 import (
+	"context"
+	"slices"
 	"testing"
 
 	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	coordv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
@@ -35,6 +47,7 @@ func init() {
 	scheme = runtime.NewScheme()
 	_ = clientgoscheme.AddToScheme(scheme)
 	_ = coordv1.AddToScheme(scheme)
+	_ = xpdbv1alpha1.AddToScheme(scheme)
 }
 
 func TestService_disruptionAllowed(t *testing.T) {
@@ -289,3 +302,74 @@ func TestService_disruptionAllowed(t *testing.T) {
 		})
 	}
 }
+
+func TestService_GetXPdbsForPod(t *testing.T) {
+	newXPDB := func(name string, targetRef *xpdbv1alpha1.XPodDisruptionBudgetTargetRef) *xpdbv1alpha1.XPodDisruptionBudget {
+		xpdb := &xpdbv1alpha1.XPodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: name},
+			Spec:       xpdbv1alpha1.XPodDisruptionBudgetSpec{TargetRef: targetRef},
+		}
+		if targetRef == nil {
+			xpdb.Spec.Selector = metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}
+		}
+		return xpdb
+	}
+	objs := []client.Object{
+		newXPDB("by-selector", nil),
+		newXPDB("by-target", &xpdbv1alpha1.XPodDisruptionBudgetTargetRef{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db"}),
+		newXPDB("missing", &xpdbv1alpha1.XPodDisruptionBudgetTargetRef{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "missing"}),
+		newXPDB("unknown-kind", &xpdbv1alpha1.XPodDisruptionBudgetTargetRef{APIVersion: "db.example.com/v1", Kind: "Cluster", Name: "db"}),
+	}
+
+	var scaleGets int
+	newService := func(objs ...client.Object) *Service {
+		cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceGet: func(_ context.Context, _ client.Client, _ string, obj client.Object, sub client.Object, _ ...client.SubResourceGetOption) error {
+				scaleGets++
+				gvk := obj.GetObjectKind().GroupVersionKind()
+				switch {
+				case gvk.Kind == "Cluster":
+					return &meta.NoKindMatchError{GroupKind: gvk.GroupKind()}
+				case obj.GetName() == "forbidden":
+					return apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "statefulsets"}, obj.GetName(), nil)
+				case obj.GetName() == "timeout":
+					return apierrors.NewServerTimeout(schema.GroupResource{Group: "apps", Resource: "statefulsets"}, "get", 1)
+				case obj.GetName() == "missing":
+					return apierrors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "statefulsets"}, obj.GetName())
+				}
+				scale := sub.(*unstructured.Unstructured)
+				scale.Object["spec"] = map[string]any{"replicas": int64(3)}
+				scale.Object["status"] = map[string]any{"selector": "app=db"}
+				return nil
+			},
+		}).Build()
+		return NewService(zap.New(), cl, cl, NewScaleFinder(cl, nil), nil, "x-pdb", nil)
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "db-0", Labels: map[string]string{"app": "db"}}}
+
+	s := newService(objs...)
+	xpdbs, err := s.GetXPdbsForPod(context.Background(), pod)
+	require.NoError(t, err)
+	names := make([]string, 0, len(xpdbs))
+	for _, xpdb := range xpdbs {
+		names = append(names, xpdb.Name)
+	}
+	assert.ElementsMatch(t, []string{"by-selector", "by-target"}, names)
+	assert.Equal(t, 3, scaleGets)
+
+	t.Run("should memoize the selectors of targets", func(t *testing.T) {
+		_, err := s.GetXPdbsForPod(context.Background(), pod)
+		require.NoError(t, err)
+		// only the failed lookups are repeated.
+		assert.Equal(t, 5, scaleGets)
+	})
+
+	for _, name := range []string{"forbidden", "timeout"} {
+		t.Run("should fail if target can't be resolved: "+name, func(t *testing.T) {
+			broken := newXPDB(name, &xpdbv1alpha1.XPodDisruptionBudgetTargetRef{APIVersion: "apps/v1", Kind: "StatefulSet", Name: name})
+			s := newService(append(slices.Clone(objs), broken)...)
+			_, err := s.GetXPdbsForPod(context.Background(), pod)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package preactivities
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"time"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	"github.com/form3tech-oss/x-pdb/internal/metrics"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Controller tracks the lifecycle of the pre-activities of the pods x-pdb blocked the disruption of.
+// It records when each pre-activity was requested, marks pre-activities which didn't complete
+// within the timeout and clears the pending disruption annotation once all pre-activities completed.
+type Controller struct {
+	logger   logr.Logger
+	cli      client.Client
+	recorder record.EventRecorder
+	service  *Service
+	now      func() time.Time
+}
+
+// NewController creates a new Controller.
+func NewController(logger logr.Logger, cli client.Client, recorder record.EventRecorder, service *Service) *Controller {
+	return &Controller{
+		logger:   logger,
+		cli:      cli,
+		recorder: recorder,
+		service:  service,
+		now:      time.Now,
+	}
+}
+
+// SetupWithManager registers the controller for the pods with a pending disruption or pre-activity status.
+func (c *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pre-activities").
+		For(&corev1.Pod{}, builder.WithPredicates(predicate.NewPredicateFuncs(hasPreActivityAnnotations))).
+		Complete(c)
+}
+
+func (c *Controller) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	logger := c.logger.WithValues("pod", req.Name, "namespace", req.Namespace)
+
+	var pod corev1.Pod
+	if err := c.cli.Get(ctx, req.NamespacedName, &pod); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if !pod.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	now := c.now()
+	orig := pod.DeepCopy()
+	pending := PendingPreActivities(&pod)
+	var requeueAfter time.Duration
+	// events and metrics are recorded once the pod was patched, so retries don't record them twice.
+	var observe []func()
+
+	for _, name := range pending {
+		status, found := GetStatus(&pod, name)
+		if !found {
+			// pre-activities of pods without pending disruption are left to the workload.
+			if _, pendingDisruption := pod.Annotations[HasPendingDisruptionAnnotationName]; !pendingDisruption {
+				continue
+			}
+			status = &Status{RequestedAt: metav1.NewTime(now)}
+			SetStatus(&pod, name, status)
+			observe = append(observe, func() {
+				c.recorder.Eventf(&pod, corev1.EventTypeNormal, string(xpdbv1alpha1.XPDBEventReasonPreActivityRequested),
+					"pre-activity %s requested: %s", name, pod.Annotations[PreActivityAnnotationNamePrefix+name])
+			})
+		}
+		if status.TimedOutAt != nil {
+			continue
+		}
+
+		deadline, found := c.service.Deadline(&pod, name)
+		if !found {
+			continue
+		}
+		if now.Before(deadline) {
+			if wait := deadline.Sub(now); requeueAfter == 0 || wait < requeueAfter {
+				requeueAfter = wait
+			}
+			continue
+		}
+
+		status.TimedOutAt = ptr.To(metav1.NewTime(now))
+		SetStatus(&pod, name, status)
+		observe = append(observe, func() {
+			policy := c.service.Policy()
+			metrics.ObservePreActivityTimeout(pod.Namespace, string(policy))
+			c.recorder.Eventf(&pod, corev1.EventTypeWarning, string(xpdbv1alpha1.XPDBEventReasonPreActivityTimedOut),
+				"pre-activity %s did not complete within %s, timeout policy %s", name, now.Sub(status.RequestedAt.Time).Round(time.Second), policy)
+			logger.Info("pre-activity timed out", "preActivity", name, "policy", policy)
+		})
+	}
+
+	for key := range orig.Annotations {
+		name, isStatus := strings.CutPrefix(key, StatusAnnotationNamePrefix)
+		if !isStatus {
+			continue
+		}
+		if _, stillPending := pod.Annotations[PreActivityAnnotationNamePrefix+name]; stillPending {
+			continue
+		}
+
+		delete(pod.Annotations, key)
+		status, found := GetStatus(orig, name)
+		if !found {
+			continue
+		}
+		result := metrics.PreActivityResultCompleted
+		if status.TimedOutAt != nil {
+			result = metrics.PreActivityResultTimedOut
+		}
+		duration := now.Sub(status.RequestedAt.Time)
+		observe = append(observe, func() {
+			metrics.ObservePreActivityCompleted(pod.Namespace, result, duration)
+			c.recorder.Eventf(&pod, corev1.EventTypeNormal, string(xpdbv1alpha1.XPDBEventReasonPreActivityCompleted),
+				"pre-activity %s completed after %s", name, duration.Round(time.Second))
+			logger.Info("pre-activity completed", "preActivity", name, "duration", duration)
+		})
+	}
+
+	if len(pending) == 0 {
+		if _, found := pod.Annotations[HasPendingDisruptionAnnotationName]; found {
+			delete(pod.Annotations, HasPendingDisruptionAnnotationName)
+			observe = append(observe, func() {
+				c.recorder.Event(&pod, corev1.EventTypeNormal, string(xpdbv1alpha1.XPDBEventReasonPreActivitiesCompleted),
+					"all pre-activities completed, the pod can be disrupted")
+			})
+		}
+	}
+
+	if !maps.Equal(orig.Annotations, pod.Annotations) {
+		if err := c.cli.Patch(ctx, &pod, client.MergeFromWithOptions(orig, client.MergeFromWithOptimisticLock{})); err != nil {
+			if apierrors.IsConflict(err) {
+				return reconcile.Result{Requeue: true}, nil
+			}
+			return reconcile.Result{}, err
+		}
+	}
+	for _, o := range observe {
+		o()
+	}
+
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// hasPreActivityAnnotations returns true if x-pdb blocked the disruption of the pod because of pre-activities.
+func hasPreActivityAnnotations(obj client.Object) bool {
+	for key := range obj.GetAnnotations() {
+		if strings.HasPrefix(key, StatusAnnotationNamePrefix) || key == HasPendingDisruptionAnnotationName {
+			return true
+		}
+	}
+	return false
+}
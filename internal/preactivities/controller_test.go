@@ -0,0 +1,171 @@
+package preactivities
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestController_Reconcile(t *testing.T) {
+	requestedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	status := func(timedOut bool) string {
+		s := &Status{RequestedAt: metav1.NewTime(requestedAt)}
+		if timedOut {
+			s.TimedOutAt = &metav1.Time{Time: requestedAt.Add(10 * time.Minute)}
+		}
+		pod := &corev1.Pod{}
+		SetStatus(pod, "transfer-leadership", s)
+		return pod.Annotations[StatusAnnotationNamePrefix+"transfer-leadership"]
+	}
+
+	tests := []struct {
+		name             string
+		annotations      map[string]string
+		now              time.Time
+		wantAnnotations  map[string]string
+		wantRequeueAfter time.Duration
+		wantEvents       []string
+	}{
+		{
+			name: "should record the request time of new pre-activities",
+			annotations: map[string]string{
+				PreActivityAnnotationNamePrefix + "transfer-leadership": "move the raft leader",
+				HasPendingDisruptionAnnotationName:                      "true",
+			},
+			now: requestedAt,
+			wantAnnotations: map[string]string{
+				PreActivityAnnotationNamePrefix + "transfer-leadership": "move the raft leader",
+				StatusAnnotationNamePrefix + "transfer-leadership":      status(false),
+				HasPendingDisruptionAnnotationName:                      "true",
+			},
+			wantRequeueAfter: 10 * time.Minute,
+			wantEvents:       []string{"Normal PreActivityRequested pre-activity transfer-leadership requested: move the raft leader"},
+		},
+		{
+			name: "should requeue pending pre-activities at their deadline",
+			annotations: map[string]string{
+				PreActivityAnnotationNamePrefix + "transfer-leadership": "move the raft leader",
+				StatusAnnotationNamePrefix + "transfer-leadership":      status(false),
+			},
+			now: requestedAt.Add(4 * time.Minute),
+			wantAnnotations: map[string]string{
+				PreActivityAnnotationNamePrefix + "transfer-leadership": "move the raft leader",
+				StatusAnnotationNamePrefix + "transfer-leadership":      status(false),
+			},
+			wantRequeueAfter: 6 * time.Minute,
+		},
+		{
+			name: "should mark pre-activities which timed out",
+			annotations: map[string]string{
+				PreActivityAnnotationNamePrefix + "transfer-leadership": "move the raft leader",
+				StatusAnnotationNamePrefix + "transfer-leadership":      status(false),
+			},
+			now: requestedAt.Add(10 * time.Minute),
+			wantAnnotations: map[string]string{
+				PreActivityAnnotationNamePrefix + "transfer-leadership": "move the raft leader",
+				StatusAnnotationNamePrefix + "transfer-leadership":      status(true),
+			},
+			wantEvents: []string{"Warning PreActivityTimedOut pre-activity transfer-leadership did not complete within 10m0s, timeout policy Block"},
+		},
+		{
+			name: "should clear the pending disruption once all pre-activities completed",
+			annotations: map[string]string{
+				StatusAnnotationNamePrefix + "transfer-leadership": status(false),
+				HasPendingDisruptionAnnotationName:                 "true",
+				"other":                                            "kept",
+			},
+			now:             requestedAt.Add(time.Minute),
+			wantAnnotations: map[string]string{"other": "kept"},
+			wantEvents: []string{
+				"Normal PreActivityCompleted pre-activity transfer-leadership completed after 1m0s",
+				"Normal PreActivitiesCompleted all pre-activities completed, the pod can be disrupted",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "db-0", Annotations: tt.annotations}}
+			cl := fake.NewClientBuilder().WithObjects(pod).Build()
+			recorder := record.NewFakeRecorder(10)
+
+			service := NewService(logr.Discard(), cl, Options{Timeout: 10 * time.Minute, TimeoutPolicy: TimeoutPolicyBlock})
+			controller := NewController(logr.Discard(), cl, recorder, service)
+			controller.now = func() time.Time { return tt.now }
+
+			result, err := controller.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "db", Name: "db-0"}})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRequeueAfter, result.RequeueAfter)
+
+			var got corev1.Pod
+			require.NoError(t, cl.Get(ctx, types.NamespacedName{Namespace: "db", Name: "db-0"}, &got))
+			assert.Equal(t, tt.wantAnnotations, got.Annotations)
+
+			close(recorder.Events)
+			var events []string
+			for e := range recorder.Events {
+				events = append(events, e)
+			}
+			assert.Equal(t, tt.wantEvents, events)
+		})
+	}
+}
+
+func TestService_CanPodBeDisrupted(t *testing.T) {
+	pending := func(requestedAt time.Time) *corev1.Pod {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "db",
+			Name:        "db-0",
+			Annotations: map[string]string{PreActivityAnnotationNamePrefix + "transfer-leadership": ""},
+		}}
+		SetStatus(pod, "transfer-leadership", &Status{RequestedAt: metav1.NewTime(requestedAt)})
+		return pod
+	}
+
+	tests := []struct {
+		name    string
+		pod     *corev1.Pod
+		policy  TimeoutPolicy
+		allowed bool
+	}{
+		{
+			name:    "should block pending pre-activities",
+			pod:     pending(time.Now()),
+			policy:  TimeoutPolicyAllowAfterTimeout,
+			allowed: false,
+		},
+		{
+			name:    "should block timed out pre-activities with block policy",
+			pod:     pending(time.Now().Add(-time.Hour)),
+			policy:  TimeoutPolicyBlock,
+			allowed: false,
+		},
+		{
+			name:    "should allow timed out pre-activities with allow after timeout policy",
+			pod:     pending(time.Now().Add(-time.Hour)),
+			policy:  TimeoutPolicyAllowAfterTimeout,
+			allowed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithObjects(tt.pod).Build()
+			service := NewService(logr.Discard(), cl, Options{Timeout: 10 * time.Minute, TimeoutPolicy: tt.policy})
+
+			allowed, err := service.CanPodBeDisrupted(context.Background(), tt.pod)
+			require.NoError(t, err)
+			assert.Equal(t, tt.allowed, allowed)
+			assert.Equal(t, tt.allowed, service.Overdue(tt.pod, []string{"transfer-leadership"}))
+		})
+	}
+}
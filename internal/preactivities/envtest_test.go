@@ -0,0 +1,172 @@
+package preactivities
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// k8sClient talks to the api server started by envtest, it is nil if KUBEBUILDER_ASSETS is not set.
+var k8sClient client.Client
+
+func TestMain(m *testing.M) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		os.Exit(m.Run())
+	}
+
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "charts", "x-pdb", "crds")},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := testEnv.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to start envtest: %v\n", err)
+		os.Exit(1)
+	}
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = xpdbv1alpha1.AddToScheme(scheme)
+	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create client: %v\n", err)
+		_ = testEnv.Stop()
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	_ = testEnv.Stop()
+	os.Exit(code)
+}
+
+// newEnvtestPod creates a pod in a new namespace of the envtest api server.
+func newEnvtestPod(t *testing.T, annotations map[string]string) *corev1.Pod {
+	if k8sClient == nil {
+		t.Skip("KUBEBUILDER_ASSETS is not set, run make test to run envtest tests")
+	}
+	ctx := context.Background()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "preactivities-"}}
+	require.NoError(t, k8sClient.Create(ctx, ns))
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns.Name, Name: "db-0", Annotations: annotations},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "db", Image: "db"}}},
+	}
+	require.NoError(t, k8sClient.Create(ctx, pod))
+	return pod
+}
+
+func TestService_RequestPreActivities_Envtest(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(logr.Discard(), k8sClient, Options{})
+
+	t.Run("should annotate pod without annotations", func(t *testing.T) {
+		pod := newEnvtestPod(t, nil)
+		// the pod decoded from the admission request has no annotations.
+		decoded := pod.DeepCopy()
+		decoded.Annotations = nil
+
+		require.NoError(t, service.RequestPreActivities(ctx, decoded, map[string]string{"transfer-leadership": "move the raft leader"}))
+
+		var got corev1.Pod
+		require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(pod), &got))
+		assert.Equal(t, "move the raft leader", got.Annotations[PreActivityAnnotationNamePrefix+"transfer-leadership"])
+		assert.Equal(t, "true", got.Annotations[HasPendingDisruptionAnnotationName])
+		_, found := GetStatus(&got, "transfer-leadership")
+		assert.True(t, found)
+	})
+
+	t.Run("should not clobber concurrent updates of stale pods", func(t *testing.T) {
+		pod := newEnvtestPod(t, nil)
+		stale := pod.DeepCopy()
+
+		pod.Labels = map[string]string{"app": "db"}
+		pod.Annotations = map[string]string{"workload": "kept"}
+		require.NoError(t, k8sClient.Update(ctx, pod))
+
+		require.NoError(t, service.RequestPreActivities(ctx, stale, map[string]string{"transfer-leadership": ""}))
+
+		var got corev1.Pod
+		require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(pod), &got))
+		assert.Equal(t, "db", got.Labels["app"])
+		assert.Equal(t, "kept", got.Annotations["workload"])
+		assert.Equal(t, "true", got.Annotations[HasPendingDisruptionAnnotationName])
+	})
+}
+
+func TestService_CanPodBeDisrupted_Envtest(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(logr.Discard(), k8sClient, Options{})
+	pod := newEnvtestPod(t, map[string]string{PreActivityAnnotationNamePrefix + "transfer-leadership": ""})
+
+	// concurrent admission requests of the same pod evaluate stale copies of it.
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stale := pod.DeepCopy()
+			stale.Labels = map[string]string{"attempt": fmt.Sprint(i)}
+			allowed, err := service.CanPodBeDisrupted(ctx, stale)
+			if err == nil && allowed {
+				err = fmt.Errorf("attempt %d was allowed", i)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	var got corev1.Pod
+	require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(pod), &got))
+	assert.Equal(t, "true", got.Annotations[HasPendingDisruptionAnnotationName])
+	assert.Empty(t, got.Labels)
+}
+
+func TestController_Reconcile_Envtest(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(logr.Discard(), k8sClient, Options{Timeout: time.Hour})
+	pod := newEnvtestPod(t, map[string]string{PreActivityAnnotationNamePrefix + "transfer-leadership": ""})
+	controller := NewController(logr.Discard(), k8sClient, record.NewFakeRecorder(10), service)
+	req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(pod)}
+
+	allowed, err := service.CanPodBeDisrupted(ctx, pod.DeepCopy())
+	require.NoError(t, err)
+	require.False(t, allowed)
+
+	// the workload completes the pre-activity while the controller holds a stale copy in its cache.
+	var completed corev1.Pod
+	require.NoError(t, k8sClient.Get(ctx, req.NamespacedName, &completed))
+	delete(completed.Annotations, PreActivityAnnotationNamePrefix+"transfer-leadership")
+	completed.Annotations["workload"] = "kept"
+	require.NoError(t, k8sClient.Update(ctx, &completed))
+
+	result, err := controller.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, reconcile.Result{}, result)
+
+	var got corev1.Pod
+	require.NoError(t, k8sClient.Get(ctx, req.NamespacedName, &got))
+	assert.Equal(t, map[string]string{"workload": "kept"}, got.Annotations)
+}
@@ -2,36 +2,78 @@ package preactivities
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"time"
 
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
 	PreActivityAnnotationNamePrefix    = "pre-activity.xpdb.form3.tech/"
 	HasPendingDisruptionAnnotationName = "xpdb.form3.tech/has-pending-disruption"
+	// StatusAnnotationNamePrefix prefixes the annotations tracking the state of a pre-activity.
+	StatusAnnotationNamePrefix = "pre-activity-status.xpdb.form3.tech/"
 )
 
+// TimeoutPolicy defines how pre-activities which didn't complete within the timeout are handled.
+type TimeoutPolicy string
+
+const (
+	// TimeoutPolicyBlock keeps blocking the disruption until the pre-activity completed.
+	TimeoutPolicyBlock TimeoutPolicy = "Block"
+	// TimeoutPolicyAllowAfterTimeout stops blocking the disruption once the pre-activity timed out.
+	TimeoutPolicyAllowAfterTimeout TimeoutPolicy = "AllowAfterTimeout"
+)
+
+// Options configure the enforcement and the timeout of pre-activities.
+type Options struct {
+	// DisabledByDefault disables pre-activities for XPDBs which don't enable them explicitly.
+	DisabledByDefault bool
+	// Timeout is the time pre-activities have to complete, 0 disables the timeout.
+	Timeout time.Duration
+	// TimeoutPolicy applies to pre-activities which didn't complete within the timeout.
+	TimeoutPolicy TimeoutPolicy
+}
+
+// Status is the state of a pre-activity, stored as JSON in the annotation
+// StatusAnnotationNamePrefix + name.
+type Status struct {
+	RequestedAt metav1.Time  `json:"requestedAt"`
+	TimedOutAt  *metav1.Time `json:"timedOutAt,omitempty"`
+}
+
 type Service struct {
 	logger logr.Logger
 	cli    client.Client
+	opts   Options
 }
 
 func NewService(
 	logger logr.Logger,
 	cli client.Client,
+	opts Options,
 ) *Service {
 	return &Service{
 		logger: logger,
 		cli:    cli,
+		opts:   opts,
 	}
 }
 
 func (s *Service) CanPodBeDisrupted(ctx context.Context, pod *corev1.Pod) (bool, error) {
-	pendingPreactivities := s.getPendingPreactivities(pod)
+	pendingPreactivities := s.getBlockingPreactivities(pod, time.Now())
 	if len(pendingPreactivities) == 0 {
 		return true, nil
 	}
@@ -42,7 +84,7 @@ func (s *Service) CanPodBeDisrupted(ctx context.Context, pod *corev1.Pod) (bool,
 		"pendingActivities", pendingPreactivities,
 	).Info("pod has pending activities")
 
-	err := s.setPendingDisruptionAnnotationOnPod(ctx, pod)
+	err := s.setPendingDisruptionAnnotationOnPod(ctx, pod, nil)
 	if err != nil {
 		s.logger.WithValues(
 			"podName", pod.Name,
@@ -55,7 +97,117 @@ func (s *Service) CanPodBeDisrupted(ctx context.Context, pod *corev1.Pod) (bool,
 	return false, nil
 }
 
-func (s *Service) getPendingPreactivities(pod *corev1.Pod) []string {
+// RequestPreActivities annotates the pod with the pre-activities which have to be performed
+// before it can be disrupted and marks the pod as having a pending disruption.
+// activities maps the names of the pre-activities to their descriptions.
+// The pod can be disrupted once the workload removed the annotations of all pre-activities.
+func (s *Service) RequestPreActivities(ctx context.Context, pod *corev1.Pod, activities map[string]string) error {
+	for name := range activities {
+		if errs := validation.IsQualifiedName(PreActivityAnnotationNamePrefix + name); len(errs) > 0 {
+			return fmt.Errorf("invalid pre-activity %q: %s", name, strings.Join(errs, ", "))
+		}
+	}
+
+	s.logger.WithValues(
+		"podName", pod.Name,
+		"podNamespace", pod.Namespace,
+		"requestedActivities", activities,
+	).Info("requesting pre-activities")
+
+	return s.setPendingDisruptionAnnotationOnPod(ctx, pod, activities)
+}
+
+// Enabled returns true if pending pre-activities block disruptions of the pods of the xpdb.
+func (s *Service) Enabled(xpdb *xpdbv1alpha1.XPodDisruptionBudget) bool {
+	if pa := xpdb.Spec.PreActivities; pa != nil && pa.Enabled != nil {
+		return *pa.Enabled
+	}
+	return !s.opts.DisabledByDefault
+}
+
+// Overdue returns true if the pre-activities are pending on the pod and timed out
+// and the timeout policy allows disruptions after the timeout.
+func (s *Service) Overdue(pod *corev1.Pod, names []string) bool {
+	if s.opts.TimeoutPolicy != TimeoutPolicyAllowAfterTimeout {
+		return false
+	}
+	now := time.Now()
+	for _, name := range names {
+		if _, pending := pod.Annotations[PreActivityAnnotationNamePrefix+name]; !pending || !s.TimedOut(pod, name, now) {
+			return false
+		}
+	}
+	return true
+}
+
+// TimedOut returns true if the pre-activity was requested longer than the timeout ago.
+// Pre-activities without status, i.e. not yet seen by the controller, haven't timed out.
+func (s *Service) TimedOut(pod *corev1.Pod, name string, now time.Time) bool {
+	deadline, found := s.Deadline(pod, name)
+	return found && !now.Before(deadline)
+}
+
+// Deadline returns the time the pre-activity times out at.
+// It returns false if there is no timeout or the pre-activity has no status.
+func (s *Service) Deadline(pod *corev1.Pod, name string) (time.Time, bool) {
+	if s.opts.Timeout <= 0 {
+		return time.Time{}, false
+	}
+	status, found := GetStatus(pod, name)
+	if !found {
+		return time.Time{}, false
+	}
+	return status.RequestedAt.Add(s.opts.Timeout), true
+}
+
+// BlockingPreActivities returns the pending pre-activities of the pod which block its disruption.
+func (s *Service) BlockingPreActivities(pod *corev1.Pod) []string {
+	return s.getBlockingPreactivities(pod, time.Now())
+}
+
+// Policy returns the timeout policy.
+func (s *Service) Policy() TimeoutPolicy {
+	return s.opts.TimeoutPolicy
+}
+
+// GetStatus returns the status of a pre-activity of the pod.
+// Invalid statuses are treated as missing, so they are recreated.
+func GetStatus(pod *corev1.Pod, name string) (*Status, bool) {
+	value, found := pod.Annotations[StatusAnnotationNamePrefix+name]
+	if !found {
+		return nil, false
+	}
+	var status Status
+	if err := json.Unmarshal([]byte(value), &status); err != nil {
+		return nil, false
+	}
+	return &status, true
+}
+
+// SetStatus stores the status of a pre-activity on the pod.
+func SetStatus(pod *corev1.Pod, name string, status *Status) {
+	value, _ := json.Marshal(status)
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[StatusAnnotationNamePrefix+name] = string(value)
+}
+
+// getBlockingPreactivities returns the pending pre-activities which block disruptions,
+// i.e. all pending ones unless the timeout policy allows disruptions after the timeout.
+func (s *Service) getBlockingPreactivities(pod *corev1.Pod, now time.Time) []string {
+	var blocking []string
+	for _, name := range PendingPreActivities(pod) {
+		if s.opts.TimeoutPolicy == TimeoutPolicyAllowAfterTimeout && s.TimedOut(pod, name, now) {
+			continue
+		}
+		blocking = append(blocking, name)
+	}
+	return blocking
+}
+
+// PendingPreActivities returns the names of the pre-activities annotated on the pod.
+func PendingPreActivities(pod *corev1.Pod) []string {
 	preactivities := []string{}
 	for k := range pod.Annotations {
 		if strings.Contains(k, PreActivityAnnotationNamePrefix) {
@@ -63,14 +215,48 @@ func (s *Service) getPendingPreactivities(pod *corev1.Pod) []string {
 			preactivities = append(preactivities, preactivity)
 		}
 	}
+	slices.Sort(preactivities)
 	return preactivities
 }
 
-func (s *Service) setPendingDisruptionAnnotationOnPod(ctx context.Context, pod *corev1.Pod) error {
-	pod.Annotations[HasPendingDisruptionAnnotationName] = "true"
-	err := s.cli.Update(ctx, pod)
+// setPendingDisruptionAnnotationOnPod marks the pod as having a pending disruption, adds the requested
+// pre-activities and records the request time of the pre-activities which have no status yet.
+// The annotations are merge patched, so concurrent updates of the pod are neither clobbered nor conflict.
+func (s *Service) setPendingDisruptionAnnotationOnPod(ctx context.Context, pod *corev1.Pod, requested map[string]string) error {
+	annotations := map[string]string{}
+	if pod.Annotations[HasPendingDisruptionAnnotationName] != "true" {
+		annotations[HasPendingDisruptionAnnotationName] = "true"
+	}
+	for name, description := range requested {
+		if value, found := pod.Annotations[PreActivityAnnotationNamePrefix+name]; !found || value != description {
+			annotations[PreActivityAnnotationNamePrefix+name] = description
+		}
+	}
+	now := metav1.Now()
+	for _, name := range append(PendingPreActivities(pod), slices.Collect(maps.Keys(requested))...) {
+		if _, found := GetStatus(pod, name); !found {
+			value, _ := json.Marshal(&Status{RequestedAt: now})
+			annotations[StatusAnnotationNamePrefix+name] = string(value)
+		}
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]any{"metadata": map[string]any{"annotations": annotations}})
 	if err != nil {
 		return err
 	}
-	return nil
+	return retry.OnError(retry.DefaultBackoff, isRetriable, func() error {
+		return s.cli.Patch(ctx, pod, client.RawPatch(types.MergePatchType, patch))
+	})
+}
+
+// isRetriable returns true for transient errors of the api server.
+func isRetriable(err error) bool {
+	return apierrors.IsConflict(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err)
 }
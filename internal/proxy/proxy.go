@@ -0,0 +1,217 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/form3tech-oss/x-pdb/internal/metrics"
+	"golang.org/x/net/proxy"
+	"google.golang.org/grpc"
+)
+
+const (
+	schemeHTTP   = "http"
+	schemeSOCKS5 = "socks5"
+)
+
+// Config configures an egress proxy used to reach a remote endpoint.
+type Config struct {
+	// URL is the address of the proxy, e.g. `http://proxy:3128` for HTTP CONNECT
+	// or `socks5://proxy:1080` for SOCKS5. Credentials can be passed as user info.
+	URL string `json:"url"`
+	// PasswordFile is the path to a file holding the password of the user set in URL.
+	// +optional
+	PasswordFile string `json:"passwordFile,omitempty"`
+}
+
+// Validate verifies the proxy configuration.
+func (c *Config) Validate() error {
+	if c == nil {
+		return nil
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy url: %w", err)
+	}
+	if u.Scheme != schemeHTTP && u.Scheme != schemeSOCKS5 {
+		return fmt.Errorf("unsupported proxy scheme %q, must be %q or %q", u.Scheme, schemeHTTP, schemeSOCKS5)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("proxy url has no host")
+	}
+	if c.PasswordFile != "" && u.User == nil {
+		return fmt.Errorf("passwordFile requires a user in the proxy url")
+	}
+	return nil
+}
+
+// Dialer establishes connections through an egress proxy.
+type Dialer struct {
+	proxyURL *url.URL
+	// client names the client pool using the proxy in metrics.
+	client       string
+	passwordFile string
+}
+
+// NewDialer creates a Dialer for the supplied proxy configuration.
+func NewDialer(cfg *Config, client string) (*Dialer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Dialer{
+		proxyURL:     u,
+		client:       client,
+		passwordFile: cfg.PasswordFile,
+	}, nil
+}
+
+// DialOptions returns the grpc options to connect to target through the proxy.
+// The target is not resolved locally, as the proxy may be the only one able to resolve it.
+func (d *Dialer) DialOptions(target string) (string, []grpc.DialOption) {
+	return "passthrough:///" + target, []grpc.DialOption{grpc.WithContextDialer(d.DialContext)}
+}
+
+// DialContext connects to addr through the proxy.
+func (d *Dialer) DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	switch d.proxyURL.Scheme {
+	case schemeSOCKS5:
+		conn, err = d.dialSOCKS5(ctx, addr)
+	default:
+		conn, err = d.dialHTTPConnect(ctx, addr)
+	}
+
+	if err != nil {
+		metrics.ObserveProxyConnectFailure(d.client, d.proxyURL.Host)
+		return nil, fmt.Errorf("unable to connect to %s through proxy %s: %w", addr, d.proxyURL.Host, err)
+	}
+	return conn, nil
+}
+
+func (d *Dialer) dialSOCKS5(ctx context.Context, addr string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if d.proxyURL.User != nil {
+		password, err := d.password()
+		if err != nil {
+			return nil, err
+		}
+		auth = &proxy.Auth{User: d.proxyURL.User.Username(), Password: password}
+	}
+
+	dialer, err := proxy.SOCKS5("tcp", d.proxyURL.Host, auth, &net.Dialer{})
+	if err != nil {
+		return nil, err
+	}
+	return dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+}
+
+func (d *Dialer) dialHTTPConnect(ctx context.Context, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", d.proxyURL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if d.proxyURL.User != nil {
+		password, err := d.password()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		credentials := d.proxyURL.User.Username() + ":" + password
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+
+	// bound the handshake with the proxy by the deadline of the context.
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy responded with %s", resp.Status)
+	}
+
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: br}, nil
+	}
+	return conn, nil
+}
+
+func (d *Dialer) password() (string, error) {
+	if d.passwordFile == "" {
+		password, _ := d.proxyURL.User.Password()
+		return password, nil
+	}
+
+	// the password is read on every connection to pick up rotated credentials.
+	//nolint:gosec
+	data, err := os.ReadFile(d.passwordFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read proxy password: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// bufferedConn is a connection whose first bytes
+// have already been read by the proxy handshake.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -0,0 +1,276 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startEchoServer starts a tcp server which echoes everything it receives.
+func startEchoServer(t *testing.T) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() })
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return lis.Addr().String()
+}
+
+// testProxy is an in-process HTTP CONNECT and SOCKS5 proxy.
+type testProxy struct {
+	addr        string
+	credentials string
+	tunnels     atomic.Int32
+}
+
+func startTestProxy(t *testing.T, socks bool, user, password string) *testProxy {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() })
+
+	p := &testProxy{addr: lis.Addr().String()}
+	if user != "" {
+		p.credentials = user + ":" + password
+	}
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			if socks {
+				go p.serveSOCKS5(conn)
+			} else {
+				go p.serveConnect(conn)
+			}
+		}
+	}()
+	return p
+}
+
+func (p *testProxy) serveConnect(conn net.Conn) {
+	defer conn.Close()
+
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil || req.Method != http.MethodConnect {
+		_, _ = io.WriteString(conn, "HTTP/1.1 400 Bad Request\r\n\r\n")
+		return
+	}
+
+	if p.credentials != "" &&
+		req.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte(p.credentials)) {
+		_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+		return
+	}
+
+	upstream, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	p.tunnel(conn, upstream)
+}
+
+func (p *testProxy) serveSOCKS5(conn net.Conn) {
+	defer conn.Close()
+
+	// greeting: version, number of methods, methods
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return
+	}
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+
+	if p.credentials == "" {
+		_, _ = conn.Write([]byte{5, 0})
+	} else {
+		_, _ = conn.Write([]byte{5, 2})
+		// username/password sub-negotiation
+		b := make([]byte, 2)
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return
+		}
+		user := make([]byte, b[1])
+		_, _ = io.ReadFull(conn, user)
+		if _, err := io.ReadFull(conn, b[:1]); err != nil {
+			return
+		}
+		password := make([]byte, b[0])
+		_, _ = io.ReadFull(conn, password)
+		if string(user)+":"+string(password) != p.credentials {
+			_, _ = conn.Write([]byte{1, 1})
+			return
+		}
+		_, _ = conn.Write([]byte{1, 0})
+	}
+
+	// request: version, command, reserved, address type, address, port
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, 4)
+		_, _ = io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		l := make([]byte, 1)
+		_, _ = io.ReadFull(conn, l)
+		name := make([]byte, l[0])
+		_, _ = io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return
+	}
+
+	upstream, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	p.tunnel(conn, upstream)
+}
+
+func (p *testProxy) tunnel(conn, upstream net.Conn) {
+	defer upstream.Close()
+	p.tunnels.Add(1)
+	go func() { _, _ = io.Copy(upstream, conn) }()
+	_, _ = io.Copy(conn, upstream)
+}
+
+func assertEcho(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	_, err := conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+}
+
+func TestDialer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	echo := startEchoServer(t)
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("s3cret\n"), 0o600))
+
+	tests := []struct {
+		name         string
+		socks        bool
+		user         string
+		password     string
+		url          func(addr string) string
+		passwordFile string
+		expectErr    bool
+	}{
+		{
+			name: "http connect",
+			url:  func(addr string) string { return "http://" + addr },
+		},
+		{
+			name:     "http connect with credentials",
+			user:     "x-pdb",
+			password: "s3cret",
+			url:      func(addr string) string { return "http://x-pdb:s3cret@" + addr },
+		},
+		{
+			name:         "http connect with password file",
+			user:         "x-pdb",
+			password:     "s3cret",
+			url:          func(addr string) string { return "http://x-pdb@" + addr },
+			passwordFile: passwordFile,
+		},
+		{
+			name:      "http connect with wrong credentials",
+			user:      "x-pdb",
+			password:  "s3cret",
+			url:       func(addr string) string { return "http://x-pdb:wrong@" + addr },
+			expectErr: true,
+		},
+		{
+			name:  "socks5",
+			socks: true,
+			url:   func(addr string) string { return "socks5://" + addr },
+		},
+		{
+			name:     "socks5 with credentials",
+			socks:    true,
+			user:     "x-pdb",
+			password: "s3cret",
+			url:      func(addr string) string { return "socks5://x-pdb:s3cret@" + addr },
+		},
+		{
+			name:      "socks5 with wrong credentials",
+			socks:     true,
+			user:      "x-pdb",
+			password:  "s3cret",
+			url:       func(addr string) string { return "socks5://x-pdb:wrong@" + addr },
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := startTestProxy(t, tt.socks, tt.user, tt.password)
+
+			d, err := NewDialer(&Config{URL: tt.url(p.addr), PasswordFile: tt.passwordFile}, "test")
+			require.NoError(t, err)
+
+			conn, err := d.DialContext(ctx, echo)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assertEcho(t, conn)
+			assert.Equal(t, int32(1), p.tunnels.Load())
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, (*Config)(nil).Validate())
+	assert.NoError(t, (&Config{URL: "http://proxy:3128"}).Validate())
+	assert.NoError(t, (&Config{URL: "socks5://user@proxy:1080", PasswordFile: "/password"}).Validate())
+	assert.Error(t, (&Config{URL: "https://proxy:3128"}).Validate())
+	assert.Error(t, (&Config{URL: "http://"}).Validate())
+	assert.Error(t, (&Config{URL: "http://proxy:3128", PasswordFile: "/password"}).Validate())
+}
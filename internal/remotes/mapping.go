@@ -0,0 +1,106 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remotes
+
+import (
+	"fmt"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Mapping translates the namespaces and selectors of XPDBs
+// for a remote cluster whose workloads use different namespaces or labels.
+type Mapping struct {
+	// Namespaces maps local namespaces to the namespaces of the remote.
+	// Namespaces which are not listed are passed unchanged.
+	// +optional
+	Namespaces map[string]string `json:"namespaces,omitempty"`
+	// LabelKeys maps the label keys used by local selectors to the label keys of the remote.
+	// Label keys which are not listed are passed unchanged.
+	// +optional
+	LabelKeys map[string]string `json:"labelKeys,omitempty"`
+}
+
+func (m *Mapping) validate() error {
+	if m == nil {
+		return nil
+	}
+	for from, to := range m.Namespaces {
+		if from == "" || to == "" {
+			return fmt.Errorf("namespaces cannot be empty")
+		}
+	}
+	for from, to := range m.LabelKeys {
+		if from == "" || to == "" {
+			return fmt.Errorf("label keys cannot be empty")
+		}
+	}
+	return nil
+}
+
+// MapXPDB returns the namespace and selector of the xpdb in the remote cluster of the supplied endpoint.
+// A mapping of the xpdb for the endpoint takes precedence over the mapping of the remote.
+func (c *Config) MapXPDB(endpoint string, xpdb *xpdbv1alpha1.XPodDisruptionBudget) (string, *metav1.LabelSelector) {
+	namespace, selector := xpdb.Namespace, &xpdb.Spec.Selector
+
+	if c != nil {
+		if m := c.Get(endpoint).Mapping; m != nil {
+			if ns, ok := m.Namespaces[namespace]; ok {
+				namespace = ns
+			}
+			selector = mapLabelKeys(selector, m.LabelKeys)
+		}
+	}
+
+	for _, m := range xpdb.Spec.RemoteMappings {
+		if m.Endpoint != endpoint {
+			continue
+		}
+		if m.Namespace != "" {
+			namespace = m.Namespace
+		}
+		if m.Selector != nil {
+			selector = m.Selector
+		}
+	}
+	return namespace, selector
+}
+
+func mapLabelKeys(selector *metav1.LabelSelector, labelKeys map[string]string) *metav1.LabelSelector {
+	if len(labelKeys) == 0 {
+		return selector
+	}
+	mapKey := func(key string) string {
+		if k, ok := labelKeys[key]; ok {
+			return k
+		}
+		return key
+	}
+
+	mapped := selector.DeepCopy()
+	if selector.MatchLabels != nil {
+		mapped.MatchLabels = make(map[string]string, len(selector.MatchLabels))
+		for k, v := range selector.MatchLabels {
+			mapped.MatchLabels[mapKey(k)] = v
+		}
+	}
+	for i := range mapped.MatchExpressions {
+		mapped.MatchExpressions[i].Key = mapKey(mapped.MatchExpressions[i].Key)
+	}
+	return mapped
+}
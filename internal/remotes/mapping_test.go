@@ -0,0 +1,93 @@
+package remotes
+
+import (
+	"testing"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMapXPDB(t *testing.T) {
+	selector := metav1.LabelSelector{
+		MatchLabels: map[string]string{"app": "db"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"backend"}},
+		},
+	}
+	xpdb := &xpdbv1alpha1.XPodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "postgres"},
+		Spec: xpdbv1alpha1.XPodDisruptionBudgetSpec{
+			Selector: selector,
+			RemoteMappings: []xpdbv1alpha1.XPodDisruptionBudgetRemoteMapping{
+				{
+					Endpoint: "override.example.org:443",
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"name": "postgres-legacy"}},
+				},
+			},
+		},
+	}
+	cfg := &Config{Remotes: []Remote{
+		{Endpoint: "plain.example.org:443"},
+		{
+			Endpoint: "legacy.example.org:443",
+			Mapping: &Mapping{
+				Namespaces: map[string]string{"db": "legacy-db"},
+				LabelKeys:  map[string]string{"app": "app.kubernetes.io/name"},
+			},
+		},
+		{
+			Endpoint: "override.example.org:443",
+			Mapping:  &Mapping{Namespaces: map[string]string{"db": "legacy-db"}},
+		},
+	}}
+
+	tests := []struct {
+		name         string
+		cfg          *Config
+		endpoint     string
+		wantNs       string
+		wantSelector *metav1.LabelSelector
+	}{
+		{
+			name:         "should pass namespace and selector unchanged without config",
+			endpoint:     "plain.example.org:443",
+			wantNs:       "db",
+			wantSelector: &selector,
+		},
+		{
+			name:         "should pass namespace and selector unchanged without mapping",
+			cfg:          cfg,
+			endpoint:     "plain.example.org:443",
+			wantNs:       "db",
+			wantSelector: &selector,
+		},
+		{
+			name:     "should map namespace and label keys of remote",
+			cfg:      cfg,
+			endpoint: "legacy.example.org:443",
+			wantNs:   "legacy-db",
+			wantSelector: &metav1.LabelSelector{
+				MatchLabels:      map[string]string{"app.kubernetes.io/name": "db"},
+				MatchExpressions: selector.MatchExpressions,
+			},
+		},
+		{
+			name:         "should prefer mapping of xpdb",
+			cfg:          cfg,
+			endpoint:     "override.example.org:443",
+			wantNs:       "legacy-db",
+			wantSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"name": "postgres-legacy"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns, sel := tt.cfg.MapXPDB(tt.endpoint, xpdb)
+			assert.Equal(t, tt.wantNs, ns)
+			assert.Equal(t, tt.wantSelector, sel)
+		})
+	}
+
+	// the selector of the xpdb must not be modified.
+	assert.Equal(t, selector, xpdb.Spec.Selector)
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remotes
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/form3tech-oss/x-pdb/internal/proxy"
+	"sigs.k8s.io/yaml"
+)
+
+// Remote describes a x-pdb deployment running on a remote cluster.
+type Remote struct {
+	// Endpoint is the address of the remote x-pdb state server.
+	Endpoint string `json:"endpoint"`
+	// SPIFFEID is the SPIFFE ID the remote x-pdb presents.
+	// It is required when x-pdb obtains its certificates from the SPIFFE Workload API.
+	// +optional
+	SPIFFEID string `json:"spiffeID,omitempty"`
+	// TLS overrides the certificates used to talk to this remote.
+	// It is ignored when x-pdb obtains its certificates from the SPIFFE Workload API.
+	// +optional
+	TLS *TLS `json:"tls,omitempty"`
+	// Proxy is the egress proxy used to reach this remote.
+	// +optional
+	Proxy *proxy.Config `json:"proxy,omitempty"`
+	// Mapping translates namespaces and selectors for this remote.
+	// +optional
+	Mapping *Mapping `json:"mapping,omitempty"`
+}
+
+// TLS version names supported by TLS.MinVersion.
+const (
+	TLSVersion12 = "1.2"
+	TLSVersion13 = "1.3"
+)
+
+// TLS configures the certificates used to talk to a remote
+// whose certificates are issued by a different PKI.
+type TLS struct {
+	// CAFile is the path to the PEM encoded CA bundle used to verify the
+	// certificates presented by the remote. The state server accepts client
+	// certificates issued by any of the configured CA bundles.
+	// Defaults to `ca.crt` in --controller-certs-dir.
+	// +optional
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile is the path to the client certificate presented to the remote.
+	// Defaults to `tls.crt` in --controller-certs-dir.
+	// +optional
+	CertFile string `json:"certFile,omitempty"`
+	// KeyFile is the path to the private key of CertFile.
+	// +optional
+	KeyFile string `json:"keyFile,omitempty"`
+	// ServerName overrides the name used to verify the server certificate of the remote.
+	// Defaults to the host of the endpoint.
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+	// MinVersion is the minimum TLS version used to talk to the remote, either "1.2" or "1.3".
+	// Defaults to "1.2".
+	// +optional
+	MinVersion string `json:"minVersion,omitempty"`
+}
+
+// Config holds the configuration of all the remote clusters.
+type Config struct {
+	Remotes []Remote `json:"remotes"`
+}
+
+// NewConfigFromEndpoints creates a Config for a plain list of endpoints.
+func NewConfigFromEndpoints(endpoints []string) *Config {
+	cfg := &Config{Remotes: make([]Remote, len(endpoints))}
+	for i, e := range endpoints {
+		cfg.Remotes[i] = Remote{Endpoint: e}
+	}
+	return cfg
+}
+
+// LoadConfig reads the remotes configuration file from the supplied path.
+func LoadConfig(path string) (*Config, error) {
+	//nolint:gosec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read remotes config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse remotes config: %w", err)
+	}
+
+	return &cfg, cfg.validate()
+}
+
+func (c *Config) validate() error {
+	endpoints := map[string]struct{}{}
+	for _, r := range c.Remotes {
+		if r.Endpoint == "" {
+			return fmt.Errorf("endpoint cannot be empty")
+		}
+		if _, found := endpoints[r.Endpoint]; found {
+			return fmt.Errorf("duplicate endpoint %q", r.Endpoint)
+		}
+		endpoints[r.Endpoint] = struct{}{}
+
+		if err := r.TLS.validate(); err != nil {
+			return fmt.Errorf("invalid tls configuration of %q: %w", r.Endpoint, err)
+		}
+		if err := r.Proxy.Validate(); err != nil {
+			return fmt.Errorf("invalid proxy configuration of %q: %w", r.Endpoint, err)
+		}
+		if err := r.Mapping.validate(); err != nil {
+			return fmt.Errorf("invalid mapping of %q: %w", r.Endpoint, err)
+		}
+	}
+	return nil
+}
+
+func (t *TLS) validate() error {
+	if t == nil {
+		return nil
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("certFile and keyFile must be set together")
+	}
+	switch t.MinVersion {
+	case "", TLSVersion12, TLSVersion13:
+	default:
+		return fmt.Errorf("unsupported minVersion %q", t.MinVersion)
+	}
+	return nil
+}
+
+// Endpoints returns the endpoints of all the remotes.
+func (c *Config) Endpoints() []string {
+	if c == nil {
+		return nil
+	}
+	endpoints := make([]string, len(c.Remotes))
+	for i := range c.Remotes {
+		endpoints[i] = c.Remotes[i].Endpoint
+	}
+	return endpoints
+}
+
+// Get returns the remote configured for the supplied endpoint.
+// If the endpoint is unknown a remote without any additional settings is returned.
+func (c *Config) Get(endpoint string) Remote {
+	for _, r := range c.Remotes {
+		if r.Endpoint == endpoint {
+			return r
+		}
+	}
+	return Remote{Endpoint: endpoint}
+}
@@ -17,107 +17,115 @@ limitations under the License.
 package client
 
 import (
-	"context"
-	"crypto/tls"
-	"crypto/x509"
-	"fmt"
-	"os"
-	"path"
-	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/form3tech-oss/x-pdb/internal/metrics"
+	"github.com/form3tech-oss/x-pdb/internal/mtls"
+	"github.com/form3tech-oss/x-pdb/internal/proxy"
+	"github.com/form3tech-oss/x-pdb/internal/remotes"
+	"github.com/form3tech-oss/x-pdb/internal/tracing"
 	statepb "github.com/form3tech-oss/x-pdb/pkg/proto/state/v1"
 	"github.com/go-logr/logr"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
-	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
 )
 
+// keepaliveParams are the keepalive parameters of the connections to remote x-pdb servers.
+// Idle connections are kept open, so the first request after a quiet period
+// does not pay for the tls handshake, and broken connections are detected early.
+var keepaliveParams = keepalive.ClientParameters{
+	Time:                30 * time.Second,
+	Timeout:             10 * time.Second,
+	PermitWithoutStream: true,
+}
+
 type ClientPool struct {
-	clients  map[string]statepb.StateServiceClient
-	mux      *sync.Mutex
-	ctx      context.Context
-	logger   *logr.Logger
-	certsDir string
+	conns       map[string]*grpc.ClientConn
+	mux         *sync.Mutex
+	logger      *logr.Logger
+	tlsProvider mtls.Provider
+	remotes     *remotes.Config
 }
 
 func NewClientPool(
-	ctx context.Context,
 	logger *logr.Logger,
-	certsDir string,
+	tlsProvider mtls.Provider,
+	remotesConfig *remotes.Config,
 ) *ClientPool {
 	return &ClientPool{
-		ctx:      ctx,
-		mux:      &sync.Mutex{},
-		logger:   logger,
-		certsDir: certsDir,
-		clients:  map[string]statepb.StateServiceClient{},
+		mux:         &sync.Mutex{},
+		logger:      logger,
+		tlsProvider: tlsProvider,
+		remotes:     remotesConfig,
+		conns:       map[string]*grpc.ClientConn{},
 	}
 }
 
+// Get returns the StateService client of the supplied remote endpoint.
 func (p *ClientPool) Get(endpoint string) (statepb.StateServiceClient, error) {
+	conn, err := p.getConn(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return statepb.NewStateServiceClient(conn), nil
+}
+
+// GetHealthClient returns the grpc health client of the supplied remote endpoint.
+func (p *ClientPool) GetHealthClient(endpoint string) (healthpb.HealthClient, error) {
+	conn, err := p.getConn(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return healthpb.NewHealthClient(conn), nil
+}
+
+func (p *ClientPool) getConn(endpoint string) (*grpc.ClientConn, error) {
 	p.mux.Lock()
 	defer p.mux.Unlock()
 
-	c, found := p.clients[endpoint]
+	conn, found := p.conns[endpoint]
 	if !found {
-		c, err := p.newClient(endpoint)
+		conn, err := p.newConn(endpoint)
 		if err != nil {
 			return nil, err
 		}
 
-		p.clients[endpoint] = c
-		return c, nil
+		p.conns[endpoint] = conn
+		return conn, nil
 	}
 
-	return c, nil
+	return conn, nil
 }
 
-func (p *ClientPool) newClient(endpoint string) (statepb.StateServiceClient, error) {
-	cw, err := certwatcher.New(path.Join(p.certsDir, "tls.crt"), path.Join(p.certsDir, "tls.key"))
-	if err != nil {
-		return nil, fmt.Errorf("error creating cert watcher: %w", err)
-	}
-
-	go func() {
-		if err := cw.Start(p.ctx); err != nil {
-			p.logger.Error(err, "certificate watcher error")
-		}
-	}()
-
-	certPool := x509.NewCertPool()
-	//nolint:gosec
-	clientCABytes, err := os.ReadFile(filepath.Join(p.certsDir, "ca.crt"))
+func (p *ClientPool) newConn(endpoint string) (*grpc.ClientConn, error) {
+	remote := p.remotes.Get(endpoint)
+	tlsConfig, err := p.tlsProvider.ClientConfig(remote)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read client CA cert: %w", err)
-	}
-
-	ok := certPool.AppendCertsFromPEM(clientCABytes)
-	if !ok {
-		return nil, fmt.Errorf("failed to append client CA cert to CA pool")
+		return nil, err
 	}
 
-	conn, err := grpc.NewClient(
-		endpoint,
+	target := endpoint
+	opts := []grpc.DialOption{
 		grpc.WithChainUnaryInterceptor(
 			metrics.GrpcClientMetrics.UnaryClientInterceptor(),
 		),
-		grpc.WithTransportCredentials(
-			credentials.NewTLS(
-				&tls.Config{
-					RootCAs:    certPool,
-					MinVersion: tls.VersionTLS12,
-					GetClientCertificate: func(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
-						return cw.GetCertificate(nil)
-					},
-				},
-			),
-		),
-	)
-	if err != nil {
-		return nil, err
+		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+		grpc.WithKeepaliveParams(keepaliveParams),
+		grpc.WithStatsHandler(tracing.ClientHandler()),
 	}
 
-	return statepb.NewStateServiceClient(conn), nil
+	if remote.Proxy != nil {
+		dialer, err := proxy.NewDialer(remote.Proxy, "state")
+		if err != nil {
+			return nil, err
+		}
+		var proxyOpts []grpc.DialOption
+		target, proxyOpts = dialer.DialOptions(endpoint)
+		opts = append(opts, proxyOpts...)
+	}
+
+	return grpc.NewClient(target, opts...)
 }
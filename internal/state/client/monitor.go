@@ -0,0 +1,181 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/form3tech-oss/x-pdb/internal/metrics"
+	statepb "github.com/form3tech-oss/x-pdb/pkg/proto/state/v1"
+	"github.com/go-logr/logr"
+	"github.com/sourcegraph/conc/pool"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// PeerStatus is the last observed health of a remote x-pdb server.
+type PeerStatus struct {
+	Endpoint string `json:"endpoint"`
+	// Reachable is true if the remote answered the last health check with SERVING.
+	Reachable bool `json:"reachable"`
+	// LastCheck is the time of the last health check.
+	LastCheck time.Time `json:"lastCheck"`
+	// LastSeen is the time the remote was reachable for the last time.
+	LastSeen time.Time `json:"lastSeen,omitempty"`
+	// RoundTripTime is the duration of the last health check.
+	RoundTripTime time.Duration `json:"roundTripTime"`
+	// Error describes why the last health check failed.
+	Error string `json:"error,omitempty"`
+}
+
+// PeerMonitor periodically checks the grpc health service of all the remotes
+// and records their reachability and round-trip latency.
+type PeerMonitor struct {
+	logger    logr.Logger
+	pool      *ClientPool
+	endpoints []string
+	interval  time.Duration
+	timeout   time.Duration
+
+	mux   sync.RWMutex
+	peers map[string]PeerStatus
+}
+
+// NewPeerMonitor creates a new PeerMonitor.
+func NewPeerMonitor(logger logr.Logger, clientPool *ClientPool, endpoints []string, interval time.Duration) *PeerMonitor {
+	return &PeerMonitor{
+		logger:    logger.WithName("peer-monitor"),
+		pool:      clientPool,
+		endpoints: endpoints,
+		interval:  interval,
+		timeout:   interval / 2,
+		peers:     map[string]PeerStatus{},
+	}
+}
+
+// Start checks the remotes periodically until the context is cancelled.
+func (m *PeerMonitor) Start(ctx context.Context) error {
+	if len(m.endpoints) == 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.checkAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Peers returns the last observed status of all the remotes ordered by endpoint.
+func (m *PeerMonitor) Peers() []PeerStatus {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	peers := make([]PeerStatus, 0, len(m.endpoints))
+	for _, endpoint := range m.endpoints {
+		status, found := m.peers[endpoint]
+		if !found {
+			status = PeerStatus{Endpoint: endpoint, Error: "not checked yet"}
+		}
+		peers = append(peers, status)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Endpoint < peers[j].Endpoint })
+	return peers
+}
+
+func (m *PeerMonitor) checkAll(ctx context.Context) {
+	p := pool.New()
+	for _, endpoint := range m.endpoints {
+		p.Go(func() {
+			m.check(ctx, endpoint)
+		})
+	}
+	p.Wait()
+}
+
+func (m *PeerMonitor) check(ctx context.Context, endpoint string) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := m.checkHealth(ctx, endpoint)
+	rtt := time.Since(start)
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	previous := m.peers[endpoint]
+	status := PeerStatus{
+		Endpoint:      endpoint,
+		Reachable:     err == nil,
+		LastCheck:     start,
+		LastSeen:      previous.LastSeen,
+		RoundTripTime: rtt,
+	}
+	if err == nil {
+		status.LastSeen = start
+	} else {
+		status.Error = err.Error()
+		if previous.Reachable || previous.LastCheck.IsZero() {
+			m.logger.Info("remote is unreachable", "endpoint", endpoint, "error", err.Error())
+		}
+	}
+	m.peers[endpoint] = status
+
+	metrics.ObservePeerHealthCheck(endpoint, err == nil, rtt)
+}
+
+func (m *PeerMonitor) checkHealth(ctx context.Context, endpoint string) error {
+	client, err := m.pool.GetHealthClient(endpoint)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: statepb.StateService_ServiceDesc.ServiceName})
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("remote is %s", resp.Status)
+	}
+	return nil
+}
+
+// CheckQuorum verifies that at least quorum remotes were reachable within maxAge.
+func (m *PeerMonitor) CheckQuorum(quorum int, maxAge time.Duration) error {
+	var reachable int
+	for _, p := range m.Peers() {
+		if !p.LastSeen.IsZero() && time.Since(p.LastSeen) <= maxAge {
+			reachable++
+		}
+	}
+	if reachable < quorum {
+		return fmt.Errorf("%d of %d remotes answered a health check within %s, %d required",
+			reachable, len(m.endpoints), maxAge, quorum)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	statepb "github.com/form3tech-oss/x-pdb/pkg/proto/state/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/yaml"
+)
+
+// AllNamespaces can be used in Peer.AllowedNamespaces
+// to allow a peer to act on every namespace.
+const AllNamespaces = "*"
+
+// Peer describes a remote x-pdb deployment that is allowed
+// to call the StateService.
+type Peer struct {
+	// ClusterID is the cluster id the remote x-pdb runs with (--cluster-id).
+	// Lease holder identities sent by this peer must be prefixed with it.
+	ClusterID string `json:"clusterID"`
+	// Identities are the DNS SANs or URI SANs (e.g. SPIFFE IDs) of the client
+	// certificates presented by this peer.
+	Identities []string `json:"identities"`
+	// AllowedNamespaces are the namespaces this peer may lock, unlock
+	// and read pod counts for. Use "*" to allow all namespaces.
+	AllowedNamespaces []string `json:"allowedNamespaces"`
+}
+
+// PeersConfig is the configuration used by the Authorizer.
+type PeersConfig struct {
+	Peers []Peer `json:"peers"`
+}
+
+// LoadPeersConfig reads the peers configuration file from the supplied path.
+func LoadPeersConfig(path string) (*PeersConfig, error) {
+	//nolint:gosec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read peers config: %w", err)
+	}
+
+	var cfg PeersConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse peers config: %w", err)
+	}
+
+	return &cfg, cfg.validate()
+}
+
+// Identities returns the identities of the peers by cluster id.
+func (c *PeersConfig) Identities() map[string][]string {
+	if c == nil {
+		return nil
+	}
+	identities := make(map[string][]string, len(c.Peers))
+	for _, p := range c.Peers {
+		identities[p.ClusterID] = p.Identities
+	}
+	return identities
+}
+
+func (c *PeersConfig) validate() error {
+	clusterIDs := map[string]struct{}{}
+	identities := map[string]string{}
+	for _, p := range c.Peers {
+		if p.ClusterID == "" {
+			return fmt.Errorf("peer clusterID cannot be empty")
+		}
+		if _, found := clusterIDs[p.ClusterID]; found {
+			return fmt.Errorf("duplicate peer clusterID %q", p.ClusterID)
+		}
+		clusterIDs[p.ClusterID] = struct{}{}
+
+		if len(p.Identities) == 0 {
+			return fmt.Errorf("peer %q has no identities", p.ClusterID)
+		}
+		for _, id := range p.Identities {
+			if other, found := identities[id]; found {
+				return fmt.Errorf("identity %q is used by peers %q and %q", id, other, p.ClusterID)
+			}
+			identities[id] = p.ClusterID
+		}
+	}
+	return nil
+}
+
+// Authorizer maps the client certificate of a StateService caller
+// to a configured Peer and verifies that the peer is allowed
+// to perform the request.
+type Authorizer struct {
+	logger      logr.Logger
+	peersByName map[string]*Peer
+}
+
+// NewAuthorizer creates a new Authorizer from the supplied configuration.
+func NewAuthorizer(logger *logr.Logger, cfg *PeersConfig) *Authorizer {
+	a := &Authorizer{
+		logger:      logger.WithName("audit"),
+		peersByName: map[string]*Peer{},
+	}
+	for i := range cfg.Peers {
+		p := &cfg.Peers[i]
+		for _, id := range p.Identities {
+			a.peersByName[id] = p
+		}
+	}
+	return a
+}
+
+// UnaryServerInterceptor returns a grpc interceptor which authorizes
+// all StateService requests before they reach the handler.
+func (a *Authorizer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var namespace, leaseHolderIdentity string
+		switch r := req.(type) {
+		case *statepb.LockRequest:
+			namespace, leaseHolderIdentity = r.Namespace, r.LeaseHolderIdentity
+		case *statepb.UnlockRequest:
+			namespace, leaseHolderIdentity = r.Namespace, r.LeaseHolderIdentity
+		case *statepb.GetStateRequest:
+			namespace = r.Namespace
+		default:
+			return handler(ctx, req)
+		}
+
+		if err := a.authorize(ctx, info.FullMethod, namespace, leaseHolderIdentity); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (a *Authorizer) authorize(ctx context.Context, method, namespace, leaseHolderIdentity string) error {
+	identities, err := peerIdentities(ctx)
+	if err != nil {
+		a.deny(method, nil, identities, namespace, leaseHolderIdentity, err.Error())
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	p := a.findPeer(identities)
+	if p == nil {
+		a.deny(method, nil, identities, namespace, leaseHolderIdentity, "unknown peer")
+		return status.Error(codes.PermissionDenied, "unknown peer")
+	}
+
+	if !p.namespaceAllowed(namespace) {
+		a.deny(method, p, identities, namespace, leaseHolderIdentity, "namespace not allowed")
+		return status.Errorf(codes.PermissionDenied, "peer %q is not allowed to access namespace %q", p.ClusterID, namespace)
+	}
+
+	if leaseHolderIdentity != "" && !strings.HasPrefix(leaseHolderIdentity, p.ClusterID+"/") {
+		a.deny(method, p, identities, namespace, leaseHolderIdentity, "lease holder identity does not match peer")
+		return status.Errorf(codes.PermissionDenied, "lease holder identity does not belong to peer %q", p.ClusterID)
+	}
+
+	a.logger.V(2).Info("state service request authorized",
+		"method", method,
+		"peer", p.ClusterID,
+		"namespace", namespace,
+		"leaseHolderIdentity", leaseHolderIdentity)
+	return nil
+}
+
+func (a *Authorizer) findPeer(identities []string) *Peer {
+	for _, id := range identities {
+		if p, found := a.peersByName[id]; found {
+			return p
+		}
+	}
+	return nil
+}
+
+func (a *Authorizer) deny(method string, p *Peer, identities []string, namespace, leaseHolderIdentity, reason string) {
+	var clusterID string
+	if p != nil {
+		clusterID = p.ClusterID
+	}
+	a.logger.Info("state service request denied",
+		"method", method,
+		"peer", clusterID,
+		"identities", identities,
+		"namespace", namespace,
+		"leaseHolderIdentity", leaseHolderIdentity,
+		"reason", reason)
+}
+
+func (p *Peer) namespaceAllowed(namespace string) bool {
+	for _, ns := range p.AllowedNamespaces {
+		if ns == AllNamespaces || ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// peerIdentities returns the URI and DNS SANs of the verified client certificate.
+func peerIdentities(ctx context.Context) ([]string, error) {
+	pr, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no peer information found")
+	}
+
+	tlsInfo, ok := pr.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil, fmt.Errorf("peer did not use tls")
+	}
+
+	if len(tlsInfo.State.VerifiedChains) > 0 && len(tlsInfo.State.VerifiedChains[0]) > 0 {
+		return certificateIdentities(tlsInfo.State.VerifiedChains[0][0]), nil
+	}
+
+	// SPIFFE verifies the client certificate in VerifyPeerCertificate,
+	// hence the handshake only succeeds if the presented certificate is valid
+	// but no verified chains are recorded.
+	if tlsInfo.State.HandshakeComplete && len(tlsInfo.State.PeerCertificates) > 0 {
+		return certificateIdentities(tlsInfo.State.PeerCertificates[0]), nil
+	}
+
+	return nil, fmt.Errorf("peer did not present a verified client certificate")
+}
+
+func certificateIdentities(cert *x509.Certificate) []string {
+	identities := make([]string, 0, len(cert.URIs)+len(cert.DNSNames))
+	for _, u := range cert.URIs {
+		identities = append(identities, u.String())
+	}
+	identities = append(identities, cert.DNSNames...)
+	return identities
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	statepb "github.com/form3tech-oss/x-pdb/pkg/proto/state/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func peerContext(cert *x509.Certificate) context.Context {
+	state := tls.ConnectionState{}
+	if cert != nil {
+		state.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: state},
+	})
+}
+
+func TestAuthorizer_UnaryServerInterceptor(t *testing.T) {
+	logger := zap.New()
+	authorizer := NewAuthorizer(&logger, &PeersConfig{
+		Peers: []Peer{
+			{
+				ClusterID:         "grey",
+				Identities:        []string{"x-pdb.grey.cluster.local"},
+				AllowedNamespaces: []string{"team-a"},
+			},
+			{
+				ClusterID:         "gold",
+				Identities:        []string{"spiffe://gold.example.org/ns/x-pdb/sa/x-pdb"},
+				AllowedNamespaces: []string{AllNamespaces},
+			},
+		},
+	})
+
+	greyCert := &x509.Certificate{DNSNames: []string{"x-pdb.grey.cluster.local"}}
+	goldCert := &x509.Certificate{URIs: []*url.URL{{Scheme: "spiffe", Host: "gold.example.org", Path: "/ns/x-pdb/sa/x-pdb"}}}
+	unknownCert := &x509.Certificate{DNSNames: []string{"x-pdb.rogue.cluster.local"}}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		req      any
+		wantCode codes.Code
+	}{
+		{
+			name:     "allows lock for allowed namespace and matching identity",
+			ctx:      peerContext(greyCert),
+			req:      &statepb.LockRequest{Namespace: "team-a", LeaseHolderIdentity: "grey/pod/team-a/app-0/uuid"},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "allows get state for any namespace with wildcard",
+			ctx:      peerContext(goldCert),
+			req:      &statepb.GetStateRequest{Namespace: "team-b"},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "denies namespace not allowed",
+			ctx:      peerContext(greyCert),
+			req:      &statepb.GetStateRequest{Namespace: "team-b"},
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "denies lease holder identity of a different cluster",
+			ctx:      peerContext(greyCert),
+			req:      &statepb.UnlockRequest{Namespace: "team-a", LeaseHolderIdentity: "gold/pod/team-a/app-0/uuid"},
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "denies lease holder identity sharing only a prefix with the cluster id",
+			ctx:      peerContext(greyCert),
+			req:      &statepb.LockRequest{Namespace: "team-a", LeaseHolderIdentity: "grey-2/pod/team-a/app-0/uuid"},
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "denies unknown peer",
+			ctx:      peerContext(unknownCert),
+			req:      &statepb.GetStateRequest{Namespace: "team-a"},
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "denies peer without verified certificate",
+			ctx:      peerContext(nil),
+			req:      &statepb.GetStateRequest{Namespace: "team-a"},
+			wantCode: codes.Unauthenticated,
+		},
+	}
+
+	interceptor := authorizer.UnaryServerInterceptor()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlerCalled := false
+			handler := func(_ context.Context, _ any) (any, error) {
+				handlerCalled = true
+				return nil, nil
+			}
+
+			_, err := interceptor(tt.ctx, tt.req, &grpc.UnaryServerInfo{FullMethod: "/state.v1.StateService/Test"}, handler)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantCode == codes.OK, handlerCalled)
+		})
+	}
+}
+
+func TestLoadPeersConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name: "valid config",
+			content: `
+peers:
+  - clusterID: grey
+    identities: ["x-pdb.grey.cluster.local"]
+    allowedNamespaces: ["*"]
+`,
+		},
+		{
+			name: "duplicate identity",
+			content: `
+peers:
+  - clusterID: grey
+    identities: ["x-pdb.cluster.local"]
+  - clusterID: gold
+    identities: ["x-pdb.cluster.local"]
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown field",
+			content: `
+peers:
+  - clusterID: grey
+    identities: ["x-pdb.grey.cluster.local"]
+    namespaces: ["*"]
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "peers.yaml")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+
+			_, err := LoadPeersConfig(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -18,18 +18,17 @@ package server
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"fmt"
+	"math"
 	"net"
-	"os"
-	"path"
-	"path/filepath"
 	"runtime/debug"
+	"time"
 
 	"github.com/form3tech-oss/x-pdb/internal/converters"
 	"github.com/form3tech-oss/x-pdb/internal/lock"
+	"github.com/form3tech-oss/x-pdb/internal/mtls"
 	"github.com/form3tech-oss/x-pdb/internal/pdb"
+	"github.com/form3tech-oss/x-pdb/internal/tracing"
 	statepb "github.com/form3tech-oss/x-pdb/pkg/proto/state/v1"
 	"github.com/go-logr/logr"
 	grpcprom "github.com/grpc-ecosystem/go-grpc-middleware/providers/prometheus"
@@ -37,19 +36,39 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
-	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
+// keepaliveMinTime is the minimum interval in which clients may send keepalive pings.
+// It must not be greater than the keepalive time configured on the client pools.
+const keepaliveMinTime = 10 * time.Second
+
 type Server struct {
-	stateServer *stateServer
-	logger      *logr.Logger
-	port        int
-	certsDir    string
+	stateServer  *stateServer
+	healthServer healthpb.HealthServer
+	authorizer   *Authorizer
+	tlsProvider  mtls.Provider
+	logger       *logr.Logger
+	port         int
 }
 
-func NewServer(pdbService *pdb.Service, lockService *lock.Service, logger *logr.Logger, port int, certsDir string) *Server {
+// NewServer creates a new state server.
+// When authorizer is nil all callers presenting a valid client certificate are trusted.
+// The healthServer is registered as the standard grpc health service.
+func NewServer(
+	pdbService *pdb.Service,
+	lockService *lock.Service,
+	healthServer healthpb.HealthServer,
+	authorizer *Authorizer,
+	tlsProvider mtls.Provider,
+	logger *logr.Logger,
+	port int,
+) *Server {
 	s := &stateServer{
 		pdbService:  pdbService,
 		lockService: lockService,
@@ -57,10 +76,12 @@ func NewServer(pdbService *pdb.Service, lockService *lock.Service, logger *logr.
 	}
 
 	return &Server{
-		stateServer: s,
-		logger:      logger,
-		port:        port,
-		certsDir:    certsDir,
+		stateServer:  s,
+		healthServer: healthServer,
+		authorizer:   authorizer,
+		tlsProvider:  tlsProvider,
+		logger:       logger,
+		port:         port,
 	}
 }
 
@@ -71,27 +92,9 @@ func (s *Server) Start(ctx context.Context) error {
 		return err
 	}
 
-	cw, err := certwatcher.New(path.Join(s.certsDir, "tls.crt"), path.Join(s.certsDir, "tls.key"))
-	if err != nil {
-		return fmt.Errorf("error creating cert watcher: %w", err)
-	}
-
-	go func() {
-		if err := cw.Start(ctx); err != nil {
-			s.logger.Error(err, "certificate watcher error")
-		}
-	}()
-
-	certPool := x509.NewCertPool()
-	//nolint:gosec
-	clientCABytes, err := os.ReadFile(filepath.Join(s.certsDir, "ca.crt"))
+	tlsConfig, err := s.tlsProvider.ServerConfig()
 	if err != nil {
-		return fmt.Errorf("failed to read client CA cert: %w", err)
-	}
-
-	ok := certPool.AppendCertsFromPEM(clientCABytes)
-	if !ok {
-		return fmt.Errorf("failed to append client CA cert to CA pool")
+		return fmt.Errorf("error creating tls config: %w", err)
 	}
 
 	srvMetrics := grpcprom.NewServerMetrics(
@@ -106,23 +109,28 @@ func (s *Server) Start(ctx context.Context) error {
 		return status.Errorf(codes.Internal, "%s", p)
 	}
 
+	interceptors := []grpc.UnaryServerInterceptor{
+		srvMetrics.UnaryServerInterceptor(),
+		recovery.UnaryServerInterceptor(recovery.WithRecoveryHandler(grpcPanicRecoveryHandler)),
+	}
+	if s.authorizer != nil {
+		interceptors = append(interceptors, s.authorizer.UnaryServerInterceptor())
+	} else {
+		s.logger.Info("peer authorization is disabled, any client with a valid certificate is trusted")
+	}
+
 	grpcServer := grpc.NewServer(
-		grpc.ChainUnaryInterceptor(
-			srvMetrics.UnaryServerInterceptor(),
-			recovery.UnaryServerInterceptor(recovery.WithRecoveryHandler(grpcPanicRecoveryHandler)),
-		),
-		grpc.Creds(
-			credentials.NewTLS(
-				&tls.Config{
-					MinVersion:     tls.VersionTLS13,
-					ClientAuth:     tls.RequireAndVerifyClientCert,
-					ClientCAs:      certPool,
-					GetCertificate: cw.GetCertificate,
-				},
-			),
-		),
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.Creds(credentials.NewTLS(tlsConfig)),
+		grpc.StatsHandler(tracing.ServerHandler()),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             keepaliveMinTime,
+			PermitWithoutStream: true,
+		}),
 	)
 	statepb.RegisterStateServiceServer(grpcServer, s.stateServer)
+	healthpb.RegisterHealthServer(grpcServer, s.healthServer)
+	reflection.Register(grpcServer)
 
 	errCh := make(chan error)
 	go func() {
@@ -149,25 +157,45 @@ type stateServer struct {
 }
 
 func (s *stateServer) Lock(ctx context.Context, req *statepb.LockRequest) (*statepb.LockResponse, error) {
-	labelSelector := converters.ConvertLabelSelectorToMetaV1(req.LabelSelector)
+	var err error
+	var labelSelector *metav1.LabelSelector
+	if req.LabelSelector != nil {
+		labelSelector = converters.ConvertLabelSelectorToMetaV1(req.LabelSelector)
+	}
+	if req.XpdbName != "" {
+		err = s.lockService.LocalLock(ctx, req.LeaseHolderIdentity, req.Namespace, req.XpdbName, labelSelector)
+	} else {
+		err = s.lockService.LocalLockSelector(ctx, req.LeaseHolderIdentity, req.Namespace, labelSelector)
+	}
 
 	resp := &statepb.LockResponse{}
-	err := s.lockService.LocalLock(ctx, req.LeaseHolderIdentity, req.Namespace, labelSelector)
 	if err == nil {
 		resp.Acquired = true
 	} else {
 		s.logger.Error(err, "unable to lock xpdb")
 		resp.Error = err.Error()
+		if retryAfter := lock.RetryAfter(err); retryAfter > 0 {
+			// round up, the lease must have expired once the caller retries.
+			resp.RetryAfterSeconds = int32(math.Ceil(retryAfter.Seconds()))
+		}
 	}
 
 	return resp, nil
 }
 
 func (s *stateServer) Unlock(ctx context.Context, req *statepb.UnlockRequest) (*statepb.UnlockResponse, error) {
-	labelSelector := converters.ConvertLabelSelectorToMetaV1(req.LabelSelector)
+	var err error
+	var labelSelector *metav1.LabelSelector
+	if req.LabelSelector != nil {
+		labelSelector = converters.ConvertLabelSelectorToMetaV1(req.LabelSelector)
+	}
+	if req.XpdbName != "" {
+		err = s.lockService.LocalUnlock(context.Background(), req.LeaseHolderIdentity, req.Namespace, req.XpdbName, labelSelector)
+	} else {
+		err = s.lockService.LocalUnlockSelector(context.Background(), req.LeaseHolderIdentity, req.Namespace, labelSelector)
+	}
 
 	resp := &statepb.UnlockResponse{}
-	err := s.lockService.LocalUnlock(context.Background(), req.LeaseHolderIdentity, req.Namespace, labelSelector)
 	if err == nil {
 		resp.Unlocked = true
 	} else {
@@ -179,9 +207,15 @@ func (s *stateServer) Unlock(ctx context.Context, req *statepb.UnlockRequest) (*
 }
 
 func (s *stateServer) GetState(ctx context.Context, req *statepb.GetStateRequest) (*statepb.GetStateResponse, error) {
-	labelSelector := converters.ConvertLabelSelectorToMetaV1(req.LabelSelector)
-
-	desiredHealthy, healthy, err := s.pdbService.GetPodCounts(context.Background(), req.Namespace, labelSelector)
+	var desiredHealthy, healthy int32
+	var err error
+	if req.TargetRef != nil {
+		targetRef := converters.ConvertTargetRefToV1alpha1(req.TargetRef)
+		desiredHealthy, healthy, err = s.pdbService.GetTargetPodCounts(context.Background(), req.Namespace, targetRef)
+	} else {
+		labelSelector := converters.ConvertLabelSelectorToMetaV1(req.LabelSelector)
+		desiredHealthy, healthy, err = s.pdbService.GetPodCounts(context.Background(), req.Namespace, labelSelector)
+	}
 	if err != nil {
 		s.logger.Error(err, "unable to get pod counts")
 		return nil, status.Errorf(codes.Internal, "unable to get pod counts")
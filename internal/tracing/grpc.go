@@ -0,0 +1,38 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	"google.golang.org/grpc/stats"
+)
+
+// health checks run periodically against every peer and would drown the admission traces.
+var grpcFilter = otelgrpc.WithFilter(filters.Not(filters.HealthCheck()))
+
+// ClientHandler returns the grpc stats handler creating client spans
+// and injecting the trace context into the outgoing requests.
+func ClientHandler() stats.Handler {
+	return otelgrpc.NewClientHandler(grpcFilter)
+}
+
+// ServerHandler returns the grpc stats handler extracting the trace context
+// of incoming requests and creating server spans.
+func ServerHandler() stats.Handler {
+	return otelgrpc.NewServerHandler(grpcFilter)
+}
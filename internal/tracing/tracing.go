@@ -0,0 +1,131 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/form3tech-oss/x-pdb"
+	serviceName         = "x-pdb"
+)
+
+// Span attributes shared by the instrumented services.
+const (
+	AttributeClusterID         = attribute.Key("xpdb.cluster_id")
+	AttributeAdmissionUID      = attribute.Key("xpdb.admission.uid")
+	AttributeOperation         = attribute.Key("xpdb.admission.operation")
+	AttributeAllowed           = attribute.Key("xpdb.admission.allowed")
+	AttributeStatusCode        = attribute.Key("xpdb.admission.status_code")
+	AttributeReason            = attribute.Key("xpdb.admission.reason")
+	AttributePodName           = attribute.Key("k8s.pod.name")
+	AttributeNamespace         = attribute.Key("k8s.namespace.name")
+	AttributeXPDBName          = attribute.Key("xpdb.name")
+	AttributeLeaseHolder       = attribute.Key("xpdb.lease_holder_identity")
+	AttributeEndpoint          = attribute.Key("xpdb.endpoint")
+	AttributeExpectedCount     = attribute.Key("xpdb.expected_count")
+	AttributeHealthyCount      = attribute.Key("xpdb.healthy_count")
+	AttributeDisruptionAllowed = attribute.Key("xpdb.disruption_allowed")
+	AttributeProbeCached       = attribute.Key("xpdb.probe.cached")
+)
+
+// Options configures the export of traces.
+type Options struct {
+	// Endpoint is the host:port of the OTLP gRPC collector.
+	// Traces are not exported if it is empty, but the trace context is still propagated.
+	Endpoint string
+	// Insecure disables TLS towards the collector.
+	Insecure bool
+	// SampleRatio is the ratio of root spans which are sampled.
+	SampleRatio float64
+	// ClusterID is recorded as resource attribute of every span.
+	ClusterID string
+}
+
+// Setup configures the global trace context propagator and, if an endpoint is configured,
+// the global tracer provider exporting spans with OTLP.
+// The returned func flushes and stops the exporter.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporterOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		exporterOpts = append(exporterOpts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create otlp exporter: %w", err)
+	}
+
+	tp, err := newTracerProvider(exporter, opts)
+	if err != nil {
+		return nil, err
+	}
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+func newTracerProvider(exporter sdktrace.SpanExporter, opts Options) (*sdktrace.TracerProvider, error) {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		AttributeClusterID.String(opts.ClusterID),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create trace resource: %w", err)
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// keep the sampling decision of the caller, so traces spanning clusters are complete.
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	), nil
+}
+
+// Start creates a span as child of the span in ctx using the x-pdb tracer.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on the span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	statepb "github.com/form3tech-oss/x-pdb/pkg/proto/state/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func setupTestTracing(t *testing.T) *tracetest.InMemoryExporter {
+	_, err := Setup(context.Background(), Options{})
+	require.NoError(t, err)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp, err := newTracerProvider(exporter, Options{SampleRatio: 1, ClusterID: "blue"})
+	require.NoError(t, err)
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = tp.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	require.Failf(t, "span not found", "span %q not found in %v", name, spans)
+	return tracetest.SpanStub{}
+}
+
+func TestSpans(t *testing.T) {
+	exporter := setupTestTracing(t)
+	tp := otel.GetTracerProvider().(interface{ ForceFlush(context.Context) error })
+
+	ctx, root := Start(context.Background(), "root", AttributeAdmissionUID.String("uid-1"))
+	_, child := Start(ctx, "child")
+	End(child, assert.AnError)
+	End(root, nil)
+	require.NoError(t, tp.ForceFlush(context.Background()))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	rootSpan := findSpan(t, spans, "root")
+	childSpan := findSpan(t, spans, "child")
+
+	assert.Equal(t, rootSpan.SpanContext.TraceID(), childSpan.SpanContext.TraceID())
+	assert.Equal(t, rootSpan.SpanContext.SpanID(), childSpan.Parent.SpanID())
+	assert.Contains(t, rootSpan.Attributes, AttributeAdmissionUID.String("uid-1"))
+	assert.Contains(t, rootSpan.Resource.Attributes(), AttributeClusterID.String("blue"))
+	assert.Equal(t, "Error", childSpan.Status.Code.String())
+	assert.Len(t, childSpan.Events, 1, "error should be recorded")
+}
+
+func TestGRPCPropagation(t *testing.T) {
+	exporter := setupTestTracing(t)
+	tp := otel.GetTracerProvider().(interface{ ForceFlush(context.Context) error })
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer(grpc.StatsHandler(ServerHandler()))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	statepb.RegisterStateServiceServer(srv, statepb.UnimplementedStateServiceServer{})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(ClientHandler()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	ctx, root := Start(context.Background(), "webhook")
+	// health checks are not traced.
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = statepb.NewStateServiceClient(conn).GetState(ctx, &statepb.GetStateRequest{})
+	require.Error(t, err)
+	root.End()
+
+	// the server span may end after the client received the response.
+	require.Eventually(t, func() bool {
+		_ = tp.ForceFlush(context.Background())
+		return len(exporter.GetSpans()) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	spans := exporter.GetSpans()
+	rootSpan := findSpan(t, spans, "webhook")
+	method := statepb.StateService_GetState_FullMethodName[1:]
+	var clientSpan, serverSpan tracetest.SpanStub
+	for _, s := range spans {
+		if s.Name != method {
+			continue
+		}
+		if s.SpanKind == trace.SpanKindClient {
+			clientSpan = s
+		} else {
+			serverSpan = s
+		}
+	}
+
+	assert.Equal(t, rootSpan.SpanContext.SpanID(), clientSpan.Parent.SpanID())
+	assert.Equal(t, rootSpan.SpanContext.TraceID(), serverSpan.SpanContext.TraceID())
+	assert.Equal(t, clientSpan.SpanContext.SpanID(), serverSpan.Parent.SpanID())
+	assert.True(t, serverSpan.Parent.IsRemote())
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2024 Form3.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/form3tech-oss/x-pdb/internal/audit"
+	"github.com/form3tech-oss/x-pdb/internal/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Keys of the audit annotations added to the admission responses.
+// The kube-apiserver prefixes them with the name of the webhook.
+const (
+	AuditAnnotationXPDB              = "xpdb"
+	AuditAnnotationExpected          = "expected"
+	AuditAnnotationHealthy           = "healthy"
+	AuditAnnotationDesiredHealthy    = "desired-healthy"
+	AuditAnnotationClusters          = "clusters"
+	AuditAnnotationBlockedBy         = "blocked-by"
+	AuditAnnotationRetryAfterSeconds = "retry-after-seconds"
+)
+
+// explain adds the details of the decision to the warnings and audit annotations of the response,
+// so they are shown to the client, e.g. by `kubectl drain`, and end up in the kubernetes audit log.
+func explain(resp *admission.Response, rec *audit.Record, reason metrics.DecisionReason, retryAfter time.Duration) {
+	if rec.XPDB == nil {
+		return
+	}
+	xpdbName := rec.XPDB.Namespace + "/" + rec.XPDB.Name
+
+	annotations := map[string]string{
+		AuditAnnotationXPDB: xpdbName,
+	}
+	var warnings []string
+
+	if !resp.Allowed {
+		annotations[AuditAnnotationBlockedBy] = string(reason)
+		warnings = append(warnings, fmt.Sprintf("disruption blocked by %s of xpdb %s", reason, xpdbName))
+	}
+
+	if b := rec.Budget; b != nil {
+		clusters := make([]string, 0, len(b.Clusters))
+		for _, c := range b.Clusters {
+			clusters = append(clusters, fmt.Sprintf("%s=%d/%d", c.Cluster, c.Healthy, c.Expected))
+		}
+		annotations[AuditAnnotationExpected] = strconv.Itoa(int(b.Expected))
+		annotations[AuditAnnotationHealthy] = strconv.Itoa(int(b.Healthy))
+		annotations[AuditAnnotationDesiredHealthy] = strconv.Itoa(int(b.DesiredHealthy))
+		annotations[AuditAnnotationClusters] = strings.Join(clusters, ",")
+
+		if !resp.Allowed {
+			warnings = append(warnings,
+				fmt.Sprintf("%d/%d pods healthy across all clusters, %d must stay healthy", b.Healthy, b.Expected, b.DesiredHealthy))
+			for _, c := range clusters {
+				warnings = append(warnings, "healthy/expected pods in cluster "+c)
+			}
+		}
+	}
+
+	if !resp.Allowed && retryAfter > 0 {
+		seconds := int(retryAfter.Round(time.Second).Seconds())
+		annotations[AuditAnnotationRetryAfterSeconds] = strconv.Itoa(seconds)
+		warnings = append(warnings, fmt.Sprintf("retry in %ds", seconds))
+	}
+
+	resp.AuditAnnotations = annotations
+	resp.Warnings = append(resp.Warnings, warnings...)
+}
@@ -0,0 +1,66 @@
+package webhooks
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/x-pdb/internal/audit"
+	"github.com/form3tech-oss/x-pdb/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestExplain(t *testing.T) {
+	rec := &audit.Record{
+		XPDB: &audit.ObjectReference{Namespace: "kube-system", Name: "kube-dns"},
+		Budget: &audit.Budget{
+			Expected:       6,
+			Healthy:        5,
+			DesiredHealthy: 5,
+			Clusters: []audit.ClusterCounts{
+				{Cluster: "local", Expected: 2, Healthy: 1},
+				{Cluster: "grey:443", Expected: 4, Healthy: 4},
+			},
+		},
+	}
+
+	t.Run("denied", func(t *testing.T) {
+		resp := admission.Errored(http.StatusTooManyRequests, assert.AnError)
+		explain(&resp, rec, metrics.DecisionReasonBudget, 10*time.Second)
+
+		assert.Equal(t, map[string]string{
+			AuditAnnotationXPDB:              "kube-system/kube-dns",
+			AuditAnnotationBlockedBy:         "budget",
+			AuditAnnotationExpected:          "6",
+			AuditAnnotationHealthy:           "5",
+			AuditAnnotationDesiredHealthy:    "5",
+			AuditAnnotationClusters:          "local=1/2,grey:443=4/4",
+			AuditAnnotationRetryAfterSeconds: "10",
+		}, resp.AuditAnnotations)
+		assert.Equal(t, []string{
+			"disruption blocked by budget of xpdb kube-system/kube-dns",
+			"5/6 pods healthy across all clusters, 5 must stay healthy",
+			"healthy/expected pods in cluster local=1/2",
+			"healthy/expected pods in cluster grey:443=4/4",
+			"retry in 10s",
+		}, resp.Warnings)
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		resp := admission.Allowed("")
+		explain(&resp, rec, metrics.DecisionReasonAllowed, 0)
+
+		assert.Equal(t, "kube-system/kube-dns", resp.AuditAnnotations[AuditAnnotationXPDB])
+		assert.NotContains(t, resp.AuditAnnotations, AuditAnnotationBlockedBy)
+		assert.Empty(t, resp.Warnings)
+	})
+
+	t.Run("no xpdb", func(t *testing.T) {
+		resp := admission.Allowed("")
+		explain(&resp, &audit.Record{}, metrics.DecisionReasonIgnored, 0)
+
+		assert.Empty(t, resp.AuditAnnotations)
+		assert.Empty(t, resp.Warnings)
+	})
+}
@@ -17,22 +17,33 @@ limitations under the License.
 package webhooks
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/form3tech-oss/x-pdb/api/v1alpha1"
 	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	"github.com/form3tech-oss/x-pdb/internal/audit"
 	"github.com/form3tech-oss/x-pdb/internal/disruptionprobe"
+	"github.com/form3tech-oss/x-pdb/internal/disruptionrequests"
 	"github.com/form3tech-oss/x-pdb/internal/lock"
 	"github.com/form3tech-oss/x-pdb/internal/metrics"
 	"github.com/form3tech-oss/x-pdb/internal/pdb"
 	"github.com/form3tech-oss/x-pdb/internal/preactivities"
+	"github.com/form3tech-oss/x-pdb/internal/tracing"
 
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/ptr"
@@ -54,6 +65,23 @@ const (
 	XPDBDisruptionBudgetErrorMessage             = "Cannot disrupt pod as there was an error evaluating pod's xpdb disruption budget"
 	XPDBDisruptionProbeNotAllowedMessage         = "Cannot disrupt pod as the pod's xpdb disruption probe didn't allow it."
 	XPDBDisruptionProbeErrorMessage              = "Cannot disrupt pod as there was an error calling pod's xpdb disruption probe"
+	XPDBLockedMessage                            = "Cannot disrupt pod as another disruption of the pod's xpdb is in progress."
+	PreActivitiesRequestedMessage                = "Cannot disrupt pod as the pod's xpdb disruption probe requested pre-activities."
+)
+
+// probeLeaseMargin is the part of the lease, see lock.LeaseDurationSeconds, which is left once the disruption
+// probes completed, to respond to the kube-apiserver and to let it perform the disruption before the lease expires.
+// The probe specs are validated against the remaining 3s.
+var probeLeaseMargin = 2 * time.Second
+
+// MultipleXPDBPolicy defines how disruptions of pods matching multiple XPDBs are handled.
+type MultipleXPDBPolicy string
+
+const (
+	// MultipleXPDBPolicyReject rejects the disruption with HTTP 500, like kubernetes does for PDBs.
+	MultipleXPDBPolicyReject MultipleXPDBPolicy = "Reject"
+	// MultipleXPDBPolicyAllMustAllow allows the disruption if all matching XPDBs allow it.
+	MultipleXPDBPolicyAllMustAllow MultipleXPDBPolicy = "AllMustAllow"
 )
 
 // PodValidationWebhook implements a admission webhook server that is used
@@ -67,12 +95,18 @@ type PodValidationWebhook struct {
 	lockService            *lock.Service
 	disruptionProbeService *disruptionprobe.Service
 	preactivitiesService   *preactivities.Service
+	disruptionRequests     *disruptionrequests.Service
+	auditor                *audit.Auditor
+	multipleXPDBPolicy     MultipleXPDBPolicy
+	cooldown               time.Duration
 	clusterID              string
 	podID                  string
 	dryRun                 bool
 }
 
 // NewPodValidationWebhook creates a new Pod validation webhook instance.
+// The cooldown is the time after which a disruption blocked by the budget, pending pre-activities
+// or a probe without a suggested delay should be attempted again, it gives replaced pods the chance to become ready.
 func NewPodValidationWebhook(
 	client client.Client,
 	logger logr.Logger,
@@ -85,6 +119,10 @@ func NewPodValidationWebhook(
 	lockService *lock.Service,
 	disruptionProbeService *disruptionprobe.Service,
 	preactivitiesService *preactivities.Service,
+	disruptionRequests *disruptionrequests.Service,
+	auditor *audit.Auditor,
+	multipleXPDBPolicy MultipleXPDBPolicy,
+	cooldown time.Duration,
 ) *PodValidationWebhook {
 	return &PodValidationWebhook{
 		client:                 client,
@@ -95,6 +133,10 @@ func NewPodValidationWebhook(
 		lockService:            lockService,
 		disruptionProbeService: disruptionProbeService,
 		preactivitiesService:   preactivitiesService,
+		disruptionRequests:     disruptionRequests,
+		auditor:                auditor,
+		multipleXPDBPolicy:     multipleXPDBPolicy,
+		cooldown:               cooldown,
 		clusterID:              clusterID,
 		podID:                  podID,
 		dryRun:                 dryRun,
@@ -105,14 +147,72 @@ func NewPodValidationWebhook(
 // If not, it responds with a HTTP status code 429, similar how evictions are handled
 // if a PDB is blocking the eviction.
 func (h PodValidationWebhook) Handle(ctx context.Context, request admission.Request) admission.Response {
+	ctx, span := tracing.Start(ctx, "webhooks.PodValidationWebhook.Handle",
+		tracing.AttributeAdmissionUID.String(string(request.UID)),
+		tracing.AttributeOperation.String(string(request.Operation)),
+		tracing.AttributeNamespace.String(request.Namespace),
+		tracing.AttributePodName.String(request.Name))
+	defer span.End()
+
+	start := time.Now()
+	rec := &audit.Record{
+		Time:        start,
+		UID:         string(request.UID),
+		ClusterID:   h.clusterID,
+		Operation:   string(request.Operation),
+		SubResource: request.SubResource,
+		DryRun:      ptr.Deref(request.DryRun, false),
+		User:        request.UserInfo,
+		Pod:         audit.ObjectReference{Namespace: request.Namespace, Name: request.Name},
+	}
+	resp, reason := h.handle(ctx, request, rec)
+	latency := time.Since(start)
+	metrics.ObserveAdmissionDuration(metrics.AdmissionPhaseTotal, latency)
+
+	verdict := metrics.VerdictDenied
+	if resp.Allowed {
+		verdict = metrics.VerdictAllowed
+	}
+	metrics.ObserveAdmissionDecision(request.Namespace, verdict, reason)
+
+	retryAfter := getRetryAfter(resp)
+	explain(&resp, rec, reason, retryAfter)
+
+	rec.Verdict = string(verdict)
+	rec.Reason = string(reason)
+	rec.LatencySeconds = latency.Seconds()
+	rec.RetryAfterSeconds = int(retryAfter.Round(time.Second).Seconds())
+	if resp.Result != nil {
+		rec.Message = resp.Result.Message
+		rec.StatusCode = resp.Result.Code
+	}
+	h.auditor.Record(rec)
+
+	span.SetAttributes(tracing.AttributeAllowed.Bool(resp.Allowed), tracing.AttributeReason.String(string(reason)))
+	if resp.Result != nil {
+		span.SetAttributes(tracing.AttributeStatusCode.Int64(int64(resp.Result.Code)))
+		if resp.Result.Code >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, resp.Result.Message)
+		}
+	}
+	return resp
+}
+
+// handle evaluates the admission request and returns the response along with the reason of the verdict.
+// The details of the evaluation are added to the audit record.
+func (h PodValidationWebhook) handle(
+	ctx context.Context,
+	request admission.Request,
+	rec *audit.Record,
+) (admission.Response, metrics.DecisionReason) {
 	pod, err := h.decodePod(ctx, request)
 	if err != nil {
-		return h.admissionResponse(true, "", nil)
+		return h.admissionResponse(true, "", nil), metrics.DecisionReasonError
 	}
 
 	// If pod was already deleted lets ignore this validation request
 	if pod.ObjectMeta.DeletionTimestamp != nil {
-		return h.admissionResponse(true, "", nil)
+		return h.admissionResponse(true, "", nil), metrics.DecisionReasonIgnored
 	}
 
 	logger := h.logger.WithValues("pod", pod.Name, "namespace", pod.Namespace)
@@ -141,7 +241,7 @@ func (h PodValidationWebhook) Handle(ctx context.Context, request admission.Requ
 			"reason", cond.Reason,
 			"message", cond.Message,
 		)
-		return h.admissionResponse(true, "", nil)
+		return h.admissionResponse(true, "", nil), metrics.DecisionReasonIgnored
 	}
 
 	// relevant for pre-1.29 behaviour:
@@ -149,25 +249,18 @@ func (h PodValidationWebhook) Handle(ctx context.Context, request admission.Requ
 	// post-1.29 eviction is triggered via taint and deleted via tainteviction controller
 	if podHasNodeLostReason(pod) {
 		logger.Info("ignoring pod: has status.reason=NodeLost")
-		return h.admissionResponse(true, "", nil)
-	}
-
-	// Handle preactivities feature
-	canPodBeDisrupted, err := h.preactivitiesService.CanPodBeDisrupted(ctx, pod)
-	if err != nil {
-		return h.handleError(ctx, logger, nil, "", err, fmt.Sprintf("error verifying if pod had pending pre-activities: %s", err.Error()))
-	}
-	if !canPodBeDisrupted {
-		return h.handleNotAllowedDisruption(ctx, logger, request, nil, pod, "", PendingActivitiesDisruptionNotAllowedMessage)
+		return h.admissionResponse(true, "", nil), metrics.DecisionReasonIgnored
 	}
 
 	// Handle Multi-cluster pdb feature
+	phaseStart := time.Now()
 	xpdbs, err := h.pdbService.GetXPdbsForPod(ctx, pod)
+	metrics.ObserveAdmissionDuration(metrics.AdmissionPhaseLookup, time.Since(phaseStart))
 	if err != nil {
-		return h.admissionResponse(false, fmt.Sprintf("could not get xpdbs for pod: %s", err.Error()), nil)
+		return h.admissionResponse(false, fmt.Sprintf("could not get xpdbs for pod: %s", err.Error()), nil), metrics.DecisionReasonError
 	}
 	if len(xpdbs) == 0 {
-		return h.admissionResponse(true, "", nil)
+		return h.admissionResponse(true, "", nil), metrics.DecisionReasonIgnored
 	}
 
 	if len(xpdbs) > 1 {
@@ -175,72 +268,267 @@ func (h PodValidationWebhook) Handle(ctx context.Context, request admission.Requ
 		for _, xpdb := range xpdbs {
 			xpdbNames = append(xpdbNames, xpdb.Name)
 		}
-		h.recorder.Eventf(
-			pod,
-			corev1.EventTypeWarning,
-			string(xpdbv1alpha1.XPDBEventReasonInvalidConfiguration),
-			"invalid configuration: pod matches multiple XPDBs: %s",
-			strings.Join(xpdbNames, ", "),
-		)
 		metrics.ObservePodMatchingMultipleXPDBs(pod.Namespace)
-		logger.Error(nil, "pod matches multiple xpdbs")
 
-		// When a pod matches multiple PDBs then this is a invalid configuration.
-		// We want to match the same API behavior as kubernetes, that is to return a
-		// HTTP 500 if that is the case.
-		// see upstream Kubernetes docs:
-		// https://kubernetes.io/docs/concepts/scheduling-eviction/api-eviction/#how-api-initiated-eviction-works
-		return h.admissionResponse(false,
-			"Cannot disrupt pod as it matched multiple xpdbs.",
-			ptr.To(int32(http.StatusInternalServerError)))
-	}
+		if h.multipleXPDBPolicy != MultipleXPDBPolicyAllMustAllow {
+			h.recorder.Eventf(
+				pod,
+				corev1.EventTypeWarning,
+				string(xpdbv1alpha1.XPDBEventReasonInvalidConfiguration),
+				"invalid configuration: pod matches multiple XPDBs: %s",
+				strings.Join(xpdbNames, ", "),
+			)
+			logger.Error(nil, "pod matches multiple xpdbs")
+
+			// When a pod matches multiple PDBs then this is a invalid configuration.
+			// We want to match the same API behavior as kubernetes, that is to return a
+			// HTTP 500 if that is the case.
+			// see upstream Kubernetes docs:
+			// https://kubernetes.io/docs/concepts/scheduling-eviction/api-eviction/#how-api-initiated-eviction-works
+			return h.admissionResponse(false,
+				"Cannot disrupt pod as it matched multiple xpdbs.",
+				ptr.To(int32(http.StatusInternalServerError))), metrics.DecisionReasonError
+		}
 
-	xpdb := xpdbs[0]
+		logger.V(1).Info("pod matches multiple xpdbs, all of them must allow the disruption", "xpdbs", xpdbNames)
+		for _, xpdb := range xpdbs {
+			rec.MatchedXPDBs = append(rec.MatchedXPDBs, audit.ObjectReference{Namespace: xpdb.Namespace, Name: xpdb.Name})
+		}
+	}
 
-	if xpdb.Spec.Suspend != nil && *xpdb.Spec.Suspend {
-		return h.admissionResponse(true, "", nil)
+	// Suspended XPDBs allow all disruptions, the remaining ones are evaluated
+	// in a deterministic order, so concurrent requests lock them in the same order.
+	active := make([]*xpdbv1alpha1.XPodDisruptionBudget, 0, len(xpdbs))
+	for _, xpdb := range xpdbs {
+		if xpdb.Spec.Suspend == nil || !*xpdb.Spec.Suspend {
+			active = append(active, xpdb)
+		}
+	}
+	if len(active) == 0 {
+		setAuditXPDB(ctx, rec, xpdbs[0])
+		return h.admissionResponse(true, "", nil), metrics.DecisionReasonSuspended
+	}
+	slices.SortFunc(active, func(a, b *xpdbv1alpha1.XPodDisruptionBudget) int {
+		return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Name, b.Name))
+	})
+
+	// Handle preactivities feature, only for pods protected by XPDBs enforcing them.
+	if i := slices.IndexFunc(active, h.preactivitiesService.Enabled); i >= 0 {
+		phaseStart = time.Now()
+		canPodBeDisrupted, err := h.preactivitiesService.CanPodBeDisrupted(ctx, pod)
+		metrics.ObserveAdmissionDuration(metrics.AdmissionPhasePreActivities, time.Since(phaseStart))
+		if err != nil {
+			return h.handleError(ctx, logger, nil, "error verifying if pod had pending pre-activities", err, ""), metrics.DecisionReasonError
+		}
+		if !canPodBeDisrupted {
+			setAuditXPDB(ctx, rec, active[i])
+			h.recordDisruptionRequest(ctx, logger, request, pod, active[i].Name)
+			return h.handleNotAllowedDisruption(ctx, logger, request, active[i], nil, pod, "", PendingActivitiesDisruptionNotAllowedMessage,
+				h.cooldown), metrics.DecisionReasonPreActivity
+		}
 	}
 
 	leaseHolderIdentity := lock.CreateLeaseHolderIdentity(h.clusterID, h.podID, pod.Namespace, pod.Name)
-	err = h.lockService.Lock(ctx, leaseHolderIdentity, xpdb.Namespace, &xpdb.Spec.Selector)
-	if err != nil {
-		logger.Error(
-			err,
-			"could obtain xpdb lock",
-			"leaseHolderIdentity", leaseHolderIdentity,
-		)
-		metrics.ObserveLockError(pod.Namespace)
-		return h.admissionResponse(
-			false,
-			"Cannot disrupt pod because xpdb couldn't obtain lock",
-			nil)
-	}
+	rec.LeaseHolderIdentity = leaseHolderIdentity
 
-	canBeDisrupted, err := h.pdbService.CanPodBeDisrupted(ctx, pod, xpdb)
-	if err != nil {
-		return h.handleError(ctx, logger, xpdb, XPDBDisruptionBudgetErrorMessage, err, leaseHolderIdentity)
+	lockedAt := time.Now()
+	var locked []*xpdbv1alpha1.XPodDisruptionBudget
+	for _, xpdb := range active {
+		setAuditXPDB(ctx, rec, xpdb)
+
+		phaseStart = time.Now()
+		err = h.lockService.Lock(ctx, leaseHolderIdentity, xpdb)
+		metrics.ObserveAdmissionDuration(metrics.AdmissionPhaseLock, time.Since(phaseStart))
+		if err != nil {
+			logger.Error(
+				err,
+				"could obtain xpdb lock",
+				"xpdb", xpdb.Name,
+				"leaseHolderIdentity", leaseHolderIdentity,
+			)
+			metrics.ObserveLockError(pod.Namespace)
+			h.unlockAll(ctx, logger, locked, leaseHolderIdentity)
+
+			// another disruption is in flight, retry once its lease expired.
+			if retryAfter := lock.RetryAfter(err); retryAfter > 0 {
+				return withRetryAfter(h.admissionResponse(
+					false,
+					XPDBLockedMessage,
+					ptr.To(int32(http.StatusTooManyRequests))), retryAfter), metrics.DecisionReasonLock
+			}
+			return h.admissionResponse(
+				false,
+				"Cannot disrupt pod because xpdb couldn't obtain lock",
+				nil), metrics.DecisionReasonLock
+		}
+		locked = append(locked, xpdb)
 	}
-	if !canBeDisrupted {
-		return h.handleNotAllowedDisruption(ctx, logger, request, xpdb, pod, leaseHolderIdentity, XPDBDisruptionBudgetNotAllowedMessage)
+
+	evals := make([]*pdb.Evaluation, len(active))
+	for i, xpdb := range active {
+		setAuditXPDB(ctx, rec, xpdb)
+
+		phaseStart = time.Now()
+		eval, err := h.pdbService.CanPodBeDisrupted(ctx, pod, xpdb)
+		metrics.ObserveAdmissionDuration(metrics.AdmissionPhaseBudget, time.Since(phaseStart))
+		if err != nil {
+			return h.handleError(ctx, logger, locked, XPDBDisruptionBudgetErrorMessage, err, leaseHolderIdentity), metrics.DecisionReasonError
+		}
+		rec.Budget = auditBudget(eval)
+		evals[i] = eval
+		if !eval.Allowed {
+			return h.handleNotAllowedDisruption(ctx, logger, request, xpdb, locked, pod, leaseHolderIdentity, XPDBDisruptionBudgetNotAllowedMessage,
+				h.cooldown), metrics.DecisionReasonBudget
+		}
 	}
 
 	// Handle disruption probe feature
-	if xpdb.Spec.Probe != nil && (xpdb.Spec.Probe.Enabled == nil || *xpdb.Spec.Probe.Enabled) {
-		canBeDisrupted, err := h.disruptionProbeService.CanPodBeDisrupted(ctx, pod, xpdb)
+	// the probes of all xpdbs have to complete while the leases are held.
+	probeCtx, cancel := context.WithDeadline(ctx,
+		lockedAt.Add(time.Duration(lock.LeaseDurationSeconds)*time.Second-probeLeaseMargin))
+	defer cancel()
+	for i, xpdb := range active {
+		if len(disruptionprobe.Probes(xpdb)) == 0 {
+			continue
+		}
+		setAuditXPDB(ctx, rec, xpdb)
+		rec.Budget = auditBudget(evals[i])
+
+		disruption := &disruptionprobe.Disruption{
+			Operation:      string(request.Operation),
+			SubResource:    request.SubResource,
+			DryRun:         ptr.Deref(request.DryRun, false),
+			User:           request.UserInfo,
+			Expected:       evals[i].Expected,
+			Healthy:        evals[i].Healthy,
+			DesiredHealthy: evals[i].DesiredHealthy,
+		}
+		phaseStart = time.Now()
+		result, err := h.disruptionProbeService.CanPodBeDisrupted(probeCtx, pod, xpdb, disruption)
+		metrics.ObserveAdmissionDuration(metrics.AdmissionPhaseProbe, time.Since(phaseStart))
+		rec.Probe = &audit.ProbeResult{}
 		if err != nil {
-			return h.handleError(ctx, logger, xpdb, XPDBDisruptionProbeErrorMessage, err, leaseHolderIdentity)
+			var probeErr *disruptionprobe.ProbeError
+			if errors.As(err, &probeErr) {
+				rec.Probe.Name, rec.Probe.Endpoint = probeErr.Probe, probeErr.Endpoint
+			}
+			rec.Probe.Error = err.Error()
+			return h.handleError(ctx, logger, locked, XPDBDisruptionProbeErrorMessage, err, leaseHolderIdentity), metrics.DecisionReasonError
 		}
-		if !canBeDisrupted {
-			return h.handleNotAllowedDisruption(ctx, logger, request, xpdb, pod, leaseHolderIdentity, XPDBDisruptionProbeNotAllowedMessage)
+		if !result.Allowed && h.preactivitiesService.Enabled(xpdb) {
+			// probes whose pre-activities timed out are ignored if the timeout policy allows the disruption,
+			// the denials of the other probes still apply.
+			result = result.Ignore(func(denial *disruptionprobe.Result) bool {
+				names := preActivityNames(denial)
+				if len(names) == 0 || !h.preactivitiesService.Overdue(pod, names) {
+					return false
+				}
+				logger.Info("pre-activities requested by probe timed out, ignoring probe", "probe", denial.Probe, "preActivities", names)
+				rec.Probe.PreActivities = append(rec.Probe.PreActivities, names...)
+				return true
+			})
+		}
+		rec.Probe.Allowed = result.Allowed
+		if !result.Allowed {
+			rec.Probe.Name, rec.Probe.Endpoint = result.Probe, result.Endpoint
+			rec.Probe.Reason, rec.Probe.Message = result.Reason, result.Message
+			retryAfter := result.RetryAfter
+			if retryAfter <= 0 {
+				retryAfter = h.cooldown
+			}
+			if len(result.PreActivities) > 0 && h.preactivitiesService.Enabled(xpdb) {
+				return h.requestPreActivities(ctx, logger, request, xpdb, locked, pod, leaseHolderIdentity, rec, result, retryAfter)
+			}
+			message := fmt.Sprintf("%s Blocked by probe %s.", XPDBDisruptionProbeNotAllowedMessage, result.Probe)
+			if result.Message != "" {
+				message = fmt.Sprintf("%s Blocked by probe %s: %s", XPDBDisruptionProbeNotAllowedMessage, result.Probe, result.Message)
+			}
+			return h.handleNotAllowedDisruption(ctx, logger, request, xpdb, locked, pod, leaseHolderIdentity,
+				message, retryAfter), metrics.DecisionReasonProbe
 		}
 	}
 
-	h.recorder.Eventf(xpdb, corev1.EventTypeNormal, string(xpdbv1alpha1.XPDBEventReasonAccepted), "attempted eviction of %s", pod.Name)
+	for _, xpdb := range active {
+		h.recorder.Eventf(xpdb, corev1.EventTypeNormal, string(xpdbv1alpha1.XPDBEventReasonAccepted), "attempted eviction of %s", pod.Name)
+	}
 
 	// We leave the pdb in a locked state because admission-control response
 	// is still in flight and the (potential) eviction hasn't been processed
 	// yet by the kube-apiserver.
-	return h.admissionResponse(true, "", nil)
+	return h.admissionResponse(true, "", nil), metrics.DecisionReasonAllowed
+}
+
+// requestPreActivities annotates the pod with the pre-activities requested by the probes and denies the disruption.
+// Later attempts are blocked until the workload removed the annotations of the pre-activities.
+// Dry runs don't annotate the pod.
+func (h PodValidationWebhook) requestPreActivities(
+	ctx context.Context,
+	logger logr.Logger,
+	request admission.Request,
+	xpdb *xpdbv1alpha1.XPodDisruptionBudget,
+	locked []*xpdbv1alpha1.XPodDisruptionBudget,
+	pod *corev1.Pod,
+	leaseHolderIdentity string,
+	rec *audit.Record,
+	result *disruptionprobe.Result,
+	retryAfter time.Duration,
+) (admission.Response, metrics.DecisionReason) {
+	activities := map[string]string{}
+	for _, pa := range result.PreActivities {
+		activities[pa.Name] = pa.Description
+	}
+	names := preActivityNames(result)
+	rec.Probe.PreActivities = names
+
+	if !h.dryRun && !ptr.Deref(request.DryRun, false) {
+		if err := h.preactivitiesService.RequestPreActivities(ctx, pod, activities); err != nil {
+			return h.handleError(ctx, logger, locked, "error requesting pre-activities", err, leaseHolderIdentity), metrics.DecisionReasonError
+		}
+	}
+	h.recordDisruptionRequest(ctx, logger, request, pod, xpdb.Name)
+
+	message := fmt.Sprintf("%s Requested by probe %s: %s.", PreActivitiesRequestedMessage, result.Probe, strings.Join(names, ", "))
+	return h.handleNotAllowedDisruption(ctx, logger, request, xpdb, locked, pod, leaseHolderIdentity, message,
+		retryAfter), metrics.DecisionReasonPreActivity
+}
+
+// recordDisruptionRequest records an eviction blocked by pre-activities, so x-pdb evicts the pod
+// on behalf of the requester once the pre-activities completed.
+// Failing to record the request doesn't change the verdict.
+func (h PodValidationWebhook) recordDisruptionRequest(
+	ctx context.Context,
+	logger logr.Logger,
+	request admission.Request,
+	pod *corev1.Pod,
+	xpdbName string,
+) {
+	if h.disruptionRequests == nil || h.dryRun || ptr.Deref(request.DryRun, false) ||
+		request.Operation != admissionv1.Create || request.SubResource != "eviction" {
+		return
+	}
+	if err := h.disruptionRequests.Record(ctx, pod, xpdbName, request.UserInfo.Username); err != nil {
+		logger.Error(err, "could not record disruption request")
+	}
+}
+
+// preActivityNames returns the distinct names of the pre-activities requested by the probes.
+func preActivityNames(result *disruptionprobe.Result) []string {
+	var names []string
+	for _, pa := range result.PreActivities {
+		if !slices.Contains(names, pa.Name) {
+			names = append(names, pa.Name)
+		}
+	}
+	return names
+}
+
+// setAuditXPDB records the xpdb being evaluated. If the pod matches multiple xpdbs,
+// the audit record and the explanation of the response refer to the one which decided the request.
+func setAuditXPDB(ctx context.Context, rec *audit.Record, xpdb *xpdbv1alpha1.XPodDisruptionBudget) {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.AttributeXPDBName.String(xpdb.Name))
+	rec.XPDB = &audit.ObjectReference{Namespace: xpdb.Namespace, Name: xpdb.Name}
+	rec.Budget = nil
+	rec.Probe = nil
 }
 
 func (h PodValidationWebhook) admissionResponse(allowed bool, message string, errorCode *int32) admission.Response {
@@ -270,18 +558,13 @@ func (h PodValidationWebhook) decodePod(ctx context.Context, request admission.R
 func (h PodValidationWebhook) handleError(
 	ctx context.Context,
 	logger logr.Logger,
-	xpdb *v1alpha1.XPodDisruptionBudget,
+	locked []*v1alpha1.XPodDisruptionBudget,
 	errorDescription string,
 	err error,
 	leaseHolderIdentity string,
 ) admission.Response {
-	if xpdb != nil {
-		logger.Error(err, "pod disruption check returned an error")
-		unlockErr := h.lockService.Unlock(ctx, leaseHolderIdentity, xpdb.Namespace, &xpdb.Spec.Selector)
-		if unlockErr != nil {
-			logger.Error(unlockErr, "unable to release xpdb lock")
-		}
-	}
+	logger.Error(err, "pod disruption check returned an error")
+	h.unlockAll(ctx, logger, locked, leaseHolderIdentity)
 
 	return h.admissionResponse(
 		false,
@@ -294,29 +577,84 @@ func (h PodValidationWebhook) handleNotAllowedDisruption(
 	logger logr.Logger,
 	request admission.Request,
 	xpdb *v1alpha1.XPodDisruptionBudget,
+	locked []*v1alpha1.XPodDisruptionBudget,
 	pod *corev1.Pod,
 	leaseHolderIdentity string,
 	admissionResponseMessage string,
+	retryAfter time.Duration,
 ) admission.Response {
 	if xpdb != nil {
 		h.recorder.Eventf(xpdb, corev1.EventTypeNormal, string(xpdbv1alpha1.XPDBEventReasonBlocked), "attempted eviction of %s", pod.Name)
 		metrics.ObserveEvictionRejected(xpdb.Namespace, request.Resource.Resource, request.SubResource, string(request.Operation))
-
-		err := h.lockService.Unlock(ctx, leaseHolderIdentity, xpdb.Namespace, &xpdb.Spec.Selector)
-		if err != nil {
-			logger.Error(err, "unable to unlock xpdb")
-		}
 	}
+	h.unlockAll(ctx, logger, locked, leaseHolderIdentity)
 
 	// We must return a HTTP 429 here so kubectl still behaves in the same way
 	// as the regular PDB would.
 	// see:
 	// https://kubernetes.io/docs/concepts/scheduling-eviction/api-eviction/#how-api-initiated-eviction-works
 	// https://github.com/kubernetes/kubectl/blob/acf4a09f2daede8fdbf65514ade9426db0367ed3/pkg/drain/drain.go#L318-L320
-	return h.admissionResponse(
+	return withRetryAfter(h.admissionResponse(
 		false,
 		admissionResponseMessage,
-		ptr.To(int32(http.StatusTooManyRequests)))
+		ptr.To(int32(http.StatusTooManyRequests))), retryAfter)
+}
+
+// withRetryAfter adds the time after which the client should retry to the status details of the response,
+// the kube-apiserver returns it as Retry-After header to the client.
+func withRetryAfter(resp admission.Response, retryAfter time.Duration) admission.Response {
+	if resp.Result == nil || retryAfter <= 0 {
+		return resp
+	}
+	resp.Result.Reason = metav1.StatusReasonTooManyRequests
+	resp.Result.Details = &metav1.StatusDetails{
+		RetryAfterSeconds: int32(math.Ceil(retryAfter.Seconds())),
+	}
+	return resp
+}
+
+// getRetryAfter returns the retry hint of the response.
+func getRetryAfter(resp admission.Response) time.Duration {
+	if resp.Result == nil || resp.Result.Details == nil {
+		return 0
+	}
+	return time.Duration(resp.Result.Details.RetryAfterSeconds) * time.Second
+}
+
+// unlockAll releases the locks of the xpdbs in reverse order.
+func (h PodValidationWebhook) unlockAll(
+	ctx context.Context,
+	logger logr.Logger,
+	locked []*v1alpha1.XPodDisruptionBudget,
+	leaseHolderIdentity string,
+) {
+	if len(locked) == 0 {
+		return
+	}
+
+	start := time.Now()
+	defer func() { metrics.ObserveAdmissionDuration(metrics.AdmissionPhaseUnlock, time.Since(start)) }()
+
+	for i := len(locked) - 1; i >= 0; i-- {
+		err := h.lockService.Unlock(ctx, leaseHolderIdentity, locked[i])
+		if err != nil {
+			logger.Error(err, "unable to release xpdb lock", "xpdb", locked[i].Name)
+		}
+	}
+}
+
+func auditBudget(eval *pdb.Evaluation) *audit.Budget {
+	clusters := make([]audit.ClusterCounts, 0, len(eval.Clusters))
+	for _, c := range eval.Clusters {
+		clusters = append(clusters, audit.ClusterCounts{Cluster: c.Cluster, Expected: c.Expected, Healthy: c.Healthy})
+	}
+	return &audit.Budget{
+		Expected:       eval.Expected,
+		Healthy:        eval.Healthy,
+		DesiredHealthy: eval.DesiredHealthy,
+		Allowed:        eval.Allowed,
+		Clusters:       clusters,
+	}
 }
 
 func getDisruptionTargetCondition(po *corev1.Pod) *corev1.PodCondition {
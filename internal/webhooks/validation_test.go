@@ -0,0 +1,323 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	xpdbv1alpha1 "github.com/form3tech-oss/x-pdb/api/v1alpha1"
+	"github.com/form3tech-oss/x-pdb/internal/audit"
+	"github.com/form3tech-oss/x-pdb/internal/disruptionprobe"
+	"github.com/form3tech-oss/x-pdb/internal/lock"
+	"github.com/form3tech-oss/x-pdb/internal/metrics"
+	"github.com/form3tech-oss/x-pdb/internal/pdb"
+	"github.com/form3tech-oss/x-pdb/internal/preactivities"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// newTestWebhook creates a webhook evaluating the pods of a StatefulSet db with 3 replicas
+// protected by the xpdb, x-pdb runs without remotes. The calls of the client can be intercepted with funcs.
+func newTestWebhook(
+	t *testing.T,
+	xpdb *xpdbv1alpha1.XPodDisruptionBudget,
+	pod *corev1.Pod,
+	funcs ...interceptor.Funcs,
+) *PodValidationWebhook {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, xpdbv1alpha1.AddToScheme(scheme))
+
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "db", UID: "sts-uid"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To(int32(3))},
+	}
+	objs := []client.Object{sts, xpdb, pod}
+	for _, name := range []string{"db-1", "db-2"} {
+		objs = append(objs, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: name}})
+	}
+	for _, obj := range objs {
+		if p, ok := obj.(*corev1.Pod); ok {
+			p.Labels = map[string]string{"app": "db"}
+			p.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db", UID: "sts-uid", Controller: ptr.To(true),
+			}}
+			p.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		}
+	}
+	builder := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...)
+	for _, f := range funcs {
+		builder = builder.WithInterceptorFuncs(f)
+	}
+	cl := builder.Build()
+
+	logger := logr.Discard()
+	creds := disruptionprobe.NewCredentials(cl, types.NamespacedName{}, nil, disruptionprobe.SecretAccess{})
+	clientPool := disruptionprobe.NewClientPool(context.Background(), &logger, t.TempDir(), nil, time.Minute, creds)
+	return NewPodValidationWebhook(
+		cl,
+		logger,
+		admission.NewDecoder(scheme),
+		record.NewFakeRecorder(100),
+		"blue",
+		"x-pdb-0",
+		false,
+		pdb.NewService(logger, cl, cl, pdb.NewScaleFinder(cl, nil), nil, "x-pdb", nil),
+		lock.NewService(&logger, cl, cl, nil, "x-pdb", nil),
+		disruptionprobe.NewService(&logger, clientPool, creds, "blue", 0),
+		preactivities.NewService(logger, cl, preactivities.Options{
+			Timeout:       10 * time.Minute,
+			TimeoutPolicy: preactivities.TimeoutPolicyAllowAfterTimeout,
+		}),
+		nil,
+		audit.NewAuditor(logger),
+		MultipleXPDBPolicyReject,
+		10*time.Second,
+	)
+}
+
+// newTestProbe starts a v2 HTTP probe responding with the response.
+func newTestProbe(t *testing.T, name string, response map[string]any) xpdbv1alpha1.XPodDisruptionBudgetProbeSpec {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(srv.Close)
+	return xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{
+		Name:            name,
+		HTTP:            &xpdbv1alpha1.XPodDisruptionBudgetHTTPProbe{URL: srv.URL},
+		ProtocolVersion: xpdbv1alpha1.ProbeProtocolVersionV2,
+	}
+}
+
+func TestPodValidationWebhook_OverduePreActivities(t *testing.T) {
+	// transfer-leadership was requested by the raft probe an hour ago and never completed.
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "db",
+		Name:        "db-0",
+		Annotations: map[string]string{preactivities.PreActivityAnnotationNamePrefix + "transfer-leadership": ""},
+	}}
+	preactivities.SetStatus(pod, "transfer-leadership", &preactivities.Status{RequestedAt: metav1.NewTime(time.Now().Add(-time.Hour))})
+	raft := newTestProbe(t, "raft", map[string]any{
+		"reason":        "RaftLeader",
+		"preActivities": []map[string]any{{"name": "transfer-leadership"}},
+	})
+	backup := newTestProbe(t, "backup", map[string]any{"reason": "BackupRunning", "message": "backup in progress"})
+	allowed := newTestProbe(t, "lag", map[string]any{"isAllowed": true})
+
+	tests := []struct {
+		name        string
+		mode        xpdbv1alpha1.ProbeMode
+		probes      []xpdbv1alpha1.XPodDisruptionBudgetProbeSpec
+		wantAllowed bool
+		wantMessage string
+	}{
+		{
+			name:        "all should keep denial of other probe",
+			mode:        xpdbv1alpha1.ProbeModeAll,
+			probes:      []xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{raft, backup},
+			wantMessage: XPDBDisruptionProbeNotAllowedMessage + " Blocked by probe backup: backup in progress",
+		},
+		{
+			name:        "all should allow if the other probe allows",
+			mode:        xpdbv1alpha1.ProbeModeAll,
+			probes:      []xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{raft, allowed},
+			wantAllowed: true,
+		},
+		{
+			name:        "any should allow",
+			mode:        xpdbv1alpha1.ProbeModeAny,
+			probes:      []xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{raft, backup},
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xpdb := &xpdbv1alpha1.XPodDisruptionBudget{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "db"},
+				Spec: xpdbv1alpha1.XPodDisruptionBudgetSpec{
+					Selector:       metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+					MaxUnavailable: ptr.To(intstr.FromInt32(1)),
+					Probes:         tt.probes,
+					ProbeMode:      tt.mode,
+				},
+			}
+			h := newTestWebhook(t, xpdb, pod.DeepCopy())
+
+			resp := h.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				UID:         "uid",
+				RequestKind: &metav1.GroupVersionKind{Group: "policy", Version: "v1", Kind: "Eviction"},
+				Operation:   admissionv1.Create,
+				SubResource: "eviction",
+				Namespace:   "db",
+				Name:        "db-0",
+			}})
+			assert.Equal(t, tt.wantAllowed, resp.Allowed)
+			if !tt.wantAllowed {
+				require.NotNil(t, resp.Result)
+				assert.Equal(t, int32(http.StatusTooManyRequests), resp.Result.Code)
+				assert.Equal(t, tt.wantMessage, resp.Result.Message)
+			}
+		})
+	}
+}
+
+func TestPodValidationWebhook_ProbeDeadline(t *testing.T) {
+	margin := probeLeaseMargin
+	// leave 500ms of the lease to the probes.
+	probeLeaseMargin = time.Duration(lock.LeaseDurationSeconds)*time.Second - 500*time.Millisecond
+	t.Cleanup(func() { probeLeaseMargin = margin })
+
+	blocked := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-blocked:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(blocked) })
+
+	xpdb := &xpdbv1alpha1.XPodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "db"},
+		Spec: xpdbv1alpha1.XPodDisruptionBudgetSpec{
+			Selector:       metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			MaxUnavailable: ptr.To(intstr.FromInt32(1)),
+			Probes: []xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{{
+				Name:    "slow",
+				HTTP:    &xpdbv1alpha1.XPodDisruptionBudgetHTTPProbe{URL: srv.URL},
+				Timeout: &metav1.Duration{Duration: time.Second},
+				Retries: 2,
+			}},
+		},
+	}
+	h := newTestWebhook(t, xpdb, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "db-0"}})
+
+	start := time.Now()
+	resp := h.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		UID:         "uid",
+		RequestKind: &metav1.GroupVersionKind{Group: "policy", Version: "v1", Kind: "Eviction"},
+		Operation:   admissionv1.Create,
+		SubResource: "eviction",
+		Namespace:   "db",
+		Name:        "db-0",
+	}})
+	assert.Less(t, time.Since(start), time.Second, "probes should be canceled at the deadline")
+	assert.False(t, resp.Allowed)
+	require.NotNil(t, resp.Result)
+	assert.Contains(t, resp.Result.Message, XPDBDisruptionProbeErrorMessage)
+}
+
+func TestPodValidationWebhook_UnresolvedTarget(t *testing.T) {
+	xpdb := &xpdbv1alpha1.XPodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "db"},
+		Spec: xpdbv1alpha1.XPodDisruptionBudgetSpec{
+			TargetRef:      &xpdbv1alpha1.XPodDisruptionBudgetTargetRef{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db"},
+			MaxUnavailable: ptr.To(intstr.FromInt32(1)),
+		},
+	}
+	h := newTestWebhook(t, xpdb, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "db-0"}}, interceptor.Funcs{
+		SubResourceGet: func(_ context.Context, _ client.Client, _ string, obj client.Object, _ client.Object, _ ...client.SubResourceGetOption) error {
+			return apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "statefulsets"}, obj.GetName(), nil)
+		},
+	})
+
+	resp := h.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		UID:         "uid",
+		RequestKind: &metav1.GroupVersionKind{Group: "policy", Version: "v1", Kind: "Eviction"},
+		Operation:   admissionv1.Create,
+		SubResource: "eviction",
+		Namespace:   "db",
+		Name:        "db-0",
+	}})
+	assert.False(t, resp.Allowed, "disruption should be denied if the target of the xpdb can't be resolved")
+}
+
+// recordingSink keeps the audit records written to it.
+type recordingSink struct {
+	records []*audit.Record
+}
+
+func (s *recordingSink) Write(rec *audit.Record) error {
+	s.records = append(s.records, rec)
+	return nil
+}
+
+func TestPodValidationWebhook_AuditBudgetOfProbedDisruption(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    map[string]any
+		wantAllowed bool
+		wantReason  metrics.DecisionReason
+	}{
+		{
+			name:        "allowed by probe",
+			response:    map[string]any{"isAllowed": true},
+			wantAllowed: true,
+			wantReason:  metrics.DecisionReasonAllowed,
+		},
+		{
+			name:       "denied by probe",
+			response:   map[string]any{"reason": "BackupRunning"},
+			wantReason: metrics.DecisionReasonProbe,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xpdb := &xpdbv1alpha1.XPodDisruptionBudget{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "db"},
+				Spec: xpdbv1alpha1.XPodDisruptionBudgetSpec{
+					Selector:       metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+					MaxUnavailable: ptr.To(intstr.FromInt32(1)),
+					Probes:         []xpdbv1alpha1.XPodDisruptionBudgetProbeSpec{newTestProbe(t, "backup", tt.response)},
+				},
+			}
+			h := newTestWebhook(t, xpdb, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "db-0"}})
+			sink := &recordingSink{}
+			h.auditor = audit.NewAuditor(logr.Discard(), sink)
+
+			resp := h.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				UID:         "uid",
+				RequestKind: &metav1.GroupVersionKind{Group: "policy", Version: "v1", Kind: "Eviction"},
+				Operation:   admissionv1.Create,
+				SubResource: "eviction",
+				Namespace:   "db",
+				Name:        "db-0",
+			}})
+			assert.Equal(t, tt.wantAllowed, resp.Allowed)
+
+			require.Len(t, sink.records, 1)
+			rec := sink.records[0]
+			assert.Equal(t, string(tt.wantReason), rec.Reason)
+			require.NotNil(t, rec.Probe)
+			assert.Equal(t, tt.wantAllowed, rec.Probe.Allowed)
+			require.NotNil(t, rec.Budget, "budget should be recorded for disruptions decided by a probe")
+			assert.Equal(t, int32(3), rec.Budget.Expected)
+			assert.Equal(t, int32(3), rec.Budget.Healthy)
+			assert.Equal(t, int32(2), rec.Budget.DesiredHealthy)
+			assert.True(t, rec.Budget.Allowed)
+			assert.Equal(t, "3", resp.AuditAnnotations[AuditAnnotationExpected])
+			assert.Equal(t, "3", resp.AuditAnnotations[AuditAnnotationHealthy])
+			assert.Equal(t, "2", resp.AuditAnnotations[AuditAnnotationDesiredHealthy])
+		})
+	}
+}
@@ -0,0 +1,719 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.0
+// 	protoc        (unknown)
+// source: disruptionprobe/v2/disruptionprobe.proto
+
+package disruptionprobe
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// IsDisruptionAllowedRequest has the information to request a check for disruption.
+type IsDisruptionAllowedRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The pod that is being disrupted.
+	Pod *Pod `protobuf:"bytes,1,opt,name=pod,proto3" json:"pod,omitempty"`
+	// The XPodDisruptionBudget resource that was protecting the pod.
+	Xpdb *XPodDisruptionBudget `protobuf:"bytes,2,opt,name=xpdb,proto3" json:"xpdb,omitempty"`
+	// The operation of the admission request, e.g. DELETE, or CREATE for evictions.
+	Operation string `protobuf:"bytes,3,opt,name=operation,proto3" json:"operation,omitempty"`
+	// The subresource of the admission request, e.g. eviction.
+	SubResource string `protobuf:"bytes,4,opt,name=sub_resource,json=subResource,proto3" json:"sub_resource,omitempty"`
+	// Whether the admission request is a dry run.
+	DryRun bool `protobuf:"varint,5,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	// The user requesting the disruption.
+	User *UserInfo `protobuf:"bytes,6,opt,name=user,proto3" json:"user,omitempty"`
+	// The ID of the cluster the disruption is requested in.
+	ClusterId string `protobuf:"bytes,7,opt,name=cluster_id,json=clusterId,proto3" json:"cluster_id,omitempty"`
+	// The pod counts of the XPodDisruptionBudget across all clusters.
+	Counts        *PodCounts `protobuf:"bytes,8,opt,name=counts,proto3" json:"counts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IsDisruptionAllowedRequest) Reset() {
+	*x = IsDisruptionAllowedRequest{}
+	mi := &file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IsDisruptionAllowedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IsDisruptionAllowedRequest) ProtoMessage() {}
+
+func (x *IsDisruptionAllowedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IsDisruptionAllowedRequest.ProtoReflect.Descriptor instead.
+func (*IsDisruptionAllowedRequest) Descriptor() ([]byte, []int) {
+	return file_disruptionprobe_v2_disruptionprobe_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *IsDisruptionAllowedRequest) GetPod() *Pod {
+	if x != nil {
+		return x.Pod
+	}
+	return nil
+}
+
+func (x *IsDisruptionAllowedRequest) GetXpdb() *XPodDisruptionBudget {
+	if x != nil {
+		return x.Xpdb
+	}
+	return nil
+}
+
+func (x *IsDisruptionAllowedRequest) GetOperation() string {
+	if x != nil {
+		return x.Operation
+	}
+	return ""
+}
+
+func (x *IsDisruptionAllowedRequest) GetSubResource() string {
+	if x != nil {
+		return x.SubResource
+	}
+	return ""
+}
+
+func (x *IsDisruptionAllowedRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *IsDisruptionAllowedRequest) GetUser() *UserInfo {
+	if x != nil {
+		return x.User
+	}
+	return nil
+}
+
+func (x *IsDisruptionAllowedRequest) GetClusterId() string {
+	if x != nil {
+		return x.ClusterId
+	}
+	return ""
+}
+
+func (x *IsDisruptionAllowedRequest) GetCounts() *PodCounts {
+	if x != nil {
+		return x.Counts
+	}
+	return nil
+}
+
+// Pod identifies the pod that is being disrupted.
+type Pod struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The name of the pod.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// The namespace of the pod.
+	Namespace string `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// The labels of the pod.
+	Labels map[string]string `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The name of the node the pod runs on.
+	NodeName      string `protobuf:"bytes,4,opt,name=node_name,json=nodeName,proto3" json:"node_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Pod) Reset() {
+	*x = Pod{}
+	mi := &file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Pod) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Pod) ProtoMessage() {}
+
+func (x *Pod) ProtoReflect() protoreflect.Message {
+	mi := &file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Pod.ProtoReflect.Descriptor instead.
+func (*Pod) Descriptor() ([]byte, []int) {
+	return file_disruptionprobe_v2_disruptionprobe_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Pod) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Pod) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *Pod) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Pod) GetNodeName() string {
+	if x != nil {
+		return x.NodeName
+	}
+	return ""
+}
+
+// XPodDisruptionBudget identifies the XPodDisruptionBudget resource protecting the pod.
+type XPodDisruptionBudget struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The name of the XPodDisruptionBudget.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// The namespace of the XPodDisruptionBudget.
+	Namespace     string `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *XPodDisruptionBudget) Reset() {
+	*x = XPodDisruptionBudget{}
+	mi := &file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *XPodDisruptionBudget) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*XPodDisruptionBudget) ProtoMessage() {}
+
+func (x *XPodDisruptionBudget) ProtoReflect() protoreflect.Message {
+	mi := &file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use XPodDisruptionBudget.ProtoReflect.Descriptor instead.
+func (*XPodDisruptionBudget) Descriptor() ([]byte, []int) {
+	return file_disruptionprobe_v2_disruptionprobe_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *XPodDisruptionBudget) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *XPodDisruptionBudget) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+// UserInfo describes the user requesting the disruption.
+type UserInfo struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The name of the user, e.g. system:serviceaccount:kube-system:cluster-autoscaler.
+	Username string `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	// The UID of the user.
+	Uid string `protobuf:"bytes,2,opt,name=uid,proto3" json:"uid,omitempty"`
+	// The groups of the user.
+	Groups        []string `protobuf:"bytes,3,rep,name=groups,proto3" json:"groups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserInfo) Reset() {
+	*x = UserInfo{}
+	mi := &file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserInfo) ProtoMessage() {}
+
+func (x *UserInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserInfo.ProtoReflect.Descriptor instead.
+func (*UserInfo) Descriptor() ([]byte, []int) {
+	return file_disruptionprobe_v2_disruptionprobe_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UserInfo) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *UserInfo) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *UserInfo) GetGroups() []string {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+// PodCounts are the pod counts of the XPodDisruptionBudget across all clusters.
+type PodCounts struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The number of pods expected.
+	Expected int32 `protobuf:"varint,1,opt,name=expected,proto3" json:"expected,omitempty"`
+	// The number of healthy pods.
+	Healthy int32 `protobuf:"varint,2,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	// The minimum number of healthy pods required by the XPodDisruptionBudget.
+	DesiredHealthy int32 `protobuf:"varint,3,opt,name=desired_healthy,json=desiredHealthy,proto3" json:"desired_healthy,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *PodCounts) Reset() {
+	*x = PodCounts{}
+	mi := &file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PodCounts) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PodCounts) ProtoMessage() {}
+
+func (x *PodCounts) ProtoReflect() protoreflect.Message {
+	mi := &file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PodCounts.ProtoReflect.Descriptor instead.
+func (*PodCounts) Descriptor() ([]byte, []int) {
+	return file_disruptionprobe_v2_disruptionprobe_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PodCounts) GetExpected() int32 {
+	if x != nil {
+		return x.Expected
+	}
+	return 0
+}
+
+func (x *PodCounts) GetHealthy() int32 {
+	if x != nil {
+		return x.Healthy
+	}
+	return 0
+}
+
+func (x *PodCounts) GetDesiredHealthy() int32 {
+	if x != nil {
+		return x.DesiredHealthy
+	}
+	return 0
+}
+
+// IsDisruptionAllowedResponse has the information on whether a disruption is allowed or not.
+type IsDisruptionAllowedResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Information on whether disruption is allowed.
+	IsAllowed bool `protobuf:"varint,1,opt,name=is_allowed,json=isAllowed,proto3" json:"is_allowed,omitempty"`
+	// Error information if the probe could not decide whether the disruption is allowed.
+	Error string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	// A machine readable reason of the verdict in CamelCase, e.g. RaftLeaderElection.
+	Reason string `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	// A human readable message explaining the verdict. It is added to the admission response.
+	Message string `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	// RetryAfterSeconds is the suggested delay before the disruption should be attempted again.
+	// Optional, only considered if the disruption is not allowed.
+	RetryAfterSeconds int32 `protobuf:"varint,5,opt,name=retry_after_seconds,json=retryAfterSeconds,proto3" json:"retry_after_seconds,omitempty"`
+	// PreActivities the workload wants performed before the pod is disrupted.
+	PreActivities []*PreActivity `protobuf:"bytes,6,rep,name=pre_activities,json=preActivities,proto3" json:"pre_activities,omitempty"`
+	// MaxAgeSeconds is the time x-pdb may reuse the verdict for disruptions of the same pod.
+	// Optional, if unset the verdict is cached for the cache ttl configured in x-pdb, 0 disables caching.
+	// Verdicts requesting pre-activities are never cached.
+	MaxAgeSeconds *int32 `protobuf:"varint,7,opt,name=max_age_seconds,json=maxAgeSeconds,proto3,oneof" json:"max_age_seconds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IsDisruptionAllowedResponse) Reset() {
+	*x = IsDisruptionAllowedResponse{}
+	mi := &file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IsDisruptionAllowedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IsDisruptionAllowedResponse) ProtoMessage() {}
+
+func (x *IsDisruptionAllowedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IsDisruptionAllowedResponse.ProtoReflect.Descriptor instead.
+func (*IsDisruptionAllowedResponse) Descriptor() ([]byte, []int) {
+	return file_disruptionprobe_v2_disruptionprobe_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *IsDisruptionAllowedResponse) GetIsAllowed() bool {
+	if x != nil {
+		return x.IsAllowed
+	}
+	return false
+}
+
+func (x *IsDisruptionAllowedResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *IsDisruptionAllowedResponse) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *IsDisruptionAllowedResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *IsDisruptionAllowedResponse) GetRetryAfterSeconds() int32 {
+	if x != nil {
+		return x.RetryAfterSeconds
+	}
+	return 0
+}
+
+func (x *IsDisruptionAllowedResponse) GetPreActivities() []*PreActivity {
+	if x != nil {
+		return x.PreActivities
+	}
+	return nil
+}
+
+func (x *IsDisruptionAllowedResponse) GetMaxAgeSeconds() int32 {
+	if x != nil && x.MaxAgeSeconds != nil {
+		return *x.MaxAgeSeconds
+	}
+	return 0
+}
+
+// PreActivity is an activity the workload wants performed before the pod is disrupted,
+// e.g. transferring the leadership of a raft group.
+type PreActivity struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The name of the pre-activity, a valid annotation name segment.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// A human readable description of the pre-activity.
+	Description   string `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PreActivity) Reset() {
+	*x = PreActivity{}
+	mi := &file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PreActivity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PreActivity) ProtoMessage() {}
+
+func (x *PreActivity) ProtoReflect() protoreflect.Message {
+	mi := &file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PreActivity.ProtoReflect.Descriptor instead.
+func (*PreActivity) Descriptor() ([]byte, []int) {
+	return file_disruptionprobe_v2_disruptionprobe_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PreActivity) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *PreActivity) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+var File_disruptionprobe_v2_disruptionprobe_proto protoreflect.FileDescriptor
+
+var file_disruptionprobe_v2_disruptionprobe_proto_rawDesc = []byte{
+	0x0a, 0x28, 0x64, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x70, 0x72, 0x6f, 0x62,
+	0x65, 0x2f, 0x76, 0x32, 0x2f, 0x64, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x70,
+	0x72, 0x6f, 0x62, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x12, 0x64, 0x69, 0x73, 0x72,
+	0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x2e, 0x76, 0x32, 0x22, 0xe7,
+	0x02, 0x0a, 0x1a, 0x49, 0x73, 0x44, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x41,
+	0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x29, 0x0a,
+	0x03, 0x70, 0x6f, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x64, 0x69, 0x73,
+	0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x2e, 0x76, 0x32, 0x2e,
+	0x50, 0x6f, 0x64, 0x52, 0x03, 0x70, 0x6f, 0x64, 0x12, 0x3c, 0x0a, 0x04, 0x78, 0x70, 0x64, 0x62,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x64, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x2e, 0x76, 0x32, 0x2e, 0x58, 0x50, 0x6f, 0x64,
+	0x44, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x42, 0x75, 0x64, 0x67, 0x65, 0x74,
+	0x52, 0x04, 0x78, 0x70, 0x64, 0x62, 0x12, 0x1c, 0x0a, 0x09, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6f, 0x70, 0x65, 0x72, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x75, 0x62, 0x5f, 0x72, 0x65, 0x73, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x73, 0x75, 0x62, 0x52,
+	0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x64, 0x72, 0x79, 0x5f, 0x72,
+	0x75, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x64, 0x72, 0x79, 0x52, 0x75, 0x6e,
+	0x12, 0x30, 0x0a, 0x04, 0x75, 0x73, 0x65, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1c,
+	0x2e, 0x64, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x70, 0x72, 0x6f, 0x62, 0x65,
+	0x2e, 0x76, 0x32, 0x2e, 0x55, 0x73, 0x65, 0x72, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x04, 0x75, 0x73,
+	0x65, 0x72, 0x12, 0x1d, 0x0a, 0x0a, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x5f, 0x69, 0x64,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x49,
+	0x64, 0x12, 0x35, 0x0a, 0x06, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1d, 0x2e, 0x64, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x70, 0x72,
+	0x6f, 0x62, 0x65, 0x2e, 0x76, 0x32, 0x2e, 0x50, 0x6f, 0x64, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x73,
+	0x52, 0x06, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x22, 0xcc, 0x01, 0x0a, 0x03, 0x50, 0x6f, 0x64,
+	0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61,
+	0x63, 0x65, 0x12, 0x3b, 0x0a, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x18, 0x03, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x23, 0x2e, 0x64, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x70,
+	0x72, 0x6f, 0x62, 0x65, 0x2e, 0x76, 0x32, 0x2e, 0x50, 0x6f, 0x64, 0x2e, 0x4c, 0x61, 0x62, 0x65,
+	0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x12,
+	0x1b, 0x0a, 0x09, 0x6e, 0x6f, 0x64, 0x65, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x6e, 0x6f, 0x64, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x1a, 0x39, 0x0a, 0x0b,
+	0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
+	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x48, 0x0a, 0x14, 0x58, 0x50, 0x6f, 0x64, 0x44,
+	0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x42, 0x75, 0x64, 0x67, 0x65, 0x74, 0x12,
+	0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63,
+	0x65, 0x22, 0x50, 0x0a, 0x08, 0x55, 0x73, 0x65, 0x72, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x1a, 0x0a,
+	0x08, 0x75, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x75, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x75, 0x69, 0x64,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x75, 0x69, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x67,
+	0x72, 0x6f, 0x75, 0x70, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06, 0x67, 0x72, 0x6f,
+	0x75, 0x70, 0x73, 0x22, 0x6a, 0x0a, 0x09, 0x50, 0x6f, 0x64, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x73,
+	0x12, 0x1a, 0x0a, 0x08, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x08, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x12, 0x18, 0x0a, 0x07,
+	0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x68,
+	0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x12, 0x27, 0x0a, 0x0f, 0x64, 0x65, 0x73, 0x69, 0x72, 0x65,
+	0x64, 0x5f, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x0e, 0x64, 0x65, 0x73, 0x69, 0x72, 0x65, 0x64, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x22,
+	0xbd, 0x02, 0x0a, 0x1b, 0x49, 0x73, 0x44, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e,
+	0x41, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x1d, 0x0a, 0x0a, 0x69, 0x73, 0x5f, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x09, 0x69, 0x73, 0x41, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x12, 0x14,
+	0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65,
+	0x72, 0x72, 0x6f, 0x72, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2e, 0x0a, 0x13, 0x72, 0x65, 0x74, 0x72, 0x79, 0x5f,
+	0x61, 0x66, 0x74, 0x65, 0x72, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x11, 0x72, 0x65, 0x74, 0x72, 0x79, 0x41, 0x66, 0x74, 0x65, 0x72, 0x53,
+	0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x12, 0x46, 0x0a, 0x0e, 0x70, 0x72, 0x65, 0x5f, 0x61, 0x63,
+	0x74, 0x69, 0x76, 0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f,
+	0x2e, 0x64, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x70, 0x72, 0x6f, 0x62, 0x65,
+	0x2e, 0x76, 0x32, 0x2e, 0x50, 0x72, 0x65, 0x41, 0x63, 0x74, 0x69, 0x76, 0x69, 0x74, 0x79, 0x52,
+	0x0d, 0x70, 0x72, 0x65, 0x41, 0x63, 0x74, 0x69, 0x76, 0x69, 0x74, 0x69, 0x65, 0x73, 0x12, 0x2b,
+	0x0a, 0x0f, 0x6d, 0x61, 0x78, 0x5f, 0x61, 0x67, 0x65, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64,
+	0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x48, 0x00, 0x52, 0x0d, 0x6d, 0x61, 0x78, 0x41, 0x67,
+	0x65, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x88, 0x01, 0x01, 0x42, 0x12, 0x0a, 0x10, 0x5f,
+	0x6d, 0x61, 0x78, 0x5f, 0x61, 0x67, 0x65, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x22,
+	0x43, 0x0a, 0x0b, 0x50, 0x72, 0x65, 0x41, 0x63, 0x74, 0x69, 0x76, 0x69, 0x74, 0x79, 0x12, 0x12,
+	0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61,
+	0x6d, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f,
+	0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70,
+	0x74, 0x69, 0x6f, 0x6e, 0x32, 0x92, 0x01, 0x0a, 0x16, 0x44, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x50, 0x72, 0x6f, 0x62, 0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12,
+	0x78, 0x0a, 0x13, 0x49, 0x73, 0x44, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x41,
+	0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x12, 0x2e, 0x2e, 0x64, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x2e, 0x76, 0x32, 0x2e, 0x49, 0x73, 0x44, 0x69,
+	0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x41, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2f, 0x2e, 0x64, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x2e, 0x76, 0x32, 0x2e, 0x49, 0x73, 0x44, 0x69,
+	0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x41, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x42, 0xd1, 0x01, 0x0a, 0x16, 0x63, 0x6f,
+	0x6d, 0x2e, 0x64, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x70, 0x72, 0x6f, 0x62,
+	0x65, 0x2e, 0x76, 0x32, 0x42, 0x14, 0x44, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e,
+	0x70, 0x72, 0x6f, 0x62, 0x65, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01, 0x5a, 0x38, 0x67, 0x69,
+	0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x66, 0x6f, 0x72, 0x6d, 0x33, 0x74, 0x65,
+	0x63, 0x68, 0x2d, 0x6f, 0x73, 0x73, 0x2f, 0x78, 0x2d, 0x70, 0x64, 0x62, 0x2f, 0x70, 0x6b, 0x67,
+	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x64, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f,
+	0x6e, 0x70, 0x72, 0x6f, 0x62, 0x65, 0xa2, 0x02, 0x03, 0x44, 0x58, 0x58, 0xaa, 0x02, 0x12, 0x44,
+	0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x2e, 0x56,
+	0x32, 0xca, 0x02, 0x12, 0x44, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x70, 0x72,
+	0x6f, 0x62, 0x65, 0x5c, 0x56, 0x32, 0xe2, 0x02, 0x1e, 0x44, 0x69, 0x73, 0x72, 0x75, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x5c, 0x56, 0x32, 0x5c, 0x47, 0x50, 0x42, 0x4d,
+	0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0xea, 0x02, 0x13, 0x44, 0x69, 0x73, 0x72, 0x75, 0x70,
+	0x74, 0x69, 0x6f, 0x6e, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x3a, 0x3a, 0x56, 0x32, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_disruptionprobe_v2_disruptionprobe_proto_rawDescOnce sync.Once
+	file_disruptionprobe_v2_disruptionprobe_proto_rawDescData = file_disruptionprobe_v2_disruptionprobe_proto_rawDesc
+)
+
+func file_disruptionprobe_v2_disruptionprobe_proto_rawDescGZIP() []byte {
+	file_disruptionprobe_v2_disruptionprobe_proto_rawDescOnce.Do(func() {
+		file_disruptionprobe_v2_disruptionprobe_proto_rawDescData = protoimpl.X.CompressGZIP(file_disruptionprobe_v2_disruptionprobe_proto_rawDescData)
+	})
+	return file_disruptionprobe_v2_disruptionprobe_proto_rawDescData
+}
+
+var file_disruptionprobe_v2_disruptionprobe_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_disruptionprobe_v2_disruptionprobe_proto_goTypes = []any{
+	(*IsDisruptionAllowedRequest)(nil),  // 0: disruptionprobe.v2.IsDisruptionAllowedRequest
+	(*Pod)(nil),                         // 1: disruptionprobe.v2.Pod
+	(*XPodDisruptionBudget)(nil),        // 2: disruptionprobe.v2.XPodDisruptionBudget
+	(*UserInfo)(nil),                    // 3: disruptionprobe.v2.UserInfo
+	(*PodCounts)(nil),                   // 4: disruptionprobe.v2.PodCounts
+	(*IsDisruptionAllowedResponse)(nil), // 5: disruptionprobe.v2.IsDisruptionAllowedResponse
+	(*PreActivity)(nil),                 // 6: disruptionprobe.v2.PreActivity
+	nil,                                 // 7: disruptionprobe.v2.Pod.LabelsEntry
+}
+var file_disruptionprobe_v2_disruptionprobe_proto_depIdxs = []int32{
+	1, // 0: disruptionprobe.v2.IsDisruptionAllowedRequest.pod:type_name -> disruptionprobe.v2.Pod
+	2, // 1: disruptionprobe.v2.IsDisruptionAllowedRequest.xpdb:type_name -> disruptionprobe.v2.XPodDisruptionBudget
+	3, // 2: disruptionprobe.v2.IsDisruptionAllowedRequest.user:type_name -> disruptionprobe.v2.UserInfo
+	4, // 3: disruptionprobe.v2.IsDisruptionAllowedRequest.counts:type_name -> disruptionprobe.v2.PodCounts
+	7, // 4: disruptionprobe.v2.Pod.labels:type_name -> disruptionprobe.v2.Pod.LabelsEntry
+	6, // 5: disruptionprobe.v2.IsDisruptionAllowedResponse.pre_activities:type_name -> disruptionprobe.v2.PreActivity
+	0, // 6: disruptionprobe.v2.DisruptionProbeService.IsDisruptionAllowed:input_type -> disruptionprobe.v2.IsDisruptionAllowedRequest
+	5, // 7: disruptionprobe.v2.DisruptionProbeService.IsDisruptionAllowed:output_type -> disruptionprobe.v2.IsDisruptionAllowedResponse
+	7, // [7:8] is the sub-list for method output_type
+	6, // [6:7] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_disruptionprobe_v2_disruptionprobe_proto_init() }
+func file_disruptionprobe_v2_disruptionprobe_proto_init() {
+	if File_disruptionprobe_v2_disruptionprobe_proto != nil {
+		return
+	}
+	file_disruptionprobe_v2_disruptionprobe_proto_msgTypes[5].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_disruptionprobe_v2_disruptionprobe_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_disruptionprobe_v2_disruptionprobe_proto_goTypes,
+		DependencyIndexes: file_disruptionprobe_v2_disruptionprobe_proto_depIdxs,
+		MessageInfos:      file_disruptionprobe_v2_disruptionprobe_proto_msgTypes,
+	}.Build()
+	File_disruptionprobe_v2_disruptionprobe_proto = out.File
+	file_disruptionprobe_v2_disruptionprobe_proto_rawDesc = nil
+	file_disruptionprobe_v2_disruptionprobe_proto_goTypes = nil
+	file_disruptionprobe_v2_disruptionprobe_proto_depIdxs = nil
+}